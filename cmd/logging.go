@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"github.com/bz888/blab/internal/config"
+	"github.com/bz888/blab/internal/logger"
+	"log"
+	"log/slog"
+)
+
+// initLogger starts logging from the command line flags. The subcommands
+// run without a debug console.
+func initLogger(console logger.Console) {
+	level, err := logger.ParseLevel(config.LogLevel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tagLevels, err := logger.ParseTagLevels(config.LogTags)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	path := config.LogPath
+	if path == "" {
+		if path, err = logger.DefaultDir(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	err = logger.InitLogger(logger.Options{
+		Dev:  config.Dev,
+		Path: path,
+		Rotate: logger.RotateConfig{
+			MaxSize:  int64(config.LogMaxSize) << 20,
+			MaxAge:   config.LogMaxAge,
+			Keep:     config.LogKeep,
+			Compress: config.LogGzip,
+		},
+		Console: console,
+		// The console filters by level itself, so it gets debug entries
+		// whatever -logLevel is.
+		ConsoleLevel: slog.LevelDebug,
+		Level:        level,
+		TagLevels:    tagLevels,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/bz888/blab/internal/speech"
+	"log"
+)
+
+// replay re-runs a session saved with -recordDir without starting the TUI.
+func replay(dir string) {
+	if dir == "" {
+		log.Fatal("usage: blab replay <session dir>")
+	}
+
+	initLogger(nil)
+	speech.Init()
+
+	report, err := speech.Replay(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(report)
+}
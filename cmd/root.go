@@ -1,12 +1,12 @@
 package cmd
 
 import (
-	"github.com/bz888/blab/internal/api"
+	"flag"
 	"github.com/bz888/blab/internal/api/server"
 	"github.com/bz888/blab/internal/config"
-	"github.com/bz888/blab/internal/logger"
 	"github.com/bz888/blab/internal/speech"
 	"github.com/bz888/blab/internal/ui"
+	"github.com/bz888/blab/pkg/blabclient"
 	"log"
 )
 
@@ -15,19 +15,38 @@ func init() {
 }
 
 func Execute() {
+	switch flag.Arg(0) {
+	case "transcribe":
+		transcribe(flag.Arg(1))
+		return
+	case "replay":
+		replay(flag.Arg(1))
+		return
+	}
+
 	ui.Init()
 	debugConsole, err := ui.GetDebugConsole()
-
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	logger.InitLogger(config.Dev, config.LogPath, debugConsole)
+	initLogger(debugConsole)
 
-	api.Init()
 	server.Init()
 	speech.Init()
 
-	go server.Run()
-	ui.Run()
+	listener, err := server.Listen()
+	if err != nil {
+		log.Fatal(err)
+	}
+	authConfig, err := server.LoadAuth()
+	if err != nil {
+		log.Fatal(err)
+	}
+	client, err := blabclient.New(server.Address(listener.Addr()), blabclient.WithToken(authConfig.ClientToken()))
+	if err != nil {
+		log.Fatal(err)
+	}
+	go server.Run(listener, authConfig)
+	ui.Run(client)
 }
@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/bz888/blab/internal/speech"
+	"log"
+)
+
+// transcribe runs a WAV file through the speech pipeline without starting the TUI.
+func transcribe(path string) {
+	if path == "" {
+		log.Fatal("usage: blab transcribe <file.wav>")
+	}
+
+	initLogger(nil)
+	speech.Init()
+
+	text, err := speech.TranscribeFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(text)
+}
@@ -14,6 +14,7 @@ require (
 	github.com/rivo/tview v0.0.0-20240524063012-037df494fb76
 	github.com/streamer45/silero-vad-go v0.1.3
 	github.com/stretchr/testify v1.8.4
+	golang.org/x/text v0.16.0
 )
 
 require (
@@ -29,6 +30,5 @@ require (
 	github.com/stretchr/objx v0.5.0 // indirect
 	golang.org/x/sys v0.21.0 // indirect
 	golang.org/x/term v0.21.0 // indirect
-	golang.org/x/text v0.16.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
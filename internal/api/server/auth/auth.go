@@ -0,0 +1,213 @@
+// Package auth guards the server's API with bearer tokens, each of which may
+// be limited to some models, and answers browser clients according to the
+// CORS settings.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FileName is the settings file in the config directory.
+const FileName = "auth.json"
+
+// Token grants access to the API.
+type Token struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	// Models lists the models the token may see and chat with, as
+	// provider-qualified ids or bare names; "*" matches within either, as in
+	// "ollama/*". An empty list allows every model and the endpoints that
+	// manage models and providers.
+	Models []string `json:"models,omitempty"`
+}
+
+// CORS lists the browser origins allowed to call the API, such as
+// "http://localhost:3000", or "*" for any.
+type CORS struct {
+	AllowedOrigins []string `json:"allowed_origins"`
+}
+
+// Config is the content of the settings file.
+type Config struct {
+	Tokens []Token `json:"tokens"`
+	CORS   CORS    `json:"cors"`
+}
+
+// Load reads the settings file at filename. On first run, when there is no
+// file, it creates one holding a new unrestricted token.
+func Load(filename string) (*Config, error) {
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return create(filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", filename, err)
+	}
+	if len(config.Tokens) == 0 {
+		return nil, fmt.Errorf("%s lists no tokens", filename)
+	}
+	for _, token := range config.Tokens {
+		if token.Token == "" {
+			return nil, fmt.Errorf("%s: token %q is empty", filename, token.Name)
+		}
+	}
+	return &config, nil
+}
+
+func create(filename string) (*Config, error) {
+	token, err := NewToken()
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{Tokens: []Token{{Name: "default", Token: token}}}
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return nil, err
+	}
+	// The token is a secret, so only the user may read it.
+	if err := os.WriteFile(filename, append(data, '\n'), 0600); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// NewToken returns a random token.
+func NewToken() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return "blab_" + hex.EncodeToString(secret), nil
+}
+
+// ClientToken is the token blab's own TUI uses: the first one in the file.
+func (c *Config) ClientToken() string {
+	return c.Tokens[0].Token
+}
+
+type tokenKey struct{}
+
+// Middleware answers CORS preflight requests and rejects requests without
+// a valid bearer token, except to the public paths. With required unset it
+// only applies the CORS settings.
+func (c *Config) Middleware(next http.Handler, required bool, public ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" {
+			if !c.allowsOrigin(origin) {
+				http.Error(w, "Origin not allowed", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+			if r.Method == http.MethodOptions {
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+
+		if !required || contains(public, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token, ok := c.authenticate(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="blab"`)
+			http.Error(w, "A valid bearer token is required", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tokenKey{}, token)))
+	})
+}
+
+func (c *Config) authenticate(r *http.Request) (Token, bool) {
+	presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || presented == "" {
+		return Token{}, false
+	}
+	for _, token := range c.Tokens {
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(token.Token)) == 1 {
+			return token, true
+		}
+	}
+	return Token{}, false
+}
+
+func (c *Config) allowsOrigin(origin string) bool {
+	return contains(c.CORS.AllowedOrigins, "*") || contains(c.CORS.AllowedOrigins, origin)
+}
+
+// AllowsModel reports whether the request's token may use a model. Requests
+// that were not authenticated, as when auth is off, may use every model.
+func AllowsModel(ctx context.Context, provider, name string) bool {
+	token, ok := ctx.Value(tokenKey{}).(Token)
+	if !ok || len(token.Models) == 0 {
+		return true
+	}
+	for _, pattern := range token.Models {
+		for _, id := range []string{provider + "/" + name, name} {
+			if matched, _ := path.Match(pattern, id); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Owner identifies the request's token, for data kept per client such as
+// chat sessions. Requests that were not authenticated all share the empty
+// owner.
+func Owner(ctx context.Context) string {
+	token, _ := ctx.Value(tokenKey{}).(Token)
+	return token.Token
+}
+
+// Unrestricted reports whether the request's token may use every model, and
+// so manage models and providers.
+func Unrestricted(ctx context.Context) bool {
+	token, ok := ctx.Value(tokenKey{}).(Token)
+	return !ok || len(token.Models) == 0
+}
+
+// RequireUnrestricted refuses requests whose token is limited to some
+// models, for endpoints that manage models and providers or show traffic.
+func RequireUnrestricted(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !Unrestricted(r.Context()) {
+			http.Error(w, "This token is limited to some models", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadCreatesTokenOnFirstRun(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "blab", FileName)
+
+	config, err := Load(filename)
+	require.NoError(t, err)
+	require.Len(t, config.Tokens, 1)
+	assert.True(t, strings.HasPrefix(config.ClientToken(), "blab_"))
+
+	info, err := os.Stat(filename)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "the token is only readable by the user")
+
+	again, err := Load(filename)
+	require.NoError(t, err)
+	assert.Equal(t, config.ClientToken(), again.ClientToken(), "the token is kept across runs")
+}
+
+func TestLoadRejectsEmptyTokens(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), FileName)
+	require.NoError(t, os.WriteFile(filename, []byte(`{"tokens":[{"name":"web"}]}`), 0600))
+	_, err := Load(filename)
+	assert.ErrorContains(t, err, `token "web" is empty`)
+}
+
+func testConfig() *Config {
+	return &Config{
+		Tokens: []Token{
+			{Name: "tui", Token: "secret"},
+			{Name: "web", Token: "limited", Models: []string{"ollama/*", "gpt-4o"}},
+		},
+		CORS: CORS{AllowedOrigins: []string{"http://localhost:3000"}},
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var seen context.Context
+	routes := testConfig().Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Context()
+		w.WriteHeader(http.StatusNoContent)
+	}), true, "/healthz")
+
+	serve := func(path, authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("/models", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Header().Get("WWW-Authenticate"), "Bearer")
+	assert.Equal(t, http.StatusUnauthorized, serve("/models", "Bearer wrong").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve("/models", "secret").Code)
+	assert.Equal(t, http.StatusNoContent, serve("/healthz", "").Code)
+
+	assert.Equal(t, http.StatusNoContent, serve("/models", "Bearer secret").Code)
+	assert.True(t, Unrestricted(seen))
+	assert.Equal(t, "secret", Owner(seen))
+
+	assert.Equal(t, http.StatusNoContent, serve("/models", "Bearer limited").Code)
+	assert.False(t, Unrestricted(seen))
+	assert.Equal(t, "limited", Owner(seen))
+	assert.True(t, AllowsModel(seen, "ollama", "llama3:latest"))
+	assert.True(t, AllowsModel(seen, "openai", "gpt-4o"))
+	assert.False(t, AllowsModel(seen, "openai", "gpt-4o-mini"))
+}
+
+func TestMiddlewareCORS(t *testing.T) {
+	routes := testConfig().Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), true)
+
+	preflight := httptest.NewRequest(http.MethodOptions, "/chat", nil)
+	preflight.Header.Set("Origin", "http://localhost:3000")
+	rec := httptest.NewRecorder()
+	routes.ServeHTTP(rec, preflight)
+	assert.Equal(t, http.StatusNoContent, rec.Code, "preflight requests carry no token")
+	assert.Equal(t, "http://localhost:3000", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, rec.Header().Get("Access-Control-Allow-Headers"), "Authorization")
+	assert.Contains(t, rec.Header().Get("Access-Control-Allow-Methods"), "DELETE", "sessions can be deleted")
+
+	req := httptest.NewRequest(http.MethodPost, "/chat", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	routes.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "http://localhost:3000", rec.Header().Get("Access-Control-Allow-Origin"))
+
+	req = httptest.NewRequest(http.MethodPost, "/chat", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	routes.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestMiddlewareWithoutAuth(t *testing.T) {
+	routes := testConfig().Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, Unrestricted(r.Context()))
+		w.WriteHeader(http.StatusNoContent)
+	}), false)
+
+	rec := httptest.NewRecorder()
+	routes.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/models", nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func TestRequireUnrestricted(t *testing.T) {
+	routes := testConfig().Middleware(RequireUnrestricted(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), true)
+
+	for token, want := range map[string]int{"secret": http.StatusNoContent, "limited": http.StatusForbidden} {
+		req := httptest.NewRequest(http.MethodPost, "/models/pull", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, req)
+		assert.Equal(t, want, rec.Code, token)
+	}
+}
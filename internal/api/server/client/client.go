@@ -11,7 +11,9 @@ const (
 	RoleAssistant = "assistant"
 )
 
-var CacheModels = make(map[string]string)
+// Transport carries every provider request; nil uses http.DefaultTransport.
+// It must be set before the clients are created.
+var Transport http.RoundTripper
 
 // Client represents a client for the API
 type Client struct {
@@ -34,7 +36,7 @@ func NewClient(config ClientConfig) *Client {
 	baseURL := &url.URL{Scheme: config.Scheme, Host: config.Host}
 	return &Client{
 		base:      baseURL,
-		http:      &http.Client{},
+		http:      &http.Client{Transport: Transport},
 		modelsUrl: baseURL.ResolveReference(&url.URL{Path: config.ModelsPath}),
 		chatUrl:   baseURL.ResolveReference(&url.URL{Path: config.ChatPath}),
 	}
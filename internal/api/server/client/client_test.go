@@ -0,0 +1,20 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChatMalformedURL(t *testing.T) {
+	config := ClientConfig{Scheme: "http", Host: "localhost:notaport", ChatPath: "/chat"}
+	req := &ServerChatRequest{Model: "llama3", Messages: []ServerChatMessage{{Role: RoleUser, Content: "hi"}}}
+	noop := func([]byte) error { return nil }
+
+	ollama := &OllamaClient{Client: *NewClient(config)}
+	assert.Error(t, ollama.Chat(context.Background(), req, noop))
+
+	openai := &OpenAIClient{Client: *NewClient(config), apiKey: "key"}
+	assert.Error(t, openai.Chat(context.Background(), req, noop))
+}
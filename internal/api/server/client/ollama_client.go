@@ -10,6 +10,7 @@ import (
 	"github.com/bz888/blab/internal/logger"
 	"io"
 	"net/http"
+	"net/url"
 	"time"
 )
 
@@ -21,6 +22,8 @@ type OllamaClient struct {
 type OllamaClientInterface interface {
 	GetModels() ([]OllamaModel, error)
 	Chat(ctx context.Context, req *ServerChatRequest, fn func([]byte) error) error
+	Pull(ctx context.Context, name string, fn func(PullProgress) error) error
+	Delete(ctx context.Context, name string) error
 }
 
 var ollamaConfig = ClientConfig{
@@ -30,6 +33,11 @@ var ollamaConfig = ClientConfig{
 	ChatPath:   "/api/chat",
 }
 
+const (
+	ollamaPullPath   = "/api/pull"
+	ollamaDeletePath = "/api/delete"
+)
+
 // NewOllamaClient creates a new Ollama API client
 func NewOllamaClient() OllamaClientInterface {
 	return &OllamaClient{
@@ -106,17 +114,9 @@ func (c *OllamaClient) GetModels() ([]OllamaModel, error) {
 		return nil, err
 	}
 
-	AddOllamaModelCache(response.Models)
-
 	return response.Models, nil
 }
 
-func AddOllamaModelCache(ollamaModels []OllamaModel) {
-	for _, model := range ollamaModels {
-		CacheModels[model.Name] = "ollama"
-	}
-}
-
 func (c *OllamaClient) Chat(ctx context.Context, req *ServerChatRequest, fn func([]byte) error) error {
 	return c.stream(ctx, req, fn)
 }
@@ -134,8 +134,7 @@ func (c *OllamaClient) stream(ctx context.Context, data *ServerChatRequest, fn f
 
 	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.GetChatURL(), buf)
 	if err != nil {
-		localLogger.Error("Failed to request on ollama chat:", err)
-		localLogger.Error("failed request", request)
+		localLogger.Error("Failed to request on ollama chat", "err", err, "url", c.GetChatURL())
 		return err
 	}
 
@@ -160,6 +159,89 @@ func (c *OllamaClient) stream(ctx context.Context, data *ServerChatRequest, fn f
 	return nil
 }
 
+// Pull downloads a model, calling fn with each progress update Ollama sends.
+func (c *OllamaClient) Pull(ctx context.Context, name string, fn func(PullProgress) error) error {
+	localLogger := logger.NewLogger("ollama pull")
+	bts, err := json.Marshal(map[string]any{"name": name, "stream": true})
+	if err != nil {
+		return err
+	}
+
+	pullURL := c.base.ResolveReference(&url.URL{Path: ollamaPullPath})
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, pullURL.String(), bytes.NewReader(bts))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/x-ndjson")
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return ollamaError(response)
+	}
+
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		var progress PullProgress
+		if err := json.Unmarshal(scanner.Bytes(), &progress); err != nil {
+			localLogger.Error("Failed to unmarshal pull progress", "err", err, "data", scanner.Text())
+			return err
+		}
+		if progress.Error != "" {
+			return errors.New(progress.Error)
+		}
+		if err := fn(progress); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("scanner error: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a local model.
+func (c *OllamaClient) Delete(ctx context.Context, name string) error {
+	bts, err := json.Marshal(map[string]string{"name": name})
+	if err != nil {
+		return err
+	}
+
+	deleteURL := c.base.ResolveReference(&url.URL{Path: ollamaDeletePath})
+	request, err := http.NewRequestWithContext(ctx, http.MethodDelete, deleteURL.String(), bytes.NewReader(bts))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return ollamaError(response)
+	}
+	return nil
+}
+
+// ollamaError reads the message from an Ollama error response.
+func ollamaError(response *http.Response) error {
+	var errResp struct {
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&errResp); err != nil || errResp.Error == "" {
+		return errors.New("failed request: " + response.Status)
+	}
+	return errors.New(errResp.Error)
+}
+
 // UnmarshalJSON handles the custom unmarshalling for Families.
 func (f *Families) UnmarshalJSON(data []byte) error {
 	// If the JSON data is "null", return an empty Families slice.
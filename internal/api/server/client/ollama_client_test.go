@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestOllamaClient(t *testing.T, handler http.HandlerFunc) *OllamaClient {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	config := ollamaConfig
+	config.Host = u.Host
+	return &OllamaClient{Client: *NewClient(config)}
+}
+
+func TestOllamaPull(t *testing.T) {
+	c := newTestOllamaClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, ollamaPullPath, r.URL.Path)
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "llama3", body["name"])
+
+		io.WriteString(w, `{"status":"pulling manifest"}`+"\n")
+		io.WriteString(w, `{"status":"downloading","digest":"sha256:1","total":100,"completed":40}`+"\n")
+		io.WriteString(w, `{"status":"success"}`+"\n")
+	})
+
+	var progress []PullProgress
+	err := c.Pull(context.Background(), "llama3", func(p PullProgress) error {
+		progress = append(progress, p)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []PullProgress{
+		{Status: "pulling manifest"},
+		{Status: "downloading", Digest: "sha256:1", Total: 100, Completed: 40},
+		{Status: "success"},
+	}, progress)
+}
+
+func TestOllamaPullError(t *testing.T) {
+	c := newTestOllamaClient(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"status":"pulling manifest"}`+"\n")
+		io.WriteString(w, `{"error":"pull model manifest: file does not exist"}`+"\n")
+	})
+
+	err := c.Pull(context.Background(), "nope", func(PullProgress) error { return nil })
+	assert.EqualError(t, err, "pull model manifest: file does not exist")
+}
+
+func TestOllamaDelete(t *testing.T) {
+	c := newTestOllamaClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodDelete, r.Method)
+		assert.Equal(t, ollamaDeletePath, r.URL.Path)
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		if body["name"] != "llama3" {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"error":"model 'missing' not found"}`)
+		}
+	})
+
+	require.NoError(t, c.Delete(context.Background(), "llama3"))
+
+	assert.EqualError(t, c.Delete(context.Background(), "missing"), "model 'missing' not found")
+}
@@ -10,12 +10,12 @@ import (
 	"github.com/bz888/blab/internal/logger"
 	"io"
 	"net/http"
-	"os"
 )
 
 // OpenAIClient represents a client for the OpenAI API
 type OpenAIClient struct {
 	Client
+	apiKey string
 }
 
 type OpenAIClientInterface interface {
@@ -30,10 +30,11 @@ var openAIConfig = ClientConfig{
 	ChatPath:   "/v1/chat/completions",
 }
 
-// NewOpenAIClient creates a new OpenAI API client
-func NewOpenAIClient() OpenAIClientInterface {
+// NewOpenAIClient creates a new OpenAI API client authenticating with apiKey
+func NewOpenAIClient(apiKey string) OpenAIClientInterface {
 	return &OpenAIClient{
 		Client: *NewClient(openAIConfig),
+		apiKey: apiKey,
 	}
 }
 
@@ -80,12 +81,11 @@ func (c *OpenAIClient) GetModels() ([]OpenAIModel, error) {
 	requestURL := c.GetModelsURL()
 
 	req, err := http.NewRequest(http.MethodGet, requestURL, nil)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+os.Getenv("OPENAI_API_KEY"))
-
 	if err != nil {
 		return nil, err
 	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
 
 	resp, err := c.http.Do(req)
 	if err != nil {
@@ -107,20 +107,9 @@ func (c *OpenAIClient) GetModels() ([]OpenAIModel, error) {
 		return nil, err
 	}
 
-	AddOpenAIModelCache(response.Data)
-
 	return response.Data, nil
 }
 
-func AddOpenAIModelCache(openAIModels []OpenAIModel) {
-	for _, model := range openAIModels {
-		// todo tidy up
-		//if model.OwnedBy == "openai" {
-		CacheModels[model.ID] = "openai"
-		//}
-	}
-}
-
 // Chat makes a chat request to the OpenAI API
 func (c *OpenAIClient) Chat(ctx context.Context, req *ServerChatRequest, fn func([]byte) error) error {
 	return c.stream(ctx, req, fn)
@@ -140,13 +129,12 @@ func (c *OpenAIClient) stream(ctx context.Context, data *ServerChatRequest, fn f
 
 	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.GetChatURL(), buf)
 	if err != nil {
-		localLogger.Error("Failed to request on ollama chat:", err)
-		localLogger.Error("failed request", request)
+		localLogger.Error("Failed to request on openai chat", "err", err, "url", c.GetChatURL())
 		return err
 	}
 
 	request.Header.Set("Content-Type", "application/json")
-	request.Header.Set("Authorization", "Bearer "+os.Getenv("OPENAI_API_KEY"))
+	request.Header.Set("Authorization", "Bearer "+c.apiKey)
 
 	response, err := c.http.Do(request)
 	if err != nil {
@@ -157,7 +145,7 @@ func (c *OpenAIClient) stream(ctx context.Context, data *ServerChatRequest, fn f
 	if response.StatusCode != http.StatusOK {
 		var errResp map[string]interface{}
 		if err := json.NewDecoder(response.Body).Decode(&errResp); err != nil {
-			localLogger.Error("Failed to decode error response:", err)
+			localLogger.Error("Failed to decode error response", "err", err)
 			return fmt.Errorf("received non-200 response: %d, failed to decode error message", response.StatusCode)
 		}
 
@@ -167,7 +155,7 @@ func (c *OpenAIClient) stream(ctx context.Context, data *ServerChatRequest, fn f
 				errorMessage = message
 			}
 		}
-		localLogger.Error("Received error response:", errorMessage)
+		localLogger.Error("Received error response", "status", response.StatusCode, "message", errorMessage)
 		return fmt.Errorf("received non-200 response: %d, error: %s", response.StatusCode, errorMessage)
 	}
 
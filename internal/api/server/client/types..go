@@ -1,14 +1,24 @@
 package client
 
+import "time"
+
 // ChatRequest ClientRequest Request from client
 type ChatRequest struct {
 	Text  string `json:"text"`
 	Model string `json:"model"`
+	// Language, a BCP 47 tag, asks the model to reply in that language.
+	Language string `json:"language,omitempty"`
+	// Session is the conversation the message belongs to, the default
+	// session when empty.
+	Session string `json:"session,omitempty"`
 }
 
 // ChatResponse ClientResponse Response to client
 type ChatResponse struct {
 	ProcessedText string `json:"processedText"`
+	// Queued is the request's position in the provider's queue while it
+	// waits for its turn, 1 being next. It is zero once the reply streams.
+	Queued int `json:"queued,omitempty"`
 }
 
 type ServerChatMessage struct {
@@ -21,3 +31,38 @@ type ServerChatRequest struct {
 	Messages []ServerChatMessage `json:"messages"`
 	Stream   bool                `json:"stream"` // Always true for streaming
 }
+
+// ModelInfo describes a model for the model browser. Fields a provider does
+// not report are left empty.
+type ModelInfo struct {
+	// ID is the provider-qualified name, e.g. "ollama/llama3:latest".
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	Provider      string    `json:"provider"`
+	Family        string    `json:"family,omitempty"`
+	ParameterSize string    `json:"parameterSize,omitempty"`
+	Quantization  string    `json:"quantization,omitempty"`
+	Size          int64     `json:"size,omitempty"`
+	ModifiedAt    time.Time `json:"modifiedAt,omitempty"`
+}
+
+// ModelRequest names the model to pull or delete.
+type ModelRequest struct {
+	Name string `json:"name"`
+}
+
+// ProviderRequest sets the API key of a provider, or asks for it to be
+// probed now when it needs none.
+type ProviderRequest struct {
+	Provider string `json:"provider"`
+	APIKey   string `json:"api_key,omitempty"`
+}
+
+// PullProgress is one status update while a model is pulled.
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
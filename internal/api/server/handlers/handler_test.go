@@ -2,12 +2,19 @@ package handlers
 
 import (
 	"context"
-	"log"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/bz888/blab/internal/api/server/auth"
 	"github.com/bz888/blab/internal/api/server/client"
+	"github.com/bz888/blab/internal/api/server/limit"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type MockOpenAIClient struct {
@@ -19,15 +26,34 @@ func (m *MockOpenAIClient) GetModels() ([]client.OpenAIModel, error) {
 	return args.Get(0).([]client.OpenAIModel), args.Error(1)
 }
 
-func (m *MockOpenAIClient) Chat(ctx context.Context, req *client.OpenAIChatRequest, fn func([]byte) error) error {
+func (m *MockOpenAIClient) Chat(ctx context.Context, req *client.ServerChatRequest, fn func([]byte) error) error {
 	return nil
 }
 
-func TestProcessOpenAiModels(t *testing.T) {
+type MockOllamaClient struct {
+	mock.Mock
+}
+
+func (m *MockOllamaClient) GetModels() ([]client.OllamaModel, error) {
+	args := m.Called()
+	return args.Get(0).([]client.OllamaModel), args.Error(1)
+}
+
+func (m *MockOllamaClient) Chat(ctx context.Context, req *client.ServerChatRequest, fn func([]byte) error) error {
+	return m.Called(req.Model).Error(0)
+}
+
+func (m *MockOllamaClient) Pull(ctx context.Context, name string, fn func(client.PullProgress) error) error {
+	return m.Called(name).Error(0)
+}
+
+func (m *MockOllamaClient) Delete(ctx context.Context, name string) error {
+	return m.Called(name).Error(0)
+}
+
+func TestListOpenAIModels(t *testing.T) {
 	mockOpenAIClient := new(MockOpenAIClient)
-	handler := &Handler{
-		openAIClient: mockOpenAIClient,
-	}
+	handler := NewHandler(mockOpenAIClient, nil)
 
 	mockModels := []client.OpenAIModel{
 		{ID: "dall-e-3", OwnedBy: "system"},
@@ -52,32 +78,273 @@ func TestProcessOpenAiModels(t *testing.T) {
 		{ID: "text-embedding-ada-002", OwnedBy: "openai-internal"},
 		{ID: "gpt-3.5-turbo-16k-0613", OwnedBy: "openai"},
 	}
+	mockOpenAIClient.On("GetModels").Return(mockModels, nil).Once()
 
-	client.CacheModels = make(map[string]string)
+	expectedModelNames := []string{"openai/gpt-3.5-turbo-0301", "openai/gpt-3.5-turbo", "openai/gpt-3.5-turbo-0613", "openai/gpt-3.5-turbo-16k-0613"}
 
-	mockOpenAIClient.On("GetModels").Return(mockModels, nil)
-	client.AddOpenAIModelCache(mockModels)
+	rec := httptest.NewRecorder()
+	handler.ModelHandler(rec, httptest.NewRequest(http.MethodGet, "/models", nil))
+	var modelNames []string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &modelNames))
+	assert.ElementsMatch(t, expectedModelNames, modelNames, "The model names should match the expected ones")
 
-	modelNames := handler.processOpenAiModels()
+	// A second request within the TTL is served from the registry.
+	rec = httptest.NewRecorder()
+	handler.ModelHandler(rec, httptest.NewRequest(http.MethodGet, "/models", nil))
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &modelNames))
+	assert.ElementsMatch(t, expectedModelNames, modelNames)
+	mockOpenAIClient.AssertNumberOfCalls(t, "GetModels", 1)
+}
 
-	log.Println(client.CacheModels)
+func TestProcessTextHandlerModelCollision(t *testing.T) {
+	mockOpenAIClient := new(MockOpenAIClient)
+	mockOpenAIClient.On("GetModels").Return([]client.OpenAIModel{{ID: "llama3", OwnedBy: "openai"}}, nil)
+	mockOllamaClient := new(MockOllamaClient)
+	mockOllamaClient.On("GetModels").Return([]client.OllamaModel{{Name: "llama3"}}, nil)
+	handler := NewHandler(mockOpenAIClient, mockOllamaClient)
 
-	expectedModelNames := []string{"gpt-3.5-turbo-0301", "gpt-3.5-turbo", "gpt-3.5-turbo-0613", "gpt-3.5-turbo-16k-0613"}
+	rec := httptest.NewRecorder()
+	handler.ProcessTextHandler(rec, httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(`{"model":"llama3","text":"hi"}`)))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), `model "llama3" is offered by ollama and openai, use ollama/llama3 or openai/llama3`)
 
-	assert.ElementsMatch(t, expectedModelNames, modelNames, "The model names should match the expected ones")
-	assert.Equal(t, "openai", client.CacheModels["gpt-3.5-turbo-0301"])
-	assert.Equal(t, "openai", client.CacheModels["gpt-3.5-turbo"])
-	assert.Equal(t, "openai", client.CacheModels["gpt-3.5-turbo-0613"])
-	assert.Equal(t, "openai", client.CacheModels["gpt-3.5-turbo-16k-0613"])
-
-	client.CacheModels = map[string]string{
-		"gpt-3.5-turbo-0301":     "openai",
-		"gpt-3.5-turbo":          "openai",
-		"gpt-3.5-turbo-0613":     "openai",
-		"gpt-3.5-turbo-16k-0613": "openai",
+	rec = httptest.NewRecorder()
+	handler.ProcessTextHandler(rec, httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(`{"model":"mistral","text":"hi"}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestProcessTextHandlerQualifiedModel(t *testing.T) {
+	mockOpenAIClient := new(MockOpenAIClient)
+	mockOpenAIClient.On("GetModels").Return([]client.OpenAIModel{{ID: "llama3", OwnedBy: "openai"}}, nil)
+	mockOllamaClient := new(MockOllamaClient)
+	mockOllamaClient.On("GetModels").Return([]client.OllamaModel{{Name: "llama3"}, {Name: "library/phi3"}}, nil)
+	handler := NewHandler(mockOpenAIClient, mockOllamaClient)
+
+	// The provider is sent its own name for the model.
+	mockOllamaClient.On("Chat", "llama3").Return(nil).Once()
+	rec := httptest.NewRecorder()
+	handler.ProcessTextHandler(rec, httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(`{"model":"ollama/llama3","text":"hi"}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// Bare names with a slash still resolve when unambiguous.
+	mockOllamaClient.On("Chat", "library/phi3").Return(nil).Once()
+	rec = httptest.NewRecorder()
+	handler.ProcessTextHandler(rec, httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(`{"model":"library/phi3","text":"hi"}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	mockOllamaClient.AssertExpectations(t)
+}
+
+func TestRefreshModelsHandler(t *testing.T) {
+	mockOllamaClient := new(MockOllamaClient)
+	mockOllamaClient.On("GetModels").Return([]client.OllamaModel{{Name: "llama3"}}, nil).Once()
+	mockOllamaClient.On("GetModels").Return([]client.OllamaModel{{Name: "llama3"}, {Name: "mistral"}}, nil).Once()
+	handler := NewHandler(nil, mockOllamaClient)
+
+	rec := httptest.NewRecorder()
+	handler.ModelHandler(rec, httptest.NewRequest(http.MethodGet, "/models", nil))
+	assert.JSONEq(t, `["ollama/llama3"]`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	handler.RefreshModelsHandler(rec, httptest.NewRequest(http.MethodPost, "/models/refresh", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var models []client.ModelInfo
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &models))
+	assert.Len(t, models, 2)
+
+	rec = httptest.NewRecorder()
+	handler.RefreshModelsHandler(rec, httptest.NewRequest(http.MethodGet, "/models/refresh", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestAttachProviderLater(t *testing.T) {
+	handler := NewHandler(nil, nil)
+
+	rec := httptest.NewRecorder()
+	handler.ModelHandler(rec, httptest.NewRequest(http.MethodGet, "/models", nil))
+	assert.JSONEq(t, `[]`, rec.Body.String(), "a server without providers still answers")
+
+	rec = httptest.NewRecorder()
+	handler.PullModelHandler(rec, httptest.NewRequest(http.MethodPost, "/models/pull", strings.NewReader(`{"name":"llama3"}`)))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	mockOllamaClient := new(MockOllamaClient)
+	mockOllamaClient.On("GetModels").Return([]client.OllamaModel{{Name: "llama3"}}, nil)
+	handler.AttachOllama(mockOllamaClient)
+
+	rec = httptest.NewRecorder()
+	handler.ModelHandler(rec, httptest.NewRequest(http.MethodGet, "/models", nil))
+	assert.JSONEq(t, `["ollama/llama3"]`, rec.Body.String())
+}
+
+func TestModelAllowlist(t *testing.T) {
+	mockOllamaClient := new(MockOllamaClient)
+	mockOllamaClient.On("GetModels").Return([]client.OllamaModel{{Name: "llama3"}, {Name: "mistral"}}, nil)
+	handler := NewHandler(nil, mockOllamaClient)
+
+	tokens := &auth.Config{Tokens: []auth.Token{{Name: "web", Token: "limited", Models: []string{"ollama/llama3"}}}}
+	routes := tokens.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/models":
+			handler.ModelHandler(w, r)
+		case "/chat":
+			handler.ProcessTextHandler(w, r)
+		}
+	}), true)
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer limited")
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, req)
+		return rec
 	}
 
-	modelNames = handler.processOpenAiModels()
+	assert.JSONEq(t, `["ollama/llama3"]`, serve(http.MethodGet, "/models", "").Body.String())
 
-	assert.ElementsMatch(t, expectedModelNames, modelNames, "The model names should match the expected ones")
+	rec := serve(http.MethodPost, "/chat", `{"model":"mistral","text":"hi"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "ollama/mistral")
+	mockOllamaClient.AssertNotCalled(t, "Chat", mock.Anything)
+}
+
+func TestProcessTextHandlerLimits(t *testing.T) {
+	mockOllamaClient := new(MockOllamaClient)
+	mockOllamaClient.On("GetModels").Return([]client.OllamaModel{{Name: "llama3"}}, nil)
+	handler := NewHandler(nil, mockOllamaClient)
+	handler.SetLimit("ollama", limit.New(limit.Config{Concurrency: 1, Queue: 1}))
+
+	busy, finish := make(chan struct{}), make(chan struct{})
+	mockOllamaClient.On("Chat", "llama3").Run(func(mock.Arguments) {
+		close(busy)
+		<-finish
+	}).Return(nil).Once()
+	mockOllamaClient.On("Chat", "llama3").Return(nil).Once()
+
+	server := httptest.NewServer(http.HandlerFunc(handler.ProcessTextHandler))
+	defer server.Close()
+	chat := func() *http.Response {
+		resp, err := http.Post(server.URL, "application/json", strings.NewReader(`{"model":"llama3","text":"hi"}`))
+		require.NoError(t, err)
+		return resp
+	}
+
+	first := make(chan *http.Response)
+	go func() { first <- chat() }()
+	<-busy
+
+	// The second request waits, and is told where it stands.
+	second := chat()
+	defer second.Body.Close()
+	assert.Equal(t, http.StatusOK, second.StatusCode)
+	var queued client.ChatResponse
+	require.NoError(t, json.NewDecoder(second.Body).Decode(&queued))
+	assert.Equal(t, 1, queued.Queued)
+
+	// The third finds the queue full.
+	third := chat()
+	third.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, third.StatusCode)
+	assert.Equal(t, "1", third.Header.Get("Retry-After"))
+
+	close(finish)
+	(<-first).Body.Close()
+	_, err := io.ReadAll(second.Body)
+	require.NoError(t, err)
+	mockOllamaClient.AssertExpectations(t)
+}
+
+// replyingOllama answers every chat with reply, recording what it was sent.
+type replyingOllama struct {
+	MockOllamaClient
+	reply string
+	sent  [][]client.ServerChatMessage
+}
+
+func (m *replyingOllama) Chat(ctx context.Context, req *client.ServerChatRequest, fn func([]byte) error) error {
+	m.sent = append(m.sent, req.Messages)
+	bts, _ := json.Marshal(client.OllamaAPIResponse{Message: client.ServerChatMessage{Role: client.RoleAssistant, Content: m.reply}, Done: true})
+	return fn(bts)
+}
+
+func TestSessions(t *testing.T) {
+	ollama := &replyingOllama{reply: "hello"}
+	ollama.On("GetModels").Return([]client.OllamaModel{{Name: "llama3"}}, nil)
+	handler := NewHandler(nil, ollama)
+
+	rec := httptest.NewRecorder()
+	handler.SessionsHandler(rec, httptest.NewRequest(http.MethodPost, "/sessions", nil))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var created struct{ ID string }
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+
+	chat := func(body string) int {
+		rec := httptest.NewRecorder()
+		handler.ProcessTextHandler(rec, httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(body)))
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusOK, chat(`{"model":"llama3","text":"hi","session":"`+created.ID+`"}`))
+	assert.Equal(t, http.StatusOK, chat(`{"model":"llama3","text":"again","session":"`+created.ID+`"}`))
+	assert.Equal(t, http.StatusOK, chat(`{"model":"llama3","text":"elsewhere"}`))
+	assert.Equal(t, http.StatusNotFound, chat(`{"model":"llama3","text":"hi","session":"missing"}`))
+
+	require.Len(t, ollama.sent, 3)
+	assert.Equal(t, []client.ServerChatMessage{
+		{Role: client.RoleUser, Content: "hi"},
+		{Role: client.RoleAssistant, Content: "hello"},
+		{Role: client.RoleUser, Content: "again"},
+	}, ollama.sent[1], "the session's history is sent along")
+	assert.Len(t, ollama.sent[2], 1, "the default session is separate")
+
+	rec = httptest.NewRecorder()
+	handler.SessionHandler(rec, httptest.NewRequest(http.MethodGet, "/sessions/"+created.ID, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"content":"again"`)
+
+	rec = httptest.NewRecorder()
+	handler.SessionHandler(rec, httptest.NewRequest(http.MethodDelete, "/sessions/"+created.ID, nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = httptest.NewRecorder()
+	handler.SessionHandler(rec, httptest.NewRequest(http.MethodGet, "/sessions/"+created.ID, nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestSessionsPerToken(t *testing.T) {
+	ollama := &replyingOllama{reply: "hello"}
+	ollama.On("GetModels").Return([]client.OllamaModel{{Name: "llama3"}}, nil)
+	handler := NewHandler(nil, ollama)
+
+	tokens := &auth.Config{Tokens: []auth.Token{{Name: "tui", Token: "secret"}, {Name: "web", Token: "limited", Models: []string{"ollama/*"}}}}
+	routes := tokens.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/chat":
+			handler.ProcessTextHandler(w, r)
+		case r.URL.Path == "/sessions":
+			handler.SessionsHandler(w, r)
+		default:
+			handler.SessionHandler(w, r)
+		}
+	}), true)
+	serve := func(token, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("secret", http.MethodPost, "/sessions", "")
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var created struct{ ID string }
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	require.Equal(t, http.StatusOK, serve("secret", http.MethodPost, "/chat", `{"model":"llama3","text":"private"}`).Code)
+
+	assert.JSONEq(t, `[]`, serve("limited", http.MethodGet, "/sessions", "").Body.String())
+	assert.Equal(t, http.StatusNotFound, serve("limited", http.MethodGet, "/sessions/"+created.ID, "").Code)
+	assert.Equal(t, http.StatusNotFound, serve("limited", http.MethodDelete, "/sessions/"+created.ID, "").Code)
+	assert.Equal(t, http.StatusNotFound, serve("limited", http.MethodPost, "/chat", `{"model":"llama3","text":"hi","session":"`+created.ID+`"}`).Code)
+
+	// Without a session id, each token talks in its own default session.
+	require.Equal(t, http.StatusOK, serve("limited", http.MethodPost, "/chat", `{"model":"llama3","text":"hi"}`).Code)
+	assert.Equal(t, []client.ServerChatMessage{{Role: client.RoleUser, Content: "hi"}}, ollama.sent[len(ollama.sent)-1])
+	assert.Equal(t, http.StatusOK, serve("secret", http.MethodGet, "/sessions/"+created.ID, "").Code)
 }
@@ -1,25 +1,101 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/bz888/blab/internal/api/server/auth"
 	"github.com/bz888/blab/internal/api/server/client"
+	"github.com/bz888/blab/internal/api/server/limit"
+	"github.com/bz888/blab/internal/api/server/registry"
+	"github.com/bz888/blab/internal/api/server/session"
 	"github.com/bz888/blab/internal/logger"
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+	"math"
 	"net/http"
+	"strconv"
 	"sync"
+	"time"
+)
+
+// ModelTTL is how long a provider's model list is used before it is
+// fetched again.
+const ModelTTL = 5 * time.Minute
+
+// SessionTTL is how long a chat session is kept after its last message, and
+// MaxSessions how many are kept at once.
+const (
+	SessionTTL  = 24 * time.Hour
+	MaxSessions = 1000
 )
 
 type Handler struct {
+	// mu guards the clients, which are attached as providers come online.
+	mu           sync.RWMutex
 	openAIClient client.OpenAIClientInterface
 	ollamaClient client.OllamaClientInterface
+	limits       map[string]*limit.Limiter
+	models       *registry.Registry
+	sessions     *session.Store
 }
 
-var ChatHistory = make([]client.ServerChatMessage, 0)
-
+// NewHandler serves the given clients, either of which may be nil until its
+// provider is attached.
 func NewHandler(openAIClient client.OpenAIClientInterface, ollamaClient client.OllamaClientInterface) *Handler {
-	return &Handler{
-		openAIClient: openAIClient,
-		ollamaClient: ollamaClient,
+	h := &Handler{models: registry.New(ModelTTL), limits: map[string]*limit.Limiter{}, sessions: session.NewStore(MaxSessions, SessionTTL)}
+	if ollamaClient != nil {
+		h.AttachOllama(ollamaClient)
 	}
+	if openAIClient != nil {
+		h.AttachOpenAI(openAIClient)
+	}
+	return h
+}
+
+// AttachOllama starts serving Ollama's models through c, replacing any
+// client it was served through.
+func (h *Handler) AttachOllama(c client.OllamaClientInterface) {
+	h.mu.Lock()
+	h.ollamaClient = c
+	h.mu.Unlock()
+	h.models.Register("ollama", h.listOllamaModels)
+}
+
+// AttachOpenAI starts serving OpenAI's models through c, replacing any
+// client it was served through.
+func (h *Handler) AttachOpenAI(c client.OpenAIClientInterface) {
+	h.mu.Lock()
+	h.openAIClient = c
+	h.mu.Unlock()
+	h.models.Register("openai", h.listOpenAIModels)
+}
+
+// SetLimit has chat requests to a provider wait for limiter. Providers
+// without one are not limited.
+func (h *Handler) SetLimit(provider string, limiter *limit.Limiter) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.limits[provider] = limiter
+}
+
+func (h *Handler) limiter(provider string) *limit.Limiter {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.limits[provider]
+}
+
+func (h *Handler) ollama() client.OllamaClientInterface {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.ollamaClient
+}
+
+func (h *Handler) openAI() client.OpenAIClientInterface {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.openAIClient
 }
 
 func (h *Handler) ProcessTextHandler(w http.ResponseWriter, r *http.Request) {
@@ -32,56 +108,94 @@ func (h *Handler) ProcessTextHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
-	clientType, ok := client.CacheModels[clientReq.Model]
+	model, err := h.models.Lookup(r.Context(), clientReq.Model)
+	var collision *registry.CollisionError
+	switch {
+	case errors.As(err, &collision):
+		localLogger.Error("Model name collision", "model", clientReq.Model, "providers", collision.Providers)
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		localLogger.Error("Model not found", "model", clientReq.Model)
+		http.Error(w, "Model not found", http.StatusBadRequest)
+		return
+	}
+	if !auth.AllowsModel(r.Context(), model.Provider, model.Name) {
+		localLogger.Warn("Model not allowed for token", "model", model.ID)
+		http.Error(w, "This token may not use "+model.ID, http.StatusForbidden)
+		return
+	}
+	// Providers only know their own, unqualified names.
+	clientReq.Model = model.Name
 
+	current, err := h.sessions.Get(auth.Owner(r.Context()), clientReq.Session)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	question := client.ServerChatMessage{Role: client.RoleUser, Content: clientReq.Text}
+	history := append(current.Messages, question)
+
+	release, ok := h.waitForTurn(w, r, model.Provider)
 	if !ok {
-		localLogger.Error("Model not found", http.StatusBadRequest)
-		http.Error(w, "Model not found", http.StatusBadRequest)
 		return
 	}
-	// build client request
+	defer release()
 
-	if clientType == "openai" {
-		h.processWithOpenAIClient(w, r, clientReq, &ChatHistory)
-	} else if clientType == "ollama" {
-		h.processWithOllamaClient(w, r, clientReq, &ChatHistory)
+	var reply string
+	if model.Provider == "openai" {
+		reply, ok = h.processWithOpenAIClient(w, r, clientReq, history)
+	} else if model.Provider == "ollama" {
+		reply, ok = h.processWithOllamaClient(w, r, clientReq, history)
 	} else {
 		http.Error(w, "Unknown client type", http.StatusInternalServerError)
+		return
+	}
+	// A failed exchange is left out of the session, so it can be retried.
+	if !ok {
+		return
+	}
+	answer := client.ServerChatMessage{Role: client.RoleAssistant, Content: reply}
+	if _, err := h.sessions.Append(auth.Owner(r.Context()), current.ID, question, answer); err != nil {
+		localLogger.Warn("Session ended during chat", "session", current.ID, "err", err)
 	}
 }
 
-func (h *Handler) ModelHandler(w http.ResponseWriter, r *http.Request) {
-	var wg sync.WaitGroup
-	models := make([]string, 0)
-	modelsChan := make(chan []string, 2)
-	errChan := make(chan error, 2)
-
-	if h.ollamaClient != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			ollamaModels := h.processOllamaModels()
-			modelsChan <- ollamaModels
-		}()
+// waitForTurn holds a chat request until the provider's limits let it
+// through, streaming its queue position meanwhile. It answers the request
+// itself with 429 when the queue is full.
+func (h *Handler) waitForTurn(w http.ResponseWriter, r *http.Request, provider string) (func(), bool) {
+	limiter := h.limiter(provider)
+	if limiter == nil {
+		return func() {}, true
 	}
 
-	if h.openAIClient != nil {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			openAIModels := h.processOpenAiModels()
-			modelsChan <- openAIModels
-		}()
+	encoder := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	release, err := limiter.Acquire(r.Context(), func(position int) {
+		w.Header().Set("Content-Type", "application/json")
+		encoder.Encode(client.ChatResponse{Queued: position})
+		if flusher != nil {
+			flusher.Flush()
+		}
+	})
+	switch {
+	case errors.Is(err, limit.ErrQueueFull):
+		retryAfter := int(math.Ceil(limiter.RetryAfter().Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		http.Error(w, provider+" is busy, try again later", http.StatusTooManyRequests)
+		return nil, false
+	case err != nil:
+		// The client gave up while waiting.
+		return nil, false
 	}
+	return release, true
+}
 
-	go func() {
-		wg.Wait()
-		close(modelsChan)
-		close(errChan)
-	}()
-
-	for modelList := range modelsChan {
-		models = append(models, modelList...)
+func (h *Handler) ModelHandler(w http.ResponseWriter, r *http.Request) {
+	models := make([]string, 0)
+	for _, model := range h.allowedModels(r.Context()) {
+		models = append(models, model.ID)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -89,3 +203,42 @@ func (h *Handler) ModelHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to encode response: "+err.Error(), http.StatusInternalServerError)
 	}
 }
+
+// RefreshModels fetches every provider's models now and warns about model
+// names offered by more than one provider.
+func (h *Handler) RefreshModels(ctx context.Context) error {
+	localLogger := logger.NewLogger("RefreshModels")
+	err := h.models.Refresh(ctx)
+	if err != nil {
+		localLogger.Error("Failed to refresh models", "err", err)
+	}
+	for name, providers := range h.models.Collisions(ctx) {
+		localLogger.Warn("Model offered by several providers", "model", name, "providers", providers)
+	}
+	return err
+}
+
+// chatMessages is the history sent to the model for a request. A language
+// instruction is added as a system message but kept out of the history, so
+// it only applies to this reply.
+func chatMessages(clientReq client.ChatRequest, history []client.ServerChatMessage) []client.ServerChatMessage {
+	if clientReq.Language == "" {
+		return history
+	}
+	messages := make([]client.ServerChatMessage, 0, len(history)+1)
+	messages = append(messages, client.ServerChatMessage{
+		Role:    client.RoleSystem,
+		Content: languageInstruction(clientReq.Language),
+	})
+	return append(messages, history...)
+}
+
+func languageInstruction(tag string) string {
+	name := tag
+	if t, err := language.Parse(tag); err == nil {
+		if n := display.English.Tags().Name(t); n != "" {
+			name = fmt.Sprintf("%s (%s)", n, tag)
+		}
+	}
+	return "The user is speaking " + name + ". Reply in the same language."
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/bz888/blab/internal/api/server/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChatMessagesLanguage(t *testing.T) {
+	history := []client.ServerChatMessage{{Role: client.RoleUser, Content: "Wie spät ist es?"}}
+
+	assert.Equal(t, history, chatMessages(client.ChatRequest{}, history))
+
+	messages := chatMessages(client.ChatRequest{Language: "de-DE"}, history)
+	assert.Len(t, messages, 2)
+	assert.Equal(t, client.RoleSystem, messages[0].Role)
+	assert.Contains(t, messages[0].Content, "German (Germany) (de-DE)")
+	assert.Equal(t, history[0], messages[1])
+	assert.Len(t, history, 1, "history is not modified")
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/bz888/blab/internal/api/server/auth"
+	"github.com/bz888/blab/internal/api/server/client"
+	"github.com/bz888/blab/internal/api/server/registry"
+	"github.com/bz888/blab/internal/logger"
+	"net/http"
+)
+
+// ModelInfoHandler lists every model with the details its provider reports.
+func (h *Handler) ModelInfoHandler(w http.ResponseWriter, r *http.Request) {
+	models := h.allowedModels(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(models); err != nil {
+		http.Error(w, "Failed to encode response: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// RefreshModelsHandler fetches every provider's models now and lists them
+// like ModelInfoHandler. It fails only when no provider could be reached.
+func (h *Handler) RefreshModelsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := h.RefreshModels(r.Context())
+	models := h.allowedModels(r.Context())
+	if err != nil && len(models) == 0 {
+		http.Error(w, "Failed to refresh models: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(models); err != nil {
+		http.Error(w, "Failed to encode response: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// allowedModels lists the models the request's token may use.
+func (h *Handler) allowedModels(ctx context.Context) []client.ModelInfo {
+	models := make([]client.ModelInfo, 0)
+	for _, model := range h.models.Models(ctx) {
+		if auth.AllowsModel(ctx, model.Provider, model.Name) {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+// PullModelHandler pulls an Ollama model, streaming its progress as
+// newline-delimited PullProgress values. A failure after the stream has
+// started is sent as a final PullProgress with Error set.
+func (h *Handler) PullModelHandler(w http.ResponseWriter, r *http.Request) {
+	localLogger := logger.NewLogger("PullModelHandler")
+	modelReq, ok := h.decodeModelRequest(w, r)
+	if !ok {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	encoder := json.NewEncoder(w)
+
+	if provider, name := h.models.Split(modelReq.Name); provider == "ollama" {
+		modelReq.Name = name
+	} else if provider != "" {
+		http.Error(w, "Only Ollama models can be pulled", http.StatusBadRequest)
+		return
+	}
+
+	localLogger.Info("Pulling model", "model", modelReq.Name)
+	err := h.ollama().Pull(r.Context(), modelReq.Name, func(progress client.PullProgress) error {
+		if err := encoder.Encode(progress); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+	if err != nil {
+		localLogger.Error("Failed to pull model", "model", modelReq.Name, "err", err)
+		encoder.Encode(client.PullProgress{Error: err.Error()})
+		return
+	}
+
+	// Fetch the models again on next use so the new one can be chatted with.
+	h.models.Invalidate("ollama")
+}
+
+// DeleteModelHandler removes a local Ollama model.
+func (h *Handler) DeleteModelHandler(w http.ResponseWriter, r *http.Request) {
+	localLogger := logger.NewLogger("DeleteModelHandler")
+	modelReq, ok := h.decodeModelRequest(w, r)
+	if !ok {
+		return
+	}
+
+	model, err := h.models.Lookup(r.Context(), modelReq.Name)
+	if err != nil {
+		status := http.StatusNotFound
+		if !errors.Is(err, registry.ErrNotFound) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if model.Provider != "ollama" {
+		http.Error(w, "Only Ollama models can be deleted", http.StatusBadRequest)
+		return
+	}
+	modelReq.Name = model.Name
+
+	if err := h.ollama().Delete(r.Context(), modelReq.Name); err != nil {
+		localLogger.Error("Failed to delete model", "model", modelReq.Name, "err", err)
+		http.Error(w, "Failed to delete model: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	h.models.Invalidate("ollama")
+	localLogger.Info("Deleted model", "model", modelReq.Name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeModelRequest reads a POST body naming an Ollama model, answering the
+// request itself when it cannot be served.
+func (h *Handler) decodeModelRequest(w http.ResponseWriter, r *http.Request) (client.ModelRequest, bool) {
+	var modelReq client.ModelRequest
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return modelReq, false
+	}
+	if err := json.NewDecoder(r.Body).Decode(&modelReq); err != nil || modelReq.Name == "" {
+		http.Error(w, "A model name is required", http.StatusBadRequest)
+		return modelReq, false
+	}
+	if h.ollama() == nil {
+		http.Error(w, "Ollama is not available", http.StatusServiceUnavailable)
+		return modelReq, false
+	}
+	return modelReq, true
+}
@@ -1,23 +1,22 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/bz888/blab/internal/api/server/client"
 	"github.com/bz888/blab/internal/logger"
 	"net/http"
+	"strings"
 )
 
-func (h *Handler) processWithOllamaClient(w http.ResponseWriter, r *http.Request, clientReq client.ChatRequest, chatHistory *[]client.ServerChatMessage) {
+// processWithOllamaClient streams the reply to history and returns it once
+// it is complete.
+func (h *Handler) processWithOllamaClient(w http.ResponseWriter, r *http.Request, clientReq client.ChatRequest, history []client.ServerChatMessage) (string, bool) {
 	localLogger := logger.NewLogger("Ollama handler")
 
-	*chatHistory = append(*chatHistory, client.ServerChatMessage{
-		Role:    client.RoleUser,
-		Content: clientReq.Text,
-	})
-
 	apiReq := client.ServerChatRequest{
 		Model:    clientReq.Model,
-		Messages: *chatHistory,
+		Messages: chatMessages(clientReq, history),
 		Stream:   true,
 	}
 
@@ -29,22 +28,23 @@ func (h *Handler) processWithOllamaClient(w http.ResponseWriter, r *http.Request
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
-		return
+		return "", false
 	}
 
-	err := h.ollamaClient.Chat(r.Context(), &apiReq, func(bts []byte) error {
+	var reply strings.Builder
+	err := h.ollama().Chat(r.Context(), &apiReq, func(bts []byte) error {
 		var apiResp client.OllamaAPIResponse
 		if err := json.Unmarshal(bts, &apiResp); err != nil {
-			localLogger.Error("Failed to unmarshal response:", err)
-			localLogger.Error("Raw response data:", string(bts)) // Log the raw response data
+			localLogger.Error("Failed to unmarshal response", "err", err, "data", string(bts))
 			return err
 		}
 
+		reply.WriteString(apiResp.Message.Content)
 		err := encoder.Encode(client.ChatResponse{ProcessedText: apiResp.Message.Content})
 		if !apiResp.Done {
-			localLogger.Info("Received response:", apiResp.Message.Content)
+			localLogger.Debug("Received response", "content", apiResp.Message.Content)
 		} else {
-			localLogger.Info("Completed response", string(bts))
+			localLogger.Info("Completed response", "data", string(bts))
 		}
 
 		if err != nil {
@@ -58,20 +58,29 @@ func (h *Handler) processWithOllamaClient(w http.ResponseWriter, r *http.Request
 
 	if err != nil {
 		http.Error(w, "Failed to process request: "+err.Error(), http.StatusInternalServerError)
+		return "", false
 	}
+	return reply.String(), true
 }
 
-func (h *Handler) processOllamaModels() []string {
-	ollamaModels, err := h.ollamaClient.GetModels()
-	// should return just the model name in an array
+// listOllamaModels fetches the local Ollama models with their details.
+func (h *Handler) listOllamaModels(context.Context) ([]client.ModelInfo, error) {
+	ollamaModels, err := h.ollama().GetModels()
 	if err != nil {
-		return []string{}
+		return nil, err
 	}
 
-	modelNames := make([]string, len(ollamaModels))
+	models := make([]client.ModelInfo, len(ollamaModels))
 	for i, model := range ollamaModels {
-		modelNames[i] = model.Name
-		//h.modelClientMap[model.Name] = "ollama"
+		models[i] = client.ModelInfo{
+			Name:          model.Name,
+			Provider:      "ollama",
+			Family:        model.Details.Family,
+			ParameterSize: model.Details.ParameterSize,
+			Quantization:  model.Details.QuantizationLevel,
+			Size:          model.Size,
+			ModifiedAt:    model.ModifiedAt,
+		}
 	}
-	return modelNames
+	return models, nil
 }
@@ -2,23 +2,23 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"github.com/bz888/blab/internal/api/server/client"
 	"github.com/bz888/blab/internal/logger"
 	"net/http"
+	"strings"
+	"time"
 )
 
-func (h *Handler) processWithOpenAIClient(w http.ResponseWriter, r *http.Request, clientReq client.ChatRequest, chatHistory *[]client.ServerChatMessage) {
+// processWithOpenAIClient streams the reply to history and returns it once
+// it is complete.
+func (h *Handler) processWithOpenAIClient(w http.ResponseWriter, r *http.Request, clientReq client.ChatRequest, history []client.ServerChatMessage) (string, bool) {
 	localLogger := logger.NewLogger("openai handler")
 
-	*chatHistory = append(*chatHistory, client.ServerChatMessage{
-		Role:    client.RoleUser,
-		Content: clientReq.Text,
-	})
-
 	apiReq := client.ServerChatRequest{
 		Model:    clientReq.Model,
-		Messages: *chatHistory,
+		Messages: chatMessages(clientReq, history),
 		Stream:   true,
 	}
 
@@ -31,7 +31,7 @@ func (h *Handler) processWithOpenAIClient(w http.ResponseWriter, r *http.Request
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
-		return
+		return "", false
 	}
 
 	respCh := make(chan string)
@@ -40,7 +40,7 @@ func (h *Handler) processWithOpenAIClient(w http.ResponseWriter, r *http.Request
 	go func() {
 		defer close(respCh)
 
-		err := h.openAIClient.Chat(r.Context(), &apiReq, func(bts []byte) error {
+		err := h.openAI().Chat(r.Context(), &apiReq, func(bts []byte) error {
 			cleanData := bytes.TrimPrefix(bts, []byte("data: "))
 			cleanData = bytes.TrimSpace(cleanData)
 
@@ -54,15 +54,14 @@ func (h *Handler) processWithOpenAIClient(w http.ResponseWriter, r *http.Request
 
 			var apiResp client.OpenAIChatResponse
 			if err := json.Unmarshal(cleanData, &apiResp); err != nil {
-				localLogger.Error("Failed to unmarshal response:", err)
-				localLogger.Error("Raw response data:", string(bts))
+				localLogger.Error("Failed to unmarshal response", "err", err, "data", string(bts))
 				return err
 			}
 
 			if apiResp.Choices != nil && len(apiResp.Choices) > 0 && apiResp.Choices[0].Delta.Content != nil {
 				content := *apiResp.Choices[0].Delta.Content
 				if content != "" {
-					localLogger.Info("msg content: ", content)
+					localLogger.Debug("Received response", "content", content)
 					respCh <- content
 				}
 			} else {
@@ -72,54 +71,58 @@ func (h *Handler) processWithOpenAIClient(w http.ResponseWriter, r *http.Request
 			return nil
 		})
 		if err != nil {
-			localLogger.Error("Error from Chat function:", err)
+			localLogger.Error("Chat request failed", "err", err)
 			errCh <- err
 		}
 	}()
 
+	var reply strings.Builder
 	for {
 		select {
 		case <-r.Context().Done():
-			return
+			return "", false
 		case err := <-errCh:
 			http.Error(w, "Failed to process request: "+err.Error(), http.StatusInternalServerError)
-			return
+			return "", false
 		case message, ok := <-respCh:
 			if !ok {
-				return
+				// The error, if any, is sent before respCh is closed.
+				select {
+				case err := <-errCh:
+					http.Error(w, "Failed to process request: "+err.Error(), http.StatusInternalServerError)
+					return "", false
+				default:
+				}
+				return reply.String(), true
 			}
+			reply.WriteString(message)
 			if err := encoder.Encode(client.ChatResponse{ProcessedText: message}); err != nil {
 				http.Error(w, "Failed to encode response: "+err.Error(), http.StatusInternalServerError)
-				return
+				return "", false
 			}
 			flusher.Flush()
 		}
 	}
 }
 
-func (h *Handler) processOpenAiModels() []string {
-	if len(client.CacheModels) > 0 {
-		var cachedModelNames []string
-		for key, value := range client.CacheModels {
-			if value == "openai" {
-				cachedModelNames = append(cachedModelNames, key)
-			}
-		}
-		if len(cachedModelNames) > 0 {
-			return cachedModelNames
-		}
-	}
-
-	openAIModels, err := h.openAIClient.GetModels()
+// listOpenAIModels fetches the OpenAI chat models. The API also lists
+// embedding, image and speech models, which are skipped.
+func (h *Handler) listOpenAIModels(context.Context) ([]client.ModelInfo, error) {
+	openAIModels, err := h.openAI().GetModels()
 	if err != nil {
-		return []string{}
+		return nil, err
 	}
-	var modelNames = make([]string, 0)
+
+	models := make([]client.ModelInfo, 0)
 	for _, model := range openAIModels {
-		if model.OwnedBy == "openai" && model.ID != "" {
-			modelNames = append(modelNames, model.ID)
+		if model.OwnedBy != "openai" || model.ID == "" {
+			continue
 		}
+		models = append(models, client.ModelInfo{
+			Name:       model.ID,
+			Provider:   "openai",
+			ModifiedAt: time.Unix(model.Created, 0),
+		})
 	}
-
-	return modelNames
+	return models, nil
 }
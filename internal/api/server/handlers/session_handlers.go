@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/bz888/blab/internal/api/server/auth"
+	"github.com/bz888/blab/internal/logger"
+	"net/http"
+	"strings"
+)
+
+// SessionsHandler lists the token's sessions on GET and starts a new one on
+// POST.
+func (h *Handler) SessionsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, h.sessions.List(auth.Owner(r.Context())))
+	case http.MethodPost:
+		created, err := h.sessions.Create(auth.Owner(r.Context()))
+		if err != nil {
+			logger.NewLogger("SessionsHandler").Error("Failed to create session", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusCreated, created)
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// SessionHandler serves /sessions/{id}: GET returns the session with its
+// messages and DELETE ends it. Other tokens' sessions are not found.
+func (h *Handler) SessionHandler(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/sessions/")
+	if id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		found, err := h.sessions.Get(auth.Owner(r.Context()), id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, found)
+	case http.MethodDelete:
+		if err := h.sessions.Delete(auth.Owner(r.Context()), id); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodDelete)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.NewLogger("writeJSON").Error("Failed to encode response", "err", err)
+	}
+}
@@ -0,0 +1,210 @@
+// Package health probes the model providers periodically and reports
+// whether each of them can be reached, how quickly it answered and why it
+// could not be reached.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Probe checks once that a provider answers, returning why it does not.
+type Probe func(ctx context.Context) error
+
+// Status is the outcome of the latest probe of a provider.
+type Status struct {
+	Provider  string        `json:"provider"`
+	Reachable bool          `json:"reachable"`
+	Latency   time.Duration `json:"latency"`
+	// Error is why the latest probe failed. LastError keeps the most recent
+	// failure after the provider recovers.
+	Error     string    `json:"error,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+	// Since is when the provider last became reachable or unreachable.
+	Since time.Time `json:"since"`
+}
+
+// Health is the body of the /healthz endpoint.
+type Health struct {
+	// Status is "ok" when every provider is reachable, "degraded" when
+	// some are and "unavailable" when none are.
+	Status    string   `json:"status"`
+	Providers []Status `json:"providers"`
+}
+
+// Monitor probes the registered providers. It is safe for concurrent use.
+type Monitor struct {
+	interval time.Duration
+	timeout  time.Duration
+	now      func() time.Time
+
+	mu       sync.RWMutex
+	probes   map[string]Probe
+	status   map[string]Status
+	onChange func(Status)
+}
+
+// New creates a monitor that probes every interval, giving up on a probe
+// after timeout.
+func New(interval, timeout time.Duration) *Monitor {
+	return &Monitor{
+		interval: interval,
+		timeout:  timeout,
+		now:      time.Now,
+		probes:   map[string]Probe{},
+		status:   map[string]Status{},
+	}
+}
+
+// Register adds a provider, replacing any provider of the same name. It is
+// reported unreachable until it has been probed.
+func (m *Monitor) Register(name string, probe Probe) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.probes[name] = probe
+	m.status[name] = Status{Provider: name, Error: "not probed yet"}
+}
+
+// OnChange calls fn, from the probing goroutine, whenever a provider becomes
+// reachable or unreachable.
+func (m *Monitor) OnChange(fn func(Status)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = fn
+}
+
+// Run probes every provider every interval until ctx is done. Call Check
+// first for the status at startup.
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Check(ctx)
+		}
+	}
+}
+
+// Check probes every provider concurrently and returns their new status.
+func (m *Monitor) Check(ctx context.Context) []Status {
+	m.mu.RLock()
+	probes := make(map[string]Probe, len(m.probes))
+	for name, probe := range m.probes {
+		probes[name] = probe
+	}
+	m.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for name, probe := range probes {
+		wg.Add(1)
+		go func(name string, probe Probe) {
+			defer wg.Done()
+			m.check(ctx, name, probe)
+		}(name, probe)
+	}
+	wg.Wait()
+	return m.Status()
+}
+
+func (m *Monitor) check(ctx context.Context, name string, probe Probe) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	start := m.now()
+	err := probe(ctx)
+	checked := m.now()
+
+	m.mu.Lock()
+	previous, ok := m.status[name]
+	if !ok {
+		// Unregistered while it was being probed.
+		m.mu.Unlock()
+		return
+	}
+	status := Status{
+		Provider:  name,
+		Reachable: err == nil,
+		Latency:   checked.Sub(start),
+		LastError: previous.LastError,
+		CheckedAt: checked,
+		Since:     previous.Since,
+	}
+	if err != nil {
+		status.Error = err.Error()
+		status.LastError = status.Error
+	}
+	changed := previous.CheckedAt.IsZero() || previous.Reachable != status.Reachable
+	if changed {
+		status.Since = checked
+	}
+	m.status[name] = status
+	onChange := m.onChange
+	m.mu.Unlock()
+
+	if changed && onChange != nil {
+		onChange(status)
+	}
+}
+
+// Status returns the latest status of every provider, sorted by name.
+func (m *Monitor) Status() []Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	statuses := make([]Status, 0, len(m.status))
+	for _, status := range m.status {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Provider < statuses[j].Provider })
+	return statuses
+}
+
+// Reachable reports whether the latest probe of a provider succeeded.
+func (m *Monitor) Reachable(name string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.status[name].Reachable
+}
+
+// Health summarises the status of every provider.
+func (m *Monitor) Health() Health {
+	health := Health{Providers: m.Status()}
+	reachable := 0
+	for _, status := range health.Providers {
+		if status.Reachable {
+			reachable++
+		}
+	}
+	switch {
+	case reachable == 0:
+		health.Status = "unavailable"
+	case reachable < len(health.Providers):
+		health.Status = "degraded"
+	default:
+		health.Status = "ok"
+	}
+	return health
+}
+
+// HealthzHandler reports whether the server can chat: 200 while at least one
+// provider is reachable, and 503 otherwise.
+func (m *Monitor) HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	health := m.Health()
+	w.Header().Set("Content-Type", "application/json")
+	if health.Status == "unavailable" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(health)
+}
+
+// ProvidersHandler lists the latest status of every provider.
+func (m *Monitor) ProvidersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m.Status())
+}
@@ -0,0 +1,112 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckTracksTransitions(t *testing.T) {
+	monitor := New(time.Minute, time.Second)
+	clock := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	monitor.now = func() time.Time {
+		clock = clock.Add(10 * time.Millisecond)
+		return clock
+	}
+
+	down := errors.New("connection refused")
+	var err error
+	monitor.Register("ollama", func(context.Context) error { return err })
+
+	var changes []Status
+	monitor.OnChange(func(status Status) { changes = append(changes, status) })
+
+	statuses := monitor.Check(context.Background())
+	require.Len(t, statuses, 1)
+	assert.True(t, statuses[0].Reachable)
+	assert.Equal(t, 10*time.Millisecond, statuses[0].Latency)
+	since := statuses[0].Since
+
+	monitor.Check(context.Background())
+	assert.Equal(t, since, monitor.Status()[0].Since, "Since only moves on a transition")
+
+	err = down
+	monitor.Check(context.Background())
+	status := monitor.Status()[0]
+	assert.False(t, status.Reachable)
+	assert.Equal(t, "connection refused", status.Error)
+	assert.True(t, status.Since.After(since))
+
+	err = nil
+	monitor.Check(context.Background())
+	status = monitor.Status()[0]
+	assert.True(t, status.Reachable)
+	assert.Empty(t, status.Error)
+	assert.Equal(t, "connection refused", status.LastError, "the last failure is kept after recovering")
+
+	require.Len(t, changes, 3)
+	assert.Equal(t, []bool{true, false, true}, []bool{changes[0].Reachable, changes[1].Reachable, changes[2].Reachable})
+}
+
+func TestCheckTimesOut(t *testing.T) {
+	monitor := New(time.Minute, 10*time.Millisecond)
+	monitor.Register("openai", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	statuses := monitor.Check(context.Background())
+	require.Len(t, statuses, 1)
+	assert.False(t, statuses[0].Reachable)
+	assert.Contains(t, statuses[0].Error, "deadline exceeded")
+}
+
+func TestHealthzHandler(t *testing.T) {
+	monitor := New(time.Minute, time.Second)
+	get := func() (int, Health) {
+		rec := httptest.NewRecorder()
+		monitor.HealthzHandler(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		var health Health
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &health))
+		return rec.Code, health
+	}
+
+	code, health := get()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "unavailable", health.Status)
+
+	monitor.Register("ollama", func(context.Context) error { return nil })
+	monitor.Register("openai", func(context.Context) error { return errors.New("401 Unauthorized") })
+	code, health = get()
+	assert.Equal(t, http.StatusServiceUnavailable, code, "providers are unreachable until probed")
+	assert.Equal(t, "not probed yet", health.Providers[0].Error)
+
+	monitor.Check(context.Background())
+	code, health = get()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "degraded", health.Status)
+	require.Len(t, health.Providers, 2)
+	assert.Equal(t, "ollama", health.Providers[0].Provider)
+	assert.Equal(t, "401 Unauthorized", health.Providers[1].Error)
+}
+
+func TestProvidersHandler(t *testing.T) {
+	monitor := New(time.Minute, time.Second)
+	monitor.Register("ollama", func(context.Context) error { return nil })
+	monitor.Check(context.Background())
+
+	rec := httptest.NewRecorder()
+	monitor.ProvidersHandler(rec, httptest.NewRequest(http.MethodGet, "/providers", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var statuses []Status
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &statuses))
+	require.Len(t, statuses, 1)
+	assert.True(t, statuses[0].Reachable)
+}
@@ -0,0 +1,279 @@
+// Package limit bounds how many requests a provider serves at once and how
+// often it is sent one, queueing the requests over those limits in order.
+package limit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull is returned when a request would have to wait but the queue
+// already holds as many requests as it may.
+var ErrQueueFull = errors.New("too many requests are waiting")
+
+// Config sets the limits of one provider. Zero values mean no limit, except
+// for Queue, where zero refuses every request that would have to wait.
+type Config struct {
+	// Concurrency is how many requests are served at once.
+	Concurrency int
+	// Rate is how many requests are started per second on average, and
+	// Burst how many may start at once after a quiet period; at least 1.
+	Rate  float64
+	Burst int
+	// Queue is how many requests may wait for their turn.
+	Queue int
+}
+
+// Parse reads a config written as comma-separated key=value pairs, e.g.
+// "concurrency=1,queue=8" or "rate=60/m,burst=5". Rates are per second
+// unless they end in /s, /m or /h.
+func Parse(spec string) (Config, error) {
+	var config Config
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return Config{}, fmt.Errorf("limit %q is not key=value", pair)
+		}
+		var err error
+		switch strings.TrimSpace(key) {
+		case "concurrency":
+			config.Concurrency, err = strconv.Atoi(value)
+		case "rate":
+			config.Rate, err = parseRate(value)
+		case "burst":
+			config.Burst, err = strconv.Atoi(value)
+		case "queue":
+			config.Queue, err = strconv.Atoi(value)
+		default:
+			return Config{}, fmt.Errorf("unknown limit %q, use concurrency, rate, burst or queue", key)
+		}
+		if err != nil {
+			return Config{}, fmt.Errorf("limit %q: %w", pair, err)
+		}
+	}
+	if config.Concurrency < 0 || config.Rate < 0 || config.Burst < 0 || config.Queue < 0 {
+		return Config{}, fmt.Errorf("limits in %q cannot be negative", spec)
+	}
+	return config, nil
+}
+
+func parseRate(value string) (float64, error) {
+	per := time.Second
+	for suffix, unit := range map[string]time.Duration{"/s": time.Second, "/m": time.Minute, "/h": time.Hour} {
+		if number, ok := strings.CutSuffix(value, suffix); ok {
+			value, per = number, unit
+			break
+		}
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	return rate / per.Seconds(), nil
+}
+
+// Limiter applies a Config. It is safe for concurrent use.
+type Limiter struct {
+	config Config
+	now    func() time.Time
+
+	mu       sync.Mutex
+	active   int
+	tokens   float64
+	refilled time.Time
+	waiting  []*waiter
+	timer    *time.Timer
+}
+
+type waiter struct {
+	ready   chan struct{} // closed once the request may start
+	moved   chan struct{} // signalled when the request moves up the queue
+	granted bool
+}
+
+// New creates a limiter with a full bucket of Burst requests.
+func New(config Config) *Limiter {
+	if config.Burst < 1 {
+		config.Burst = 1
+	}
+	return &Limiter{config: config, now: time.Now, tokens: float64(config.Burst), refilled: time.Now()}
+}
+
+// Acquire waits until a request may be sent to the provider and returns the
+// function to call once it is done. While the request waits, queued is
+// called with its position, 1 being next, whenever that changes. Acquire
+// fails at once with ErrQueueFull, or with ctx's error while waiting.
+func (l *Limiter) Acquire(ctx context.Context, queued func(position int)) (release func(), err error) {
+	l.mu.Lock()
+	if len(l.waiting) == 0 && l.take() {
+		l.mu.Unlock()
+		return l.releaseOnce(), nil
+	}
+	if len(l.waiting) >= l.config.Queue {
+		l.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	w := &waiter{ready: make(chan struct{}), moved: make(chan struct{}, 1)}
+	l.waiting = append(l.waiting, w)
+	position := len(l.waiting)
+	l.schedule()
+	l.mu.Unlock()
+
+	queued(position)
+	for {
+		select {
+		case <-w.ready:
+			return l.releaseOnce(), nil
+		case <-w.moved:
+			l.mu.Lock()
+			moved := l.position(w)
+			l.mu.Unlock()
+			if moved > 0 && moved != position {
+				position = moved
+				queued(position)
+			}
+		case <-ctx.Done():
+			l.mu.Lock()
+			granted := w.granted
+			if !granted {
+				l.remove(w)
+			}
+			l.mu.Unlock()
+			if granted {
+				// Granted just as the request gave up.
+				l.release()
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// RetryAfter estimates when a refused request could be accepted.
+func (l *Limiter) RetryAfter() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.config.Rate > 0 {
+		l.refill()
+		if wait := l.untilToken(); wait > time.Second {
+			return wait
+		}
+	}
+	return time.Second
+}
+
+// Waiting is how many requests are queued.
+func (l *Limiter) Waiting() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.waiting)
+}
+
+func (l *Limiter) releaseOnce() func() {
+	var once sync.Once
+	return func() { once.Do(l.release) }
+}
+
+func (l *Limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	l.dispatch()
+}
+
+// take starts a request if the limits allow one now.
+func (l *Limiter) take() bool {
+	if l.config.Concurrency > 0 && l.active >= l.config.Concurrency {
+		return false
+	}
+	if l.config.Rate > 0 {
+		l.refill()
+		if l.tokens < 1 {
+			return false
+		}
+		l.tokens--
+	}
+	l.active++
+	return true
+}
+
+func (l *Limiter) refill() {
+	now := l.now()
+	l.tokens = math.Min(float64(l.config.Burst), l.tokens+now.Sub(l.refilled).Seconds()*l.config.Rate)
+	l.refilled = now
+}
+
+func (l *Limiter) untilToken() time.Duration {
+	if l.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.config.Rate * float64(time.Second))
+}
+
+// dispatch starts the waiting requests the limits allow, in order.
+func (l *Limiter) dispatch() {
+	moved := false
+	for len(l.waiting) > 0 && l.take() {
+		w := l.waiting[0]
+		l.waiting = l.waiting[1:]
+		w.granted = true
+		close(w.ready)
+		moved = true
+	}
+	if moved {
+		l.notify()
+	}
+	l.schedule()
+}
+
+// schedule wakes the queue once the next rate token is due, when requests
+// wait for one rather than for a slot.
+func (l *Limiter) schedule() {
+	if l.timer != nil || len(l.waiting) == 0 || l.config.Rate <= 0 {
+		return
+	}
+	if l.config.Concurrency > 0 && l.active >= l.config.Concurrency {
+		return
+	}
+	l.refill()
+	l.timer = time.AfterFunc(l.untilToken(), func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.timer = nil
+		l.dispatch()
+	})
+}
+
+func (l *Limiter) position(w *waiter) int {
+	for i, waiting := range l.waiting {
+		if waiting == w {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func (l *Limiter) remove(w *waiter) {
+	if i := l.position(w) - 1; i >= 0 {
+		l.waiting = append(l.waiting[:i], l.waiting[i+1:]...)
+		l.notify()
+	}
+}
+
+func (l *Limiter) notify() {
+	for _, w := range l.waiting {
+		select {
+		case w.moved <- struct{}{}:
+		default:
+		}
+	}
+}
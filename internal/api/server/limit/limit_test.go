@@ -0,0 +1,138 @@
+package limit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	config, err := Parse("concurrency=2, rate=60/m,burst=5,queue=8")
+	require.NoError(t, err)
+	assert.Equal(t, Config{Concurrency: 2, Rate: 1, Burst: 5, Queue: 8}, config)
+
+	config, err = Parse("rate=0.5")
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, config.Rate)
+
+	config, err = Parse("")
+	require.NoError(t, err)
+	assert.Equal(t, Config{}, config)
+
+	_, err = Parse("concurrency")
+	assert.Error(t, err)
+	_, err = Parse("threads=2")
+	assert.ErrorContains(t, err, "unknown limit")
+	_, err = Parse("queue=-1")
+	assert.Error(t, err)
+}
+
+func TestUnlimited(t *testing.T) {
+	limiter := New(Config{})
+	for i := 0; i < 100; i++ {
+		_, err := limiter.Acquire(context.Background(), func(int) { t.Fatal("an unlimited request never waits") })
+		require.NoError(t, err)
+	}
+}
+
+// positions records the queue positions reported to a waiting request.
+type positions struct {
+	mu   sync.Mutex
+	seen []int
+	next chan int
+}
+
+func newPositions() *positions {
+	return &positions{next: make(chan int, 10)}
+}
+
+func (p *positions) queued(position int) {
+	p.mu.Lock()
+	p.seen = append(p.seen, position)
+	p.mu.Unlock()
+	p.next <- position
+}
+
+func TestConcurrencyQueuesInOrder(t *testing.T) {
+	limiter := New(Config{Concurrency: 1, Queue: 2})
+
+	release, err := limiter.Acquire(context.Background(), nil)
+	require.NoError(t, err)
+
+	first, second := newPositions(), newPositions()
+	firstDone, secondDone := make(chan func()), make(chan func())
+	go func() {
+		release, err := limiter.Acquire(context.Background(), first.queued)
+		assert.NoError(t, err)
+		firstDone <- release
+	}()
+	assert.Equal(t, 1, <-first.next)
+	go func() {
+		release, err := limiter.Acquire(context.Background(), second.queued)
+		assert.NoError(t, err)
+		secondDone <- release
+	}()
+	assert.Equal(t, 2, <-second.next)
+
+	_, err = limiter.Acquire(context.Background(), nil)
+	assert.ErrorIs(t, err, ErrQueueFull)
+
+	release()
+	release() // releasing twice frees a single slot
+	releaseFirst := <-firstDone
+	assert.Equal(t, 1, <-second.next, "the second request moves up")
+	assert.Equal(t, 1, limiter.Waiting())
+
+	releaseFirst()
+	(<-secondDone)()
+	assert.Equal(t, 0, limiter.Waiting())
+}
+
+func TestCancelLeavesQueue(t *testing.T) {
+	limiter := New(Config{Concurrency: 1, Queue: 2})
+	release, err := limiter.Acquire(context.Background(), nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first, second := newPositions(), newPositions()
+	firstErr := make(chan error)
+	go func() {
+		_, err := limiter.Acquire(ctx, first.queued)
+		firstErr <- err
+	}()
+	<-first.next
+	secondDone := make(chan func())
+	go func() {
+		release, _ := limiter.Acquire(context.Background(), second.queued)
+		secondDone <- release
+	}()
+	assert.Equal(t, 2, <-second.next)
+
+	cancel()
+	assert.ErrorIs(t, <-firstErr, context.Canceled)
+	assert.Equal(t, 1, <-second.next)
+
+	release()
+	(<-secondDone)()
+}
+
+func TestRateLimit(t *testing.T) {
+	limiter := New(Config{Rate: 20, Burst: 2, Queue: 1})
+
+	for i := 0; i < 2; i++ {
+		_, err := limiter.Acquire(context.Background(), func(int) { t.Fatal("the burst does not wait") })
+		require.NoError(t, err)
+	}
+	assert.Greater(t, limiter.RetryAfter(), time.Duration(0))
+
+	waiting := newPositions()
+	start := time.Now()
+	_, err := limiter.Acquire(context.Background(), waiting.queued)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, waiting.seen)
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond, "waited for a token, one every 50ms")
+}
@@ -1,126 +1,208 @@
 package server
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"github.com/bz888/blab/internal/api/server/auth"
 	"github.com/bz888/blab/internal/api/server/client"
 	"github.com/bz888/blab/internal/api/server/handlers"
+	"github.com/bz888/blab/internal/api/server/health"
+	"github.com/bz888/blab/internal/api/server/limit"
+	"github.com/bz888/blab/internal/api/server/trace"
+	"github.com/bz888/blab/internal/config"
 	"github.com/bz888/blab/internal/logger"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
-	"strconv"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 )
 
+// probeTimeout bounds each check that a provider is reachable.
+const probeTimeout = 5 * time.Second
+
 var (
 	LocalLogger *logger.Logger
-	port        = 8080
 )
 
 func Init() {
 	LocalLogger = logger.NewLogger("Server")
 }
 
-func Run() {
-	handler, err := initializeClients()
-	if err != nil {
-		log.Fatal(err)
+// Listen binds the address set with -listen: a TCP address, where port 0
+// picks a free port, or "unix:" followed by the path of a Unix socket.
+func Listen() (net.Listener, error) {
+	path, ok := strings.CutPrefix(config.Listen, "unix:")
+	if !ok {
+		return net.Listen("tcp", config.Listen)
 	}
 
-	registerRoutes(handler)
+	listener, err := net.Listen("unix", path)
+	if err == nil || !errors.Is(err, syscall.EADDRINUSE) {
+		return listener, err
+	}
+	// A socket nobody answers on was left behind by an instance that exited
+	// without closing it.
+	if conn, dialErr := net.Dial("unix", path); dialErr == nil {
+		conn.Close()
+		return nil, fmt.Errorf("another blab is listening on %s", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return nil, err
+	}
+	return net.Listen("unix", path)
+}
 
-	address := ":" + strconv.Itoa(port)
-	LocalLogger.Info("Debug mode is enabled")
+// Address is how a blabclient reaches the server on listener's address.
+func Address(addr net.Addr) string {
+	if addr.Network() == "unix" {
+		return "unix:" + addr.String()
+	}
+	return "http://" + addr.String()
+}
 
-	// Start the server
-	LocalLogger.Info("Server started on http://localhost" + address + "/")
-	err = http.ListenAndServe(address, nil)
+// LoadAuth reads the tokens and CORS settings from the config directory,
+// creating them with a new token on first run.
+func LoadAuth() (*auth.Config, error) {
+	dir, err := config.Dir()
 	if err != nil {
-		log.Fatal("Error starting server: ", err)
+		return nil, err
 	}
+	return auth.Load(filepath.Join(dir, auth.FileName))
 }
 
-func initializeClients() (*handlers.Handler, error) {
-	var openAIClient client.OpenAIClientInterface
-	var ollamaClient client.OllamaClientInterface
-
-	openAIAvailable := checkOpenAIAvailability()
-	ollamaAvailable := checkOllamaAvailability()
+// Run serves the API on listener until it is closed, with the tokens and
+// CORS settings of authConfig.
+func Run(listener net.Listener, authConfig *auth.Config) {
+	tracer, err := initializeTracer()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	if openAIAvailable {
-		c := client.NewOpenAIClient()
-		_, err := c.GetModels()
-		if err != nil {
-			LocalLogger.Error("Error initializing OpenAI client:", err)
-		} else {
-			openAIClient = c
-			LocalLogger.Info("OpenAI client initialized.")
-		}
-	} else {
-		openAIClient = nil
+	handler, monitor, providers := initializeProviders()
+	if err := initializeLimits(handler); err != nil {
+		log.Fatal(err)
+	}
+	if config.ProbeInterval > 0 {
+		go monitor.Run(context.Background())
 	}
 
-	if ollamaAvailable {
-		c := client.NewOllamaClient()
-		_, err := c.GetModels()
-		if err != nil {
-			LocalLogger.Error("Error initializing Ollama client:", err)
-		} else {
-			ollamaClient = c
-			LocalLogger.Info("Ollama client initialized.")
-		}
-	} else {
-		ollamaClient = nil
+	registerRoutes(handler, tracer, monitor, providers)
+
+	// Health checks are left open for supervisors without the token.
+	routes := authConfig.Middleware(http.DefaultServeMux, config.Auth, "/healthz")
+	if !config.Auth {
+		LocalLogger.Warn("API authentication is disabled")
+	}
+	if !config.AllowRemote {
+		routes = loopbackOnly(routes)
 	}
-	LocalLogger.Info("Cached models", client.CacheModels)
 
-	if !openAIAvailable && !ollamaAvailable {
-		return nil, errors.New("no clients available")
+	LocalLogger.Info("Server started", "network", listener.Addr().Network(), "address", listener.Addr().String())
+	err = http.Serve(listener, routes)
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		log.Fatal("Error starting server: ", err)
 	}
+}
 
-	return handlers.NewHandler(openAIClient, ollamaClient), nil
+// loopbackOnly refuses requests from other hosts. Requests over a Unix
+// socket have no remote address and are always local.
+func loopbackOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if r.RemoteAddr != "" && r.RemoteAddr != "@" && (err != nil || !net.ParseIP(host).IsLoopback()) {
+			LocalLogger.Warn("Refused a connection from another host", "remote", r.RemoteAddr, "path", r.URL.Path)
+			http.Error(w, "Only local connections are accepted, start blab with -allowRemote to change this", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
 }
 
-func checkOllamaAvailability() bool {
-	resp, err := http.Get("http://localhost:11434")
+// initializeTracer routes provider traffic through a tracer when -trace is
+// set. It returns nil otherwise.
+func initializeTracer() (*trace.Tracer, error) {
+	if config.Trace == "" {
+		return nil, nil
+	}
+	tracer, err := trace.Open(config.Trace)
 	if err != nil {
-		LocalLogger.Error("Ollama server not available:", err)
-		return false
+		return nil, err
 	}
-	defer resp.Body.Close()
-	return resp.StatusCode == http.StatusOK
+	client.Transport = tracer.Transport(nil)
+	LocalLogger.Info("Tracing provider requests", "path", config.Trace)
+	return tracer, nil
 }
 
-// checkOpenAIAvailability verifies the API key by making requests to both /v1/models and /v1/chat/completions endpoints
-func checkOpenAIAvailability() bool {
-	openAIKey := os.Getenv("OPENAI_API_KEY")
-	if openAIKey == "" {
-		LocalLogger.Warn("OpenAI API key not provided.")
-		return false
+// initializeLimits applies -ollamaLimit and -openaiLimit to chat requests.
+func initializeLimits(handler *handlers.Handler) error {
+	for provider, spec := range map[string]string{"ollama": config.OllamaLimit, "openai": config.OpenAILimit} {
+		limits, err := limit.Parse(spec)
+		if err != nil {
+			return fmt.Errorf("%s limits: %w", provider, err)
+		}
+		handler.SetLimit(provider, limit.New(limits))
 	}
+	return nil
+}
 
-	// Check /v1/models endpoint
-	if !checkEndpoint(openAIKey, "https://api.openai.com/v1/models") {
-		return false
+// initializeProviders probes the providers once and attaches those that are
+// reachable. The server starts even when none is, and attaches them as the
+// monitor finds them or their keys are added.
+func initializeProviders() (*handlers.Handler, *health.Monitor, *providers) {
+	handler := handlers.NewHandler(nil, nil)
+	monitor := health.New(config.ProbeInterval, probeTimeout)
+	providers := newProviders(handler, monitor)
+
+	reachable := 0
+	for _, status := range monitor.Check(context.Background()) {
+		if status.Reachable {
+			reachable++
+		}
+	}
+	if reachable == 0 {
+		LocalLogger.Warn("No provider is reachable, waiting for one to come online")
 	}
+	handler.RefreshModels(context.Background())
+	return handler, monitor, providers
+}
 
-	return true
+func probeOllama(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost:11434", nil)
+	if err != nil {
+		return err
+	}
+	return probe(req)
 }
 
-func checkEndpoint(apiKey, url string) bool {
-	req, err := http.NewRequest("GET", url, nil)
+// checkEndpoint verifies the API key with a request to an endpoint that
+// requires it, such as /v1/models.
+func checkEndpoint(ctx context.Context, apiKey, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		LocalLogger.Error("Failed to create request:", err)
-		return false
+		return err
 	}
 	req.Header.Add("Authorization", "Bearer "+apiKey)
 	req.Header.Add("Content-Type", "application/json")
+	return probe(req)
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil || resp.StatusCode != http.StatusOK {
-		LocalLogger.Error("Failed to access endpoint:", url, "Status:", resp.Status)
-		return false
+// probe sends req without tracing it, so periodic probes do not crowd the
+// trace, and fails unless the answer is 200 OK.
+func probe(req *http.Request) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
 	}
 	defer resp.Body.Close()
-	return true
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered %s", req.URL.Redacted(), resp.Status)
+	}
+	return nil
 }
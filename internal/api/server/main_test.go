@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bz888/blab/internal/config"
+	"github.com/bz888/blab/pkg/blabclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoopbackOnly(t *testing.T) {
+	Init()
+	routes := loopbackOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for remote, want := range map[string]int{
+		"127.0.0.1:51234":   http.StatusNoContent,
+		"[::1]:51234":       http.StatusNoContent,
+		"":                  http.StatusNoContent, // Unix socket
+		"@":                 http.StatusNoContent, // Unix socket on Linux
+		"192.168.1.20:5123": http.StatusForbidden,
+		"[fe80::1]:51234":   http.StatusForbidden,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/models", nil)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, req)
+		assert.Equal(t, want, rec.Code, remote)
+	}
+}
+
+func TestListenUnixSocket(t *testing.T) {
+	Init()
+	path := filepath.Join(t.TempDir(), "blab.sock")
+	previous := config.Listen
+	config.Listen = "unix:" + path
+	t.Cleanup(func() { config.Listen = previous })
+
+	// A socket left behind by an instance that did not exit cleanly.
+	stale, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	_, err = os.Stat(path)
+	require.NoError(t, err)
+
+	listener, err := Listen()
+	require.NoError(t, err)
+	defer listener.Close()
+
+	_, err = Listen()
+	assert.ErrorContains(t, err, "another blab is listening")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/providers", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"provider":"ollama","reachable":true}]`))
+	})
+	go http.Serve(listener, loopbackOnly(mux))
+
+	client, err := blabclient.New(Address(listener.Addr()))
+	require.NoError(t, err)
+	statuses, err := client.Providers(context.Background())
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	assert.True(t, statuses[0].Reachable)
+}
+
+func TestListenEphemeralPort(t *testing.T) {
+	previous := config.Listen
+	config.Listen = "127.0.0.1:0"
+	t.Cleanup(func() { config.Listen = previous })
+
+	first, err := Listen()
+	require.NoError(t, err)
+	defer first.Close()
+	second, err := Listen()
+	require.NoError(t, err, "two instances do not collide")
+	defer second.Close()
+	assert.NotEqual(t, first.Addr().String(), second.Addr().String())
+}
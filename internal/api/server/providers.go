@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/bz888/blab/internal/api/server/client"
+	"github.com/bz888/blab/internal/api/server/handlers"
+	"github.com/bz888/blab/internal/api/server/health"
+	"net/http"
+	"os"
+	"sync"
+)
+
+const openAIModelsURL = "https://api.openai.com/v1/models"
+
+// providers attaches a provider's client to the handler whenever the monitor
+// finds it reachable, so the server can start before any provider is up.
+type providers struct {
+	handler *handlers.Handler
+	monitor *health.Monitor
+
+	mu        sync.Mutex
+	openAIKey string
+}
+
+// newProviders registers a probe for Ollama, and for OpenAI when an API key
+// is set. Nothing is attached until the monitor checks them.
+func newProviders(handler *handlers.Handler, monitor *health.Monitor) *providers {
+	p := &providers{handler: handler, monitor: monitor, openAIKey: os.Getenv("OPENAI_API_KEY")}
+	monitor.Register("ollama", probeOllama)
+	if p.openAIKey == "" {
+		LocalLogger.Warn("OpenAI API key not provided.")
+	} else {
+		p.registerOpenAI(p.openAIKey)
+	}
+	monitor.OnChange(p.changed)
+	return p
+}
+
+func (p *providers) registerOpenAI(apiKey string) {
+	p.monitor.Register("openai", func(ctx context.Context) error {
+		return checkEndpoint(ctx, apiKey, openAIModelsURL)
+	})
+}
+
+// changed attaches a provider that came online with a new client, which also
+// has its models fetched again.
+func (p *providers) changed(status health.Status) {
+	if !status.Reachable {
+		LocalLogger.Warn("Provider is unreachable", "provider", status.Provider, "err", status.Error)
+		return
+	}
+
+	switch status.Provider {
+	case "ollama":
+		p.handler.AttachOllama(client.NewOllamaClient())
+	case "openai":
+		p.mu.Lock()
+		apiKey := p.openAIKey
+		p.mu.Unlock()
+		p.handler.AttachOpenAI(client.NewOpenAIClient(apiKey))
+	default:
+		return
+	}
+	LocalLogger.Info("Provider attached", "provider", status.Provider, "latency", status.Latency)
+}
+
+// AddHandler sets the API key of a provider, or has Ollama probed now rather
+// than at the next interval, and answers with the provider's status. A key
+// is only kept when the provider accepts it.
+func (p *providers) AddHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var providerReq client.ProviderRequest
+	if err := json.NewDecoder(r.Body).Decode(&providerReq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch providerReq.Provider {
+	case "openai":
+		if providerReq.APIKey == "" {
+			http.Error(w, "An API key is required for openai", http.StatusBadRequest)
+			return
+		}
+		if err := checkEndpoint(r.Context(), providerReq.APIKey, openAIModelsURL); err != nil {
+			http.Error(w, "The API key was not accepted: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		p.mu.Lock()
+		p.openAIKey = providerReq.APIKey
+		p.mu.Unlock()
+		p.registerOpenAI(providerReq.APIKey)
+	case "ollama":
+	default:
+		http.Error(w, fmt.Sprintf("Unknown provider %q, use ollama or openai", providerReq.Provider), http.StatusBadRequest)
+		return
+	}
+
+	for _, status := range p.monitor.Check(r.Context()) {
+		if status.Provider != providerReq.Provider {
+			continue
+		}
+		if !status.Reachable {
+			http.Error(w, fmt.Sprintf("%s is not reachable: %s", status.Provider, status.Error), http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+		return
+	}
+}
@@ -0,0 +1,254 @@
+// Package registry caches the models each provider offers and refreshes
+// them once they are older than a TTL.
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bz888/blab/internal/api/server/client"
+)
+
+var ErrNotFound = errors.New("model not found")
+
+// CollisionError is returned when more than one provider offers a model
+// with the same name.
+type CollisionError struct {
+	Model     string
+	Providers []string
+}
+
+func (e *CollisionError) Error() string {
+	ids := make([]string, len(e.Providers))
+	for i, provider := range e.Providers {
+		ids[i] = ID(provider, e.Model)
+	}
+	return fmt.Sprintf("model %q is offered by %s, use %s", e.Model, strings.Join(e.Providers, " and "), strings.Join(ids, " or "))
+}
+
+// ID is the provider-qualified identifier of a model, e.g. "ollama/llama3".
+func ID(provider, name string) string {
+	return provider + "/" + name
+}
+
+// Lister fetches the current models of one provider.
+type Lister func(ctx context.Context) ([]client.ModelInfo, error)
+
+// Registry holds the models of every provider. It is safe for concurrent use.
+type Registry struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu        sync.RWMutex
+	providers map[string]*provider
+}
+
+type provider struct {
+	list Lister
+
+	// refresh serialises fetches so concurrent requests for stale models
+	// share a single call to the provider.
+	refresh sync.Mutex
+
+	// Guarded by Registry.mu.
+	models  []client.ModelInfo
+	fetched time.Time
+	fetches int // counts completed fetches
+	err     error
+}
+
+// New creates a registry whose models are fetched again once older than ttl.
+func New(ttl time.Duration) *Registry {
+	return &Registry{ttl: ttl, now: time.Now, providers: map[string]*provider{}}
+}
+
+// Register adds a provider, replacing any provider of the same name.
+func (r *Registry) Register(name string, list Lister) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[name] = &provider{list: list}
+}
+
+// Providers returns the registered provider names, sorted.
+func (r *Registry) Providers() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sortedNames()
+}
+
+// Models returns every provider's models sorted by provider and name,
+// fetching those that are stale first. A provider that cannot be reached
+// keeps the models it last returned.
+func (r *Registry) Models(ctx context.Context) []client.ModelInfo {
+	r.refreshAll(ctx, false)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var models []client.ModelInfo
+	for _, name := range r.sortedNames() {
+		models = append(models, r.providers[name].models...)
+	}
+	return models
+}
+
+// Refresh fetches every provider's models now, regardless of their age.
+func (r *Registry) Refresh(ctx context.Context) error {
+	return r.refreshAll(ctx, true)
+}
+
+// Invalidate marks a provider's models stale, so the next use fetches them.
+func (r *Registry) Invalidate(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.providers[name]; ok {
+		p.fetched = time.Time{}
+	}
+}
+
+// Split separates a provider-qualified identifier into the provider and
+// model name. The provider is empty when id does not start with a registered
+// provider, as model names may contain slashes themselves.
+func (r *Registry) Split(id string) (provider, name string) {
+	prefix, rest, ok := strings.Cut(id, "/")
+	if !ok {
+		return "", id
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if _, registered := r.providers[prefix]; !registered {
+		return "", id
+	}
+	return prefix, rest
+}
+
+// Lookup finds a model by its provider-qualified identifier, or by its bare
+// name when only one provider offers it. It returns ErrNotFound when no
+// provider offers it and a *CollisionError when a bare name is offered by
+// several.
+func (r *Registry) Lookup(ctx context.Context, id string) (client.ModelInfo, error) {
+	models := r.Models(ctx)
+	if provider, name := r.Split(id); provider != "" {
+		for _, model := range models {
+			if model.Provider == provider && model.Name == name {
+				return model, nil
+			}
+		}
+		// Not qualified after all, but a bare name with a slash in it.
+	}
+
+	name := id
+	var found []client.ModelInfo
+	for _, model := range models {
+		if model.Name == name {
+			found = append(found, model)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return client.ModelInfo{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+	case 1:
+		return found[0], nil
+	}
+	collision := &CollisionError{Model: name}
+	for _, model := range found {
+		collision.Providers = append(collision.Providers, model.Provider)
+	}
+	return client.ModelInfo{}, collision
+}
+
+// Collisions maps each model name offered by more than one provider to
+// those providers.
+func (r *Registry) Collisions(ctx context.Context) map[string][]string {
+	providers := map[string][]string{}
+	for _, model := range r.Models(ctx) {
+		providers[model.Name] = append(providers[model.Name], model.Provider)
+	}
+	collisions := map[string][]string{}
+	for name, names := range providers {
+		if len(names) > 1 {
+			collisions[name] = names
+		}
+	}
+	return collisions
+}
+
+func (r *Registry) sortedNames() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// refreshAll fetches the stale providers, or all of them when force is set,
+// concurrently. It returns the errors of the providers that failed.
+func (r *Registry) refreshAll(ctx context.Context, force bool) error {
+	r.mu.RLock()
+	providers := make(map[string]*provider, len(r.providers))
+	for name, p := range r.providers {
+		providers[name] = p
+	}
+	r.mu.RUnlock()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for name, p := range providers {
+		wg.Add(1)
+		go func(name string, p *provider) {
+			defer wg.Done()
+			if err := r.refresh(ctx, name, p, force); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				mu.Unlock()
+			}
+		}(name, p)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (r *Registry) refresh(ctx context.Context, name string, p *provider, force bool) error {
+	r.mu.RLock()
+	fetches := p.fetches
+	r.mu.RUnlock()
+
+	p.refresh.Lock()
+	defer p.refresh.Unlock()
+
+	r.mu.RLock()
+	fetched, lastErr, fetchedMeanwhile := p.fetched, p.err, p.fetches != fetches
+	r.mu.RUnlock()
+	// Someone else fetched while we waited, or the models are still fresh.
+	if fetchedMeanwhile && !fetched.IsZero() || !force && r.fresh(fetched) {
+		return lastErr
+	}
+
+	models, err := p.list(ctx)
+	for i := range models {
+		models[i].Provider = name
+		models[i].ID = ID(name, models[i].Name)
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].Name < models[j].Name })
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p.fetched, p.err = r.now(), err
+	p.fetches++
+	if err == nil {
+		p.models = models
+	}
+	return err
+}
+
+func (r *Registry) fresh(fetched time.Time) bool {
+	return !fetched.IsZero() && r.now().Sub(fetched) < r.ttl
+}
@@ -0,0 +1,194 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bz888/blab/internal/api/server/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProvider returns its models and counts how often it was asked.
+type fakeProvider struct {
+	mu     sync.Mutex
+	models []string
+	err    error
+	calls  atomic.Int32
+	delay  time.Duration
+}
+
+func (p *fakeProvider) set(err error, models ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.models, p.err = models, err
+}
+
+func (p *fakeProvider) list(context.Context) ([]client.ModelInfo, error) {
+	p.calls.Add(1)
+	time.Sleep(p.delay)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return nil, p.err
+	}
+	var models []client.ModelInfo
+	for _, name := range p.models {
+		models = append(models, client.ModelInfo{Name: name})
+	}
+	return models, nil
+}
+
+type clock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *clock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func newRegistry() (*Registry, *clock) {
+	c := &clock{t: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	r := New(time.Minute)
+	r.now = c.now
+	return r, c
+}
+
+func names(models []client.ModelInfo) []string {
+	var out []string
+	for _, model := range models {
+		out = append(out, model.Provider+"/"+model.Name)
+	}
+	return out
+}
+
+func TestRegistryTTL(t *testing.T) {
+	r, c := newRegistry()
+	ollama := &fakeProvider{models: []string{"llama3"}}
+	r.Register("ollama", ollama.list)
+	ctx := context.Background()
+
+	assert.Equal(t, []string{"ollama/llama3"}, names(r.Models(ctx)))
+
+	ollama.set(nil, "llama3", "mistral")
+	c.advance(59 * time.Second)
+	assert.Equal(t, []string{"ollama/llama3"}, names(r.Models(ctx)), "fresh models are reused")
+
+	c.advance(time.Second)
+	assert.Equal(t, []string{"ollama/llama3", "ollama/mistral"}, names(r.Models(ctx)))
+	assert.Equal(t, int32(2), ollama.calls.Load())
+}
+
+func TestRegistryRefreshAndInvalidate(t *testing.T) {
+	r, _ := newRegistry()
+	ollama := &fakeProvider{models: []string{"llama3"}}
+	r.Register("ollama", ollama.list)
+	ctx := context.Background()
+
+	r.Models(ctx)
+	ollama.set(nil, "mistral")
+	require.NoError(t, r.Refresh(ctx))
+	assert.Equal(t, []string{"ollama/mistral"}, names(r.Models(ctx)))
+
+	ollama.set(nil, "phi3")
+	r.Invalidate("ollama")
+	assert.Equal(t, []string{"ollama/phi3"}, names(r.Models(ctx)))
+	assert.Equal(t, int32(3), ollama.calls.Load())
+}
+
+func TestRegistryKeepsModelsOnError(t *testing.T) {
+	r, _ := newRegistry()
+	ollama := &fakeProvider{models: []string{"llama3"}}
+	openai := &fakeProvider{models: []string{"gpt-4o"}}
+	r.Register("ollama", ollama.list)
+	r.Register("openai", openai.list)
+	ctx := context.Background()
+
+	r.Models(ctx)
+	openai.set(errors.New("unauthorized"))
+	err := r.Refresh(ctx)
+	assert.ErrorContains(t, err, "openai: unauthorized")
+	assert.Equal(t, []string{"ollama/llama3", "openai/gpt-4o"}, names(r.Models(ctx)))
+}
+
+func TestRegistryLookup(t *testing.T) {
+	r, _ := newRegistry()
+	ollama := &fakeProvider{models: []string{"llama3", "shared"}}
+	openai := &fakeProvider{models: []string{"gpt-4o", "shared"}}
+	r.Register("ollama", ollama.list)
+	r.Register("openai", openai.list)
+	ctx := context.Background()
+
+	model, err := r.Lookup(ctx, "gpt-4o")
+	require.NoError(t, err)
+	assert.Equal(t, "openai", model.Provider)
+
+	_, err = r.Lookup(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = r.Lookup(ctx, "shared")
+	var collision *CollisionError
+	require.ErrorAs(t, err, &collision)
+	assert.Equal(t, []string{"ollama", "openai"}, collision.Providers)
+	assert.Equal(t, map[string][]string{"shared": {"ollama", "openai"}}, r.Collisions(ctx))
+}
+
+func TestRegistryQualifiedLookup(t *testing.T) {
+	r, _ := newRegistry()
+	ollama := &fakeProvider{models: []string{"shared", "openai/gpt-oss"}}
+	openai := &fakeProvider{models: []string{"shared"}}
+	r.Register("ollama", ollama.list)
+	r.Register("openai", openai.list)
+	ctx := context.Background()
+
+	model, err := r.Lookup(ctx, "openai/shared")
+	require.NoError(t, err)
+	assert.Equal(t, client.ModelInfo{ID: "openai/shared", Name: "shared", Provider: "openai"}, model)
+
+	model, err = r.Lookup(ctx, "ollama/openai/gpt-oss")
+	require.NoError(t, err)
+	assert.Equal(t, "openai/gpt-oss", model.Name)
+
+	// A bare name that looks qualified still resolves.
+	model, err = r.Lookup(ctx, "openai/gpt-oss")
+	require.NoError(t, err)
+	assert.Equal(t, "ollama", model.Provider)
+
+	_, err = r.Lookup(ctx, "openai/missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	provider, name := r.Split("hf.co/user/model")
+	assert.Equal(t, "", provider)
+	assert.Equal(t, "hf.co/user/model", name)
+}
+
+func TestRegistryConcurrentUse(t *testing.T) {
+	r, _ := newRegistry()
+	ollama := &fakeProvider{models: []string{"llama3"}, delay: 20 * time.Millisecond}
+	r.Register("ollama", ollama.list)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Len(t, r.Models(ctx), 1)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), ollama.calls.Load(), "concurrent requests share one fetch")
+}
@@ -1,11 +1,24 @@
 package server
 
 import (
+	"github.com/bz888/blab/internal/api/server/auth"
 	"github.com/bz888/blab/internal/api/server/handlers"
+	"github.com/bz888/blab/internal/api/server/health"
+	"github.com/bz888/blab/internal/api/server/trace"
 	"net/http"
 )
 
-func registerRoutes(handler *handlers.Handler) {
+func registerRoutes(handler *handlers.Handler, tracer *trace.Tracer, monitor *health.Monitor, providers *providers) {
 	http.HandleFunc("/chat", handler.ProcessTextHandler)
+	http.HandleFunc("/sessions", handler.SessionsHandler)
+	http.HandleFunc("/sessions/", handler.SessionHandler)
 	http.HandleFunc("/models", handler.ModelHandler)
+	http.HandleFunc("/models/info", handler.ModelInfoHandler)
+	http.HandleFunc("/models/refresh", auth.RequireUnrestricted(handler.RefreshModelsHandler))
+	http.HandleFunc("/models/pull", auth.RequireUnrestricted(handler.PullModelHandler))
+	http.HandleFunc("/models/delete", auth.RequireUnrestricted(handler.DeleteModelHandler))
+	http.HandleFunc("/trace/last", auth.RequireUnrestricted(tracer.LastHandler))
+	http.HandleFunc("/healthz", monitor.HealthzHandler)
+	http.HandleFunc("/providers", monitor.ProvidersHandler)
+	http.HandleFunc("/providers/add", auth.RequireUnrestricted(providers.AddHandler))
 }
@@ -0,0 +1,176 @@
+// Package session keeps the conversation of each chat session on the
+// server, so a chat request only carries the new message.
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bz888/blab/internal/api/server/client"
+)
+
+// Default is the session of chat requests that name none. Each owner has
+// its own, created on first use and again after it is deleted or expires.
+const Default = "default"
+
+var ErrNotFound = errors.New("session not found")
+
+// Session is a conversation: the messages of the user and the model's
+// replies, in order.
+type Session struct {
+	ID       string                     `json:"id"`
+	Created  time.Time                  `json:"created"`
+	Updated  time.Time                  `json:"updated"`
+	Messages []client.ServerChatMessage `json:"messages"`
+}
+
+// key identifies a session. Owners only see their own sessions, so the same
+// id, such as Default, can belong to several owners.
+type key struct {
+	owner string
+	id    string
+}
+
+// Store holds the sessions in memory. Sessions unused for longer than the
+// idle TTL are dropped, and the least recently used ones make way once the
+// store is full. It is safe for concurrent use.
+type Store struct {
+	max     int
+	idleTTL time.Duration
+	now     func() time.Time
+
+	mu       sync.Mutex
+	sessions map[key]*Session
+}
+
+// NewStore keeps at most max sessions, each for idleTTL after its last use.
+// Zero means no limit.
+func NewStore(max int, idleTTL time.Duration) *Store {
+	return &Store{max: max, idleTTL: idleTTL, now: time.Now, sessions: map[key]*Session{}}
+}
+
+// Create starts an empty session of owner with a new random id.
+func (s *Store) Create(owner string) (Session, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return Session{}, fmt.Errorf("creating session id: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.add(key{owner, hex.EncodeToString(id)}).copy(), nil
+}
+
+// Get returns a copy of one of owner's sessions.
+func (s *Store) Get(owner, id string) (Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, err := s.lookup(owner, id)
+	if err != nil {
+		return Session{}, err
+	}
+	return session.copy(), nil
+}
+
+// List returns a copy of each of owner's sessions, oldest first.
+func (s *Store) List(owner string) []Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire()
+	sessions := make([]Session, 0)
+	for k, session := range s.sessions {
+		if k.owner == owner {
+			sessions = append(sessions, session.copy())
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].Created.Before(sessions[j].Created) })
+	return sessions
+}
+
+// Delete forgets one of owner's sessions.
+func (s *Store) Delete(owner, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire()
+	k := key{owner, id}
+	if _, ok := s.sessions[k]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	delete(s.sessions, k)
+	return nil
+}
+
+// Append adds messages to one of owner's sessions and returns its whole
+// conversation.
+func (s *Store) Append(owner, id string, messages ...client.ServerChatMessage) ([]client.ServerChatMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, err := s.lookup(owner, id)
+	if err != nil {
+		return nil, err
+	}
+	session.Messages = append(session.Messages, messages...)
+	session.Updated = s.now()
+	return session.copy().Messages, nil
+}
+
+// lookup finds a session, creating owner's default one. s.mu must be held.
+func (s *Store) lookup(owner, id string) (*Session, error) {
+	s.expire()
+	if id == "" {
+		id = Default
+	}
+	k := key{owner, id}
+	session, ok := s.sessions[k]
+	if !ok && id == Default {
+		return s.add(k), nil
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return session, nil
+}
+
+// add stores a new session, evicting the least recently used one when the
+// store is full. s.mu must be held.
+func (s *Store) add(k key) *Session {
+	s.expire()
+	if s.max > 0 && len(s.sessions) >= s.max {
+		var oldest key
+		first := true
+		for k, session := range s.sessions {
+			if first || session.Updated.Before(s.sessions[oldest].Updated) {
+				oldest, first = k, false
+			}
+		}
+		delete(s.sessions, oldest)
+	}
+	now := s.now()
+	session := &Session{ID: k.id, Created: now, Updated: now, Messages: []client.ServerChatMessage{}}
+	s.sessions[k] = session
+	return session
+}
+
+// expire drops the sessions idle for longer than the TTL. s.mu must be held.
+func (s *Store) expire() {
+	if s.idleTTL <= 0 {
+		return
+	}
+	cutoff := s.now().Add(-s.idleTTL)
+	for k, session := range s.sessions {
+		if session.Updated.Before(cutoff) {
+			delete(s.sessions, k)
+		}
+	}
+}
+
+func (s *Session) copy() Session {
+	c := *s
+	c.Messages = append([]client.ServerChatMessage{}, s.Messages...)
+	return c
+}
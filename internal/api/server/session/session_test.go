@@ -0,0 +1,104 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bz888/blab/internal/api/server/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	store := NewStore(0, 0)
+
+	created, err := store.Create("alice")
+	require.NoError(t, err)
+	assert.Len(t, created.ID, 16)
+	assert.Empty(t, created.Messages)
+
+	user := client.ServerChatMessage{Role: client.RoleUser, Content: "hi"}
+	history, err := store.Append("alice", created.ID, user)
+	require.NoError(t, err)
+	assert.Equal(t, []client.ServerChatMessage{user}, history)
+
+	history[0].Content = "changed"
+	session, err := store.Get("alice", created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "hi", session.Messages[0].Content, "callers get copies")
+
+	_, err = store.Append("alice", "missing", user)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, store.Delete("alice", created.ID))
+	_, err = store.Get("alice", created.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, store.Delete("alice", created.ID), ErrNotFound)
+}
+
+func TestOwners(t *testing.T) {
+	store := NewStore(0, 0)
+	created, err := store.Create("alice")
+	require.NoError(t, err)
+
+	assert.Len(t, store.List("alice"), 1)
+	assert.Empty(t, store.List("bob"), "owners only see their own sessions")
+	_, err = store.Get("bob", created.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = store.Append("bob", created.ID, client.ServerChatMessage{Role: client.RoleUser, Content: "hi"})
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, store.Delete("bob", created.ID), ErrNotFound)
+
+	_, err = store.Append("alice", "", client.ServerChatMessage{Role: client.RoleUser, Content: "secret"})
+	require.NoError(t, err)
+	history, err := store.Append("bob", "", client.ServerChatMessage{Role: client.RoleUser, Content: "hi"})
+	require.NoError(t, err)
+	assert.Len(t, history, 1, "each owner has their own default session")
+}
+
+func TestDefaultSession(t *testing.T) {
+	store := NewStore(0, 0)
+	assert.Empty(t, store.List(""))
+
+	_, err := store.Append("", "", client.ServerChatMessage{Role: client.RoleUser, Content: "hi"})
+	require.NoError(t, err)
+	session, err := store.Get("", Default)
+	require.NoError(t, err)
+	assert.Len(t, session.Messages, 1)
+
+	require.NoError(t, store.Delete("", Default))
+	session, err = store.Get("", "")
+	require.NoError(t, err)
+	assert.Empty(t, session.Messages, "the default session starts over")
+}
+
+func TestEviction(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	store := NewStore(2, time.Hour)
+	store.now = func() time.Time { return now }
+
+	first, err := store.Create("alice")
+	require.NoError(t, err)
+	now = now.Add(time.Minute)
+	second, err := store.Create("alice")
+	require.NoError(t, err)
+	now = now.Add(time.Minute)
+	_, err = store.Append("alice", first.ID, client.ServerChatMessage{Role: client.RoleUser, Content: "hi"})
+	require.NoError(t, err)
+
+	// The store is full, so the least recently used session makes way.
+	now = now.Add(time.Minute)
+	third, err := store.Create("alice")
+	require.NoError(t, err)
+	_, err = store.Get("alice", second.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = store.Get("alice", first.ID)
+	assert.NoError(t, err)
+
+	// Sessions idle for longer than the TTL are dropped.
+	now = now.Add(time.Hour - time.Second)
+	_, err = store.Get("alice", third.ID)
+	assert.NoError(t, err)
+	now = now.Add(2 * time.Minute)
+	assert.Empty(t, store.List("alice"))
+}
@@ -0,0 +1,251 @@
+// Package trace records the traffic between the server and the model
+// providers: every request with its secrets redacted, the response status,
+// timing and each raw chunk of the streamed body.
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const redacted = "REDACTED"
+
+// Exchange is one traced request and its response.
+type Exchange struct {
+	ID     int64     `json:"id"`
+	Start  time.Time `json:"start"`
+	Method string    `json:"method"`
+	URL    string    `json:"url"`
+
+	RequestHeader http.Header `json:"request_header"`
+	RequestBody   string      `json:"request_body,omitempty"`
+
+	Status         string      `json:"status,omitempty"`
+	ResponseHeader http.Header `json:"response_header,omitempty"`
+	// Headers is how long the provider took to answer with headers;
+	// Duration also includes reading the whole body.
+	Headers  time.Duration `json:"headers"`
+	Duration time.Duration `json:"duration"`
+	Chunks   []Chunk       `json:"chunks,omitempty"`
+	Err      string        `json:"error,omitempty"`
+}
+
+// Chunk is one read of the response body, as it arrived.
+type Chunk struct {
+	// At is the time since the request started.
+	At   time.Duration `json:"at"`
+	Data string        `json:"data"`
+}
+
+// Tracer writes finished exchanges to a file as JSON lines and remembers the
+// most recent one.
+type Tracer struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+	next int64
+	last *Exchange
+}
+
+// Open appends traces to the file at path.
+func Open(path string) (*Tracer, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trace file: %w", err)
+	}
+	return &Tracer{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+// Last returns the most recently finished exchange.
+func (t *Tracer) Last() (Exchange, bool) {
+	if t == nil {
+		return Exchange{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.last == nil {
+		return Exchange{}, false
+	}
+	return *t.last, true
+}
+
+func (t *Tracer) Close() error {
+	return t.file.Close()
+}
+
+func (t *Tracer) finish(e *Exchange) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.last = e
+	// The trace is a debugging aid; failing to write it must not fail the
+	// request.
+	_ = t.enc.Encode(e)
+}
+
+// Transport wraps base, or http.DefaultTransport when it is nil, so every
+// request made through it is traced.
+func (t *Tracer) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{tracer: t, base: base}
+}
+
+type transport struct {
+	tracer *Tracer
+	base   http.RoundTripper
+}
+
+func (rt *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.tracer.mu.Lock()
+	rt.tracer.next++
+	id := rt.tracer.next
+	rt.tracer.mu.Unlock()
+
+	e := &Exchange{
+		ID:            id,
+		Start:         time.Now(),
+		Method:        req.Method,
+		URL:           redactURL(req.URL),
+		RequestHeader: redactHeader(req.Header),
+	}
+
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		e.RequestBody = string(body)
+		// RoundTrip must not modify the caller's request.
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	resp, err := rt.base.RoundTrip(req)
+	e.Headers = time.Since(e.Start)
+	if err != nil {
+		e.Err = err.Error()
+		e.Duration = e.Headers
+		rt.tracer.finish(e)
+		return nil, err
+	}
+
+	e.Status = resp.Status
+	e.ResponseHeader = redactHeader(resp.Header)
+	resp.Body = &body{ReadCloser: resp.Body, tracer: rt.tracer, exchange: e}
+	return resp, nil
+}
+
+// body records each read and finishes the exchange at EOF, on an error, or
+// when closed early.
+type body struct {
+	io.ReadCloser
+	tracer   *Tracer
+	exchange *Exchange
+	once     sync.Once
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.exchange.Chunks = append(b.exchange.Chunks, Chunk{
+			At:   time.Since(b.exchange.Start),
+			Data: string(p[:n]),
+		})
+	}
+	if err != nil {
+		if err != io.EOF {
+			b.exchange.Err = err.Error()
+		}
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *body) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+	return err
+}
+
+func (b *body) finish() {
+	b.once.Do(func() {
+		b.exchange.Duration = time.Since(b.exchange.Start)
+		b.tracer.finish(b.exchange)
+	})
+}
+
+// secret reports whether a header or query parameter may carry a credential.
+func secret(name string) bool {
+	name = strings.ToLower(name)
+	switch name {
+	case "authorization", "proxy-authorization", "cookie", "set-cookie":
+		return true
+	}
+	for _, word := range []string{"key", "token", "secret", "password"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactHeader(header http.Header) http.Header {
+	out := header.Clone()
+	for name, values := range out {
+		if !secret(name) {
+			continue
+		}
+		for i, value := range values {
+			// Keep the scheme so "Bearer" versus "Basic" is still visible.
+			if scheme, _, ok := strings.Cut(value, " "); ok && strings.EqualFold(name, "authorization") {
+				values[i] = scheme + " " + redacted
+			} else {
+				values[i] = redacted
+			}
+		}
+	}
+	return out
+}
+
+func redactURL(u *url.URL) string {
+	query := u.Query()
+	changed := false
+	for name := range query {
+		if secret(name) {
+			query.Set(name, redacted)
+			changed = true
+		}
+	}
+	if !changed {
+		return u.String()
+	}
+	clean := *u
+	clean.RawQuery = query.Encode()
+	return clean.String()
+}
+
+// LastHandler serves the most recent exchange as JSON. It answers 404 when
+// tracing is off or nothing has been traced yet.
+func (t *Tracer) LastHandler(w http.ResponseWriter, r *http.Request) {
+	if t == nil {
+		http.Error(w, "tracing is disabled, start blab with -trace", http.StatusNotFound)
+		return
+	}
+	e, ok := t.Last()
+	if !ok {
+		http.Error(w, "no provider request has been traced yet", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(e)
+}
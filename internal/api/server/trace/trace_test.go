@@ -0,0 +1,114 @@
+package trace
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTracer(t *testing.T) (*Tracer, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "trace.jsonl")
+	tracer, err := Open(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { tracer.Close() })
+	return tracer, path
+}
+
+func TestTransportRecordsStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, `{"model":"llama3"}`, string(body), "provider still receives the body")
+		assert.Equal(t, "Bearer sk-secret", r.Header.Get("Authorization"), "provider still receives the key")
+
+		flusher := w.(http.Flusher)
+		for _, line := range []string{`{"done":false}`, `{"done":true}`} {
+			io.WriteString(w, line+"\n")
+			flusher.Flush()
+		}
+	}))
+	defer server.Close()
+
+	tracer, path := newTracer(t)
+	client := &http.Client{Transport: tracer.Transport(nil)}
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/api/chat?key=abc&stream=true", strings.NewReader(`{"model":"llama3"}`))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer sk-secret")
+	req.Header.Set("X-Api-Key", "sk-other")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "{\"done\":false}\n{\"done\":true}\n", string(body))
+
+	e, ok := tracer.Last()
+	require.True(t, ok)
+	assert.Equal(t, int64(1), e.ID)
+	assert.Equal(t, http.MethodPost, e.Method)
+	assert.Equal(t, server.URL+"/api/chat?key=REDACTED&stream=true", e.URL)
+	assert.Equal(t, "Bearer REDACTED", e.RequestHeader.Get("Authorization"))
+	assert.Equal(t, "REDACTED", e.RequestHeader.Get("X-Api-Key"))
+	assert.Equal(t, `{"model":"llama3"}`, e.RequestBody)
+	assert.Equal(t, "200 OK", e.Status)
+	assert.GreaterOrEqual(t, e.Duration, e.Headers)
+
+	var streamed strings.Builder
+	for _, chunk := range e.Chunks {
+		streamed.WriteString(chunk.Data)
+	}
+	assert.Equal(t, string(body), streamed.String())
+
+	// The same exchange is in the trace file, once, without the keys.
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "sk-")
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 1)
+	var line Exchange
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &line))
+	assert.Equal(t, e.URL, line.URL)
+}
+
+func TestTransportRecordsErrors(t *testing.T) {
+	tracer, _ := newTracer(t)
+	client := &http.Client{Transport: tracer.Transport(nil)}
+
+	_, err := client.Get("http://127.0.0.1:1/unreachable")
+	require.Error(t, err)
+
+	e, ok := tracer.Last()
+	require.True(t, ok)
+	assert.NotEmpty(t, e.Err)
+	assert.Empty(t, e.Status)
+}
+
+func TestLastHandler(t *testing.T) {
+	var disabled *Tracer
+	rec := httptest.NewRecorder()
+	disabled.LastHandler(rec, httptest.NewRequest(http.MethodGet, "/trace/last", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "tracing is disabled")
+
+	tracer, _ := newTracer(t)
+	rec = httptest.NewRecorder()
+	tracer.LastHandler(rec, httptest.NewRequest(http.MethodGet, "/trace/last", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	tracer.finish(&Exchange{ID: 7, URL: "http://localhost:11434/api/chat"})
+	rec = httptest.NewRecorder()
+	tracer.LastHandler(rec, httptest.NewRequest(http.MethodGet, "/trace/last", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var e Exchange
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &e))
+	assert.Equal(t, int64(7), e.ID)
+}
@@ -0,0 +1,24 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Dir is where blab keeps its settings: the directory set with -configDir,
+// or $XDG_CONFIG_HOME/blab, or ~/.config/blab.
+func Dir() (string, error) {
+	if ConfigDir != "" {
+		return ConfigDir, nil
+	}
+	home := os.Getenv("XDG_CONFIG_HOME")
+	if !filepath.IsAbs(home) {
+		userHome, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("finding config directory: %w", err)
+		}
+		home = filepath.Join(userHome, ".config")
+	}
+	return filepath.Join(home, "blab"), nil
+}
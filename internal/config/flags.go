@@ -1,14 +1,65 @@
 package config
 
-import "flag"
+import (
+	"flag"
+	"time"
+)
 
 var (
-	Dev     bool
-	LogPath string
+	Dev           bool
+	LogPath       string
+	LogLevel      string
+	LogTags       string
+	LogMaxSize    int
+	LogMaxAge     time.Duration
+	LogKeep       int
+	LogGzip       bool
+	ConsoleLines  int
+	Trace         string
+	ConfigDir     string
+	Auth          bool
+	Listen        string
+	AllowRemote   bool
+	OllamaLimit   string
+	OpenAILimit   string
+	Model         string
+	ProbeInterval time.Duration
+	SileroPath    string
+	VAD           string
+	MicChannel    int
+	HighPass      float64
+	Denoise       bool
+	RecordDir     string
+	Language      string
+	LangPrompt    bool
 )
 
 func Init() {
 	flag.BoolVar(&Dev, "dev", false, "Development mode")
-	flag.StringVar(&LogPath, "logPath", "", "Path to save the log file")
+	flag.StringVar(&LogPath, "logPath", "", "Directory for log files, default $XDG_STATE_HOME/blab/logs")
+	flag.StringVar(&LogLevel, "logLevel", "info", "Minimum log level: debug, info, warn or error")
+	flag.StringVar(&LogTags, "logTags", "", "Per-tag log levels overriding -logLevel, e.g. speech=debug,google=warn")
+	flag.IntVar(&LogMaxSize, "logMaxSize", 10, "Start a new log file after this many megabytes, 0 for no limit")
+	flag.DurationVar(&LogMaxAge, "logMaxAge", 24*time.Hour, "Start a new log file after this long, 0 for no limit")
+	flag.IntVar(&LogKeep, "logKeep", 10, "Number of old log files to keep, 0 to keep all")
+	flag.BoolVar(&LogGzip, "logGzip", false, "Compress old log files with gzip")
+	flag.StringVar(&ConfigDir, "configDir", "", "Directory for settings such as API tokens, default $XDG_CONFIG_HOME/blab")
+	flag.BoolVar(&Auth, "auth", true, "Require a bearer token from the auth settings for the embedded server's API")
+	flag.StringVar(&Listen, "listen", "127.0.0.1:0", "Address of the embedded server, host:port (port 0 picks a free one) or unix:<path> for a Unix socket")
+	flag.BoolVar(&AllowRemote, "allowRemote", false, "Accept connections to the embedded server from other hosts")
+	flag.StringVar(&OllamaLimit, "ollamaLimit", "concurrency=2,queue=16", "Limits for chat requests to Ollama: concurrency, rate (per second, or e.g. 60/m), burst and queue")
+	flag.StringVar(&OpenAILimit, "openaiLimit", "concurrency=8,queue=32", "Limits for chat requests to OpenAI, as for -ollamaLimit")
+	flag.StringVar(&Model, "model", "llama3:latest", "Model to chat with, as provider/model or a bare name offered by one provider")
+	flag.DurationVar(&ProbeInterval, "probeInterval", 30*time.Second, "How often to check that the model providers are reachable, 0 to check only at startup")
+	flag.StringVar(&Trace, "trace", "", "Record provider requests and responses, with API keys redacted, to this file")
+	flag.IntVar(&ConsoleLines, "consoleLines", 2000, "Number of log entries the debug console keeps")
+	flag.StringVar(&VAD, "vad", "auto", "Voice activity detector: silero, flux, or auto to fall back to flux when silero is unavailable")
+	flag.IntVar(&MicChannel, "micChannel", -1, "Microphone channel to use, or -1 to downmix all channels")
+	flag.Float64Var(&HighPass, "highpass", 80, "High-pass filter cutoff in Hz applied before VAD, 0 to disable")
+	flag.BoolVar(&Denoise, "denoise", false, "Attenuate background noise before VAD")
+	flag.StringVar(&RecordDir, "recordDir", "", "Save each voice session's audio and transcripts under this directory")
+	flag.StringVar(&Language, "lang", "en-US", "Speech recognition language, or \"auto\" with 2 to 3 candidates, e.g. auto,en-US,de-DE; each candidate costs a recognition request per utterance")
+	flag.BoolVar(&LangPrompt, "langPrompt", false, "Ask the model to reply in the language voice input was recognised in")
+	flag.StringVar(&SileroPath, "sileroPath", "", "Path to a silero_vad.onnx model, overriding the embedded one")
 	flag.Parse()
 }
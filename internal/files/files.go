@@ -0,0 +1,68 @@
+package files
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SileroVAD is the pre-trained Silero voice activity detection model.
+const SileroVAD = "silero_vad.onnx"
+
+//go:embed silero_vad.onnx
+var assets embed.FS
+
+// Read returns the contents of an embedded asset.
+func Read(name string) ([]byte, error) {
+	return assets.ReadFile(name)
+}
+
+// Extract writes an embedded asset into dir and returns its path. Libraries
+// such as the ONNX runtime only load models from disk, so the asset is
+// copied out once and rewritten only when the embedded version changes.
+func Extract(name, dir string) (string, error) {
+	data, err := assets.ReadFile(name)
+	if err != nil {
+		return "", fmt.Errorf("embedded asset %s: %w", name, err)
+	}
+
+	path := filepath.Join(dir, name)
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+		return path, nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	// Write to a temporary file first so a concurrent run never loads a
+	// partially written model.
+	tmp, err := os.CreateTemp(dir, name+".*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// CacheDir is the default directory assets are extracted to.
+func CacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "blab", "assets"), nil
+}
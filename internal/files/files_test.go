@@ -0,0 +1,42 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtract(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "assets")
+	want, err := Read(SileroVAD)
+	require.NoError(t, err)
+	require.NotEmpty(t, want)
+
+	path, err := Extract(SileroVAD, dir)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, SileroVAD), path)
+
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	// A stale or truncated copy is replaced.
+	require.NoError(t, os.WriteFile(path, []byte("stale"), 0644))
+	_, err = Extract(SileroVAD, dir)
+	require.NoError(t, err)
+	got, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files are cleaned up")
+}
+
+func TestExtractUnknownAsset(t *testing.T) {
+	_, err := Extract("missing.onnx", t.TempDir())
+	assert.Error(t, err)
+}
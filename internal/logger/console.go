@@ -0,0 +1,74 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Entry is a record as the debug console shows it.
+type Entry struct {
+	Time    time.Time
+	Level   slog.Level
+	Tag     string
+	Message string
+	// Fields are the record's attributes as " key=value" pairs, with groups
+	// flattened into dotted keys.
+	Fields string
+}
+
+// Console displays log records. Add is called from the log worker, so it
+// must hand the entry over to the UI without blocking on it.
+type Console interface {
+	Add(Entry)
+}
+
+type consoleHandler struct {
+	console Console
+	level   slog.Level
+}
+
+func (h *consoleHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	h.console.Add(newEntry(r))
+	return nil
+}
+
+// Records arrive fully resolved from handler, so these are never used.
+func (h *consoleHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *consoleHandler) WithGroup(string) slog.Handler      { return h }
+
+func newEntry(r slog.Record) Entry {
+	entry := Entry{Time: r.Time, Level: r.Level, Message: r.Message}
+	var fields strings.Builder
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == "tag" {
+			entry.Tag = a.Value.String()
+			return true
+		}
+		writeAttr(&fields, "", a)
+		return true
+	})
+	entry.Fields = fields.String()
+	return entry
+}
+
+func writeAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, inner := range a.Value.Group() {
+			writeAttr(b, prefix, inner)
+		}
+		return
+	}
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	fmt.Fprintf(b, " %s%s=%v", prefix, a.Key, a.Value.Any())
+}
@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// ParseLevel reads debug, info, warn or error, in any case.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("invalid log level %q, expected debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+// ParseTagLevels reads per-tag levels written as "tag=level" pairs
+// separated by commas, e.g. "speech=debug,api client=warn".
+func ParseTagLevels(s string) (map[string]slog.Level, error) {
+	levels := map[string]slog.Level{}
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		tag, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(tag) == "" {
+			return nil, fmt.Errorf("invalid tag level %q, expected tag=level", pair)
+		}
+		level, err := ParseLevel(value)
+		if err != nil {
+			return nil, fmt.Errorf("tag %q: %w", strings.TrimSpace(tag), err)
+		}
+		levels[normalizeTag(tag)] = level
+	}
+	return levels, nil
+}
+
+func normalizeTags(levels map[string]slog.Level) map[string]slog.Level {
+	normalized := make(map[string]slog.Level, len(levels))
+	for tag, level := range levels {
+		normalized[normalizeTag(tag)] = level
+	}
+	return normalized
+}
+
+// normalizeTag makes tag matching case-insensitive, since tags in the code
+// are written inconsistently ("Ollama handler", "openai handler").
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
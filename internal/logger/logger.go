@@ -1,163 +1,295 @@
 package logger
 
 import (
-	"fmt"
-	"log"
+	"context"
+	"log/slog"
 	"os"
-	"path/filepath"
 	"sync"
-	"time"
-
-	"github.com/rivo/tview"
 )
 
-type Types int
-
-const (
-	Info Types = iota
-	Error
-	Warn
-	Fatal
-)
+// LevelFatal is logged by Fatal before the process exits.
+const LevelFatal = slog.LevelError + 4
 
-type Message struct {
-	Timestamp time.Time
-	Tag       string
-	Message   string
-	LogTypes  Types
+// Options configures the process-wide log output.
+type Options struct {
+	// Dev mirrors logs to stderr when there is no debug console.
+	Dev bool
+	// Path is the directory for the JSON log files; empty disables them.
+	Path   string
+	Rotate RotateConfig
+	// Console is the debug console.
+	Console Console
+	// ConsoleLevel is the minimum level sent to the debug console, even
+	// below Level and TagLevels, which it ignores.
+	ConsoleLevel slog.Level
+	// Level is the minimum level logged for tags without their own level.
+	Level slog.Level
+	// TagLevels overrides Level per tag.
+	TagLevels map[string]slog.Level
 }
 
+// Logger is a tagged, leveled logger. Arguments after the message are
+// key-value pairs or slog.Attr values, as with log/slog.
 type Logger struct {
-	view      *tview.TextView
-	tag       string
-	dev       bool
-	logFile   *os.File
-	logChan   chan Message
-	closeChan chan struct{}
+	tag string
+	log *slog.Logger
 }
 
 var (
-	logManager *Logger
-	once       sync.Once
+	mu      sync.RWMutex
+	current *manager // nil until InitLogger
+	once    sync.Once
 )
 
-func InitLogger(dev bool, logPath string, view *tview.TextView) {
+// InitLogger starts the log worker. Only the first call has any effect.
+// Loggers created before it discard their output until it runs.
+func InitLogger(opts Options) error {
+	var err error
 	once.Do(func() {
-		logManager = &Logger{
-			view:      view,
-			dev:       dev,
-			logChan:   make(chan Message, 100),
-			closeChan: make(chan struct{}),
-		}
-		if logPath != "" {
-			timestamp := time.Now().Format("20060102_150405")
-			fileName := fmt.Sprintf("blab_log_%s.log", timestamp)
-			filePath := filepath.Join(logPath, fileName)
-
-			file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-			if err != nil {
-				log.Fatalf("Failed to open log file: %s", err)
-			}
-			logManager.logFile = file
+		var m *manager
+		m, err = newManager(opts)
+		if err != nil {
+			return
 		}
-
-		go logManager.processLogs()
+		mu.Lock()
+		current = m
+		mu.Unlock()
 	})
+	return err
 }
 
 func NewLogger(tag string) *Logger {
-	return &Logger{
-		view:      logManager.view,
-		tag:       tag,
-		dev:       logManager.dev,
-		logFile:   logManager.logFile,
-		logChan:   logManager.logChan,
-		closeChan: logManager.closeChan,
+	return &Logger{tag: tag, log: slog.New(&handler{tag: tag})}
+}
+
+// With returns a logger that adds args to every record.
+func (l *Logger) With(args ...any) *Logger {
+	return &Logger{tag: l.tag, log: l.log.With(args...)}
+}
+
+func (l *Logger) Debug(msg string, args ...any) {
+	l.log.Debug(msg, args...)
+}
+
+func (l *Logger) Info(msg string, args ...any) {
+	l.log.Info(msg, args...)
+}
+
+func (l *Logger) Warn(msg string, args ...any) {
+	l.log.Warn(msg, args...)
+}
+
+func (l *Logger) Error(msg string, args ...any) {
+	l.log.Error(msg, args...)
+}
+
+// Fatal logs, flushes every pending record and exits the process.
+func (l *Logger) Fatal(msg string, args ...any) {
+	l.log.Log(context.Background(), LevelFatal, msg, args...)
+	l.Close()
+	os.Exit(1)
+}
+
+// Close drains queued records to their outputs and closes the log file.
+// Records logged afterwards are dropped.
+func (l *Logger) Close() {
+	mu.RLock()
+	m := current
+	mu.RUnlock()
+	if m != nil {
+		m.close()
 	}
 }
 
-func (l *Logger) processLogs() {
-	for msg := range l.logChan {
-		timestamp := msg.Timestamp.Format("2006-01-02 15:04:05")
-		logMessage := fmt.Sprintf("%s [%s] %s: %s\n", timestamp, msg.Tag, msg.LogTypes.toString(), msg.Message)
-		if l.logFile != nil {
-			l.logFile.WriteString(logMessage)
+// manager owns the outputs and the goroutine writing to them, so callers
+// never block on the file or the UI.
+type manager struct {
+	level     slog.Level
+	tagLevels map[string]slog.Level
+
+	sinks   []slog.Handler // outputs for the records passing level and tagLevels
+	console *consoleHandler
+	file    *rotatingFile
+
+	mu      sync.Mutex
+	closed  bool
+	records chan queued
+	done    chan struct{}
+}
+
+// queued is a record waiting for the log worker.
+type queued struct {
+	record slog.Record
+	// logged is set when the record passes the log levels, rather than only
+	// the console's.
+	logged bool
+}
+
+func newManager(opts Options) (*manager, error) {
+	m := &manager{
+		level:     opts.Level,
+		tagLevels: normalizeTags(opts.TagLevels),
+		records:   make(chan queued, 1024),
+		done:      make(chan struct{}),
+	}
+
+	if opts.Path != "" {
+		file, err := openRotatingFile(opts.Path, opts.Rotate)
+		if err != nil {
+			return nil, err
 		}
+		m.file = file
+		m.sinks = append(m.sinks, slog.NewJSONHandler(file, &slog.HandlerOptions{
+			Level:       slog.LevelDebug - 4,
+			ReplaceAttr: replaceLevel,
+		}))
 	}
+
+	switch {
+	case opts.Console != nil:
+		m.console = &consoleHandler{console: opts.Console, level: opts.ConsoleLevel}
+	case opts.Dev:
+		m.sinks = append(m.sinks, slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+			Level:       slog.LevelDebug - 4,
+			ReplaceAttr: replaceLevel,
+		}))
+	}
+
+	go m.run()
+	return m, nil
 }
 
-func (l *Logger) log(logTypes Types, v ...interface{}) {
-	message := fmt.Sprint(v...)
-	if l.dev {
-		if l.view != nil {
-			var format string
-			switch logTypes {
-			case Info:
-				format = "[green]DEBUG (%s): %s[-]\n"
-			case Error:
-				format = "[red]DEBUG (%s): %s[-]\n"
-			case Warn:
-				format = "[yellow]DEBUG (%s): %s[-]\n"
-			case Fatal:
-				format = "[red]DEBUG (%s): %s[-]\n"
-			}
-			fmt.Fprintf(l.view, format, l.tag, message)
-		} else {
-			switch logTypes {
-			case Fatal:
-				log.Fatal(v...)
-			default:
-				log.Println(v...)
+func (m *manager) enabled(tag string, level slog.Level) bool {
+	return m.logged(tag, level) || (m.console != nil && level >= m.console.level)
+}
+
+// logged reports whether a record passes the log levels, which apply to
+// every output but the debug console.
+func (m *manager) logged(tag string, level slog.Level) bool {
+	if min, ok := m.tagLevels[normalizeTag(tag)]; ok {
+		return level >= min
+	}
+	return level >= m.level
+}
+
+func (m *manager) enqueue(r slog.Record, logged bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return
+	}
+	m.records <- queued{record: r, logged: logged}
+}
+
+func (m *manager) run() {
+	defer close(m.done)
+	for q := range m.records {
+		if q.logged {
+			for _, sink := range m.sinks {
+				// A failing output must not stop the others.
+				_ = sink.Handle(context.Background(), q.record)
 			}
 		}
+		if m.console != nil && (q.logged || q.record.Level >= m.console.level) {
+			_ = m.console.Handle(context.Background(), q.record)
+		}
 	}
+}
 
-	if l.logFile != nil {
-		logMessage := Message{
-			Timestamp: time.Now(),
-			Tag:       l.tag,
-			Message:   message,
-			LogTypes:  logTypes,
-		}
-		l.logChan <- logMessage
+func (m *manager) close() {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		<-m.done
+		return
 	}
+	m.closed = true
+	close(m.records)
+	m.mu.Unlock()
+
+	<-m.done
+	if m.file != nil {
+		m.file.Close()
+	}
+}
+
+// handler tags records and queues them for the manager. It looks the
+// manager up on every record so loggers made before InitLogger work.
+type handler struct {
+	tag    string
+	attrs  []slog.Attr   // added outside any group
+	groups []string      // open groups, outermost first
+	nested [][]slog.Attr // attributes added inside each open group
 }
 
-func (l *Logger) Info(v ...interface{}) {
-	l.log(Info, v...)
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	mu.RLock()
+	m := current
+	mu.RUnlock()
+	return m != nil && m.enabled(h.tag, level)
 }
 
-func (l *Logger) Error(v ...interface{}) {
-	l.log(Error, v...)
+func (h *handler) Handle(_ context.Context, r slog.Record) error {
+	mu.RLock()
+	m := current
+	mu.RUnlock()
+	if m == nil {
+		return nil
+	}
+
+	var attrs []slog.Attr
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	// Wrap the record's attributes in the open groups, innermost first.
+	for i := len(h.groups) - 1; i >= 0; i-- {
+		inner := append(append([]slog.Attr(nil), h.nested[i]...), attrs...)
+		attrs = []slog.Attr{{Key: h.groups[i], Value: slog.GroupValue(inner...)}}
+	}
+
+	out := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	out.AddAttrs(slog.String("tag", h.tag))
+	out.AddAttrs(h.attrs...)
+	out.AddAttrs(attrs...)
+	m.enqueue(out, m.logged(h.tag, r.Level))
+	return nil
 }
 
-func (l *Logger) Warn(v ...interface{}) {
-	l.log(Warn, v...)
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	if last := len(h.groups) - 1; last >= 0 {
+		clone.nested = append([][]slog.Attr(nil), h.nested...)
+		clone.nested[last] = append(append([]slog.Attr(nil), h.nested[last]...), attrs...)
+	} else {
+		clone.attrs = append(append([]slog.Attr(nil), h.attrs...), attrs...)
+	}
+	return &clone
 }
 
-func (l *Logger) Fatal(v ...interface{}) {
-	l.log(Fatal, v...)
-	os.Exit(1)
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.groups = append(append([]string(nil), h.groups...), name)
+	clone.nested = append(append([][]slog.Attr(nil), h.nested...), nil)
+	return &clone
 }
 
-func (l *Logger) Close() {
-	close(l.closeChan)
-	if l.logFile != nil {
-		l.logFile.Close()
+func replaceLevel(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.LevelKey && len(groups) == 0 {
+		if level, ok := a.Value.Any().(slog.Level); ok {
+			a.Value = slog.StringValue(LevelName(level))
+		}
 	}
+	return a
 }
 
-func (t Types) toString() string {
-	switch t {
-	case Info:
-		return "INFO"
-	case Error:
-		return "ERROR"
-	case Warn:
-		return "WARN"
-	case Fatal:
+// LevelName is the level as written to the logs, including FATAL.
+func LevelName(level slog.Level) string {
+	if level >= LevelFatal {
 		return "FATAL"
-	default:
-		return "UNKNOWN"
 	}
+	return level.String()
 }
@@ -0,0 +1,160 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useManager installs a manager writing to a temporary directory and
+// returns that directory.
+func useManager(t *testing.T, opts Options) string {
+	t.Helper()
+	opts.Path = t.TempDir()
+	m, err := newManager(opts)
+	require.NoError(t, err)
+
+	mu.Lock()
+	current = m
+	mu.Unlock()
+	t.Cleanup(func() {
+		m.close()
+		mu.Lock()
+		current = nil
+		mu.Unlock()
+	})
+	return opts.Path
+}
+
+func readRecords(t *testing.T, dir string) []map[string]any {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "blab_log_*.log"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	file, err := os.Open(files[0])
+	require.NoError(t, err)
+	defer file.Close()
+
+	var records []map[string]any
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLoggerWritesJSON(t *testing.T) {
+	dir := useManager(t, Options{Level: slog.LevelInfo})
+
+	log := NewLogger("Speech").With("session", 7)
+	log.Info("Transcribed", "confidence", 0.9, "text", "hello")
+	log.Close()
+
+	records := readRecords(t, dir)
+	require.Len(t, records, 1)
+	assert.Equal(t, "INFO", records[0]["level"])
+	assert.Equal(t, "Transcribed", records[0]["msg"])
+	assert.Equal(t, "Speech", records[0]["tag"])
+	assert.Equal(t, 7.0, records[0]["session"])
+	assert.Equal(t, 0.9, records[0]["confidence"])
+	assert.Equal(t, "hello", records[0]["text"])
+}
+
+func TestLoggerTagLevels(t *testing.T) {
+	dir := useManager(t, Options{
+		Level:     slog.LevelWarn,
+		TagLevels: map[string]slog.Level{"Speech": slog.LevelDebug},
+	})
+
+	NewLogger("speech").Debug("kept")
+	NewLogger("ui").Info("dropped")
+	NewLogger("ui").Warn("kept too")
+	NewLogger("ui").Close()
+
+	var messages []string
+	for _, record := range readRecords(t, dir) {
+		messages = append(messages, record["msg"].(string))
+	}
+	assert.Equal(t, []string{"kept", "kept too"}, messages)
+}
+
+type consoleEntries []Entry
+
+func (c *consoleEntries) Add(e Entry) { *c = append(*c, e) }
+
+func TestLoggerConsoleLevel(t *testing.T) {
+	var console consoleEntries
+	dir := useManager(t, Options{Level: slog.LevelInfo, Console: &console, ConsoleLevel: slog.LevelDebug})
+
+	log := NewLogger("ui")
+	log.Debug("console only")
+	log.Info("everywhere")
+	log.Close()
+
+	require.Len(t, console, 2)
+	assert.Equal(t, "console only", console[0].Message)
+	records := readRecords(t, dir)
+	require.Len(t, records, 1)
+	assert.Equal(t, "everywhere", records[0]["msg"])
+}
+
+func TestLoggerDropsAfterClose(t *testing.T) {
+	dir := useManager(t, Options{})
+
+	log := NewLogger("test")
+	for i := 0; i < 100; i++ {
+		log.Info("record", "i", i)
+	}
+	log.Close()
+	log.Info("after close")
+	log.Close()
+
+	assert.Len(t, readRecords(t, dir), 100)
+}
+
+func TestLoggerBeforeInit(t *testing.T) {
+	assert.NotPanics(t, func() {
+		NewLogger("test").Error("nowhere to go")
+	})
+}
+
+func TestParseTagLevels(t *testing.T) {
+	levels, err := ParseTagLevels("speech=debug, Google Client=WARN,")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]slog.Level{
+		"speech":        slog.LevelDebug,
+		"google client": slog.LevelWarn,
+	}, levels)
+
+	_, err = ParseTagLevels("speech")
+	assert.Error(t, err)
+	_, err = ParseTagLevels("speech=loud")
+	assert.Error(t, err)
+}
+
+func TestNewEntry(t *testing.T) {
+	when := time.Date(2024, 1, 1, 13, 4, 5, 0, time.UTC)
+	r := slog.NewRecord(when, slog.LevelWarn, "Input overflowed", 0)
+	r.AddAttrs(
+		slog.String("tag", "speech"),
+		slog.Group("device", slog.String("name", "mic"), slog.Int("channels", 2)),
+	)
+
+	assert.Equal(t, Entry{
+		Time:    when,
+		Level:   slog.LevelWarn,
+		Tag:     "speech",
+		Message: "Input overflowed",
+		Fields:  " device.name=mic device.channels=2",
+	}, newEntry(r))
+}
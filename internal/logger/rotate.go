@@ -0,0 +1,219 @@
+package logger
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	filePrefix = "blab_log_"
+	fileSuffix = ".log"
+)
+
+// RotateConfig limits how large and how old the log file gets and how many
+// old files are kept. Zero values disable each limit.
+type RotateConfig struct {
+	// MaxSize is the size in bytes after which a new file is started.
+	MaxSize int64
+	// MaxAge is how long a file is written to before a new one is started.
+	MaxAge time.Duration
+	// Keep is how many files besides the current one are kept, counting
+	// those left by earlier runs.
+	Keep int
+	// Compress gzips files once they are rotated out.
+	Compress bool
+}
+
+// DefaultDir is where logs are written when no path is given:
+// $XDG_STATE_HOME/blab/logs, or ~/.local/state/blab/logs.
+func DefaultDir() (string, error) {
+	state := os.Getenv("XDG_STATE_HOME")
+	if !filepath.IsAbs(state) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("finding log directory: %w", err)
+		}
+		state = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(state, "blab", "logs"), nil
+}
+
+// rotatingFile is the log file. It is only used from the log worker, so it
+// does no locking of its own.
+type rotatingFile struct {
+	dir    string
+	cfg    RotateConfig
+	now    func() time.Time
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+func openRotatingFile(dir string, cfg RotateConfig) (*rotatingFile, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	f := &rotatingFile{dir: dir, cfg: cfg, now: time.Now}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	f.prune()
+	return f, nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	if f.full(len(p)) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// full reports whether writing n more bytes should go to a new file. An
+// empty file is never full, so a single oversized record is still written.
+func (f *rotatingFile) full(n int) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.cfg.MaxSize > 0 && f.size+int64(n) > f.cfg.MaxSize {
+		return true
+	}
+	return f.cfg.MaxAge > 0 && f.now().Sub(f.opened) >= f.cfg.MaxAge
+}
+
+func (f *rotatingFile) rotate() error {
+	old := f.file.Name()
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	if f.cfg.Compress {
+		// A failed compression leaves the plain file, which is still kept.
+		_ = compress(old)
+	}
+	f.prune()
+	return nil
+}
+
+// open starts a new file named after the current time.
+func (f *rotatingFile) open() error {
+	f.opened = f.now()
+	base := filePrefix + f.opened.Format("20060102_150405")
+	for i := 0; ; i++ {
+		name := base + fileSuffix
+		if i > 0 {
+			name = fmt.Sprintf("%s_%d%s", base, i, fileSuffix)
+		}
+		file, err := os.OpenFile(filepath.Join(f.dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0666)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to open log file: %w", err)
+		}
+		f.file, f.size = file, 0
+		return nil
+	}
+}
+
+// prune removes the oldest log files beyond the retention count.
+func (f *rotatingFile) prune() {
+	if f.cfg.Keep <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return
+	}
+
+	type logFile struct {
+		path    string
+		modTime time.Time
+	}
+	var files []logFile
+	current := filepath.Base(f.file.Name())
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == current || !isLogFile(name) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, logFile{filepath.Join(f.dir, name), info.ModTime()})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].modTime.Equal(files[j].modTime) {
+			return files[i].modTime.After(files[j].modTime)
+		}
+		return files[i].path > files[j].path
+	})
+	for i := f.cfg.Keep; i < len(files); i++ {
+		os.Remove(files[i].path)
+	}
+}
+
+func (f *rotatingFile) Close() error {
+	return f.file.Close()
+}
+
+func isLogFile(name string) bool {
+	name = strings.TrimSuffix(name, ".gz")
+	return strings.HasPrefix(name, filePrefix) && strings.HasSuffix(name, fileSuffix)
+}
+
+// compress replaces path with path.gz, keeping its modification time so
+// retention still orders it correctly.
+func compress(path string) (err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			out.Close()
+			os.Remove(out.Name())
+		}
+	}()
+
+	zw := gzip.NewWriter(out)
+	if _, err = io.Copy(zw, in); err != nil {
+		return err
+	}
+	if err = zw.Close(); err != nil {
+		return err
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+	if err = os.Chtimes(out.Name(), info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+	in.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,136 @@
+package logger
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clock is a fake time source advanced by the tests.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func newTestFile(t *testing.T, cfg RotateConfig) (*rotatingFile, *clock) {
+	t.Helper()
+	c := &clock{time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	dir := t.TempDir()
+	f := &rotatingFile{dir: dir, cfg: cfg, now: c.now}
+	require.NoError(t, f.open())
+	t.Cleanup(func() { f.Close() })
+	return f, c
+}
+
+func logFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func write(t *testing.T, f *rotatingFile, s string) {
+	t.Helper()
+	_, err := f.Write([]byte(s))
+	require.NoError(t, err)
+}
+
+func TestRotateOnSize(t *testing.T) {
+	f, _ := newTestFile(t, RotateConfig{MaxSize: 10})
+
+	write(t, f, "12345678\n")
+	write(t, f, "a\n")             // would make 11 bytes
+	write(t, f, "too long line\n") // oversized records go to a fresh file
+
+	assert.Equal(t, []string{
+		"blab_log_20240101_120000.log",
+		"blab_log_20240101_120000_1.log",
+		"blab_log_20240101_120000_2.log",
+	}, logFiles(t, f.dir))
+}
+
+func TestRotateOnAge(t *testing.T) {
+	f, c := newTestFile(t, RotateConfig{MaxAge: time.Hour})
+
+	write(t, f, "first\n")
+	c.t = c.t.Add(59 * time.Minute)
+	write(t, f, "still first\n")
+	c.t = c.t.Add(time.Minute)
+	write(t, f, "second\n")
+
+	assert.Equal(t, []string{
+		"blab_log_20240101_120000.log",
+		"blab_log_20240101_130000.log",
+	}, logFiles(t, f.dir))
+	data, err := os.ReadFile(filepath.Join(f.dir, "blab_log_20240101_120000.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "first\nstill first\n", string(data))
+}
+
+func TestRotateKeepsNewest(t *testing.T) {
+	f, c := newTestFile(t, RotateConfig{MaxAge: time.Hour, Keep: 2})
+	// Files from another program are left alone.
+	require.NoError(t, os.WriteFile(filepath.Join(f.dir, "notes.txt"), nil, 0666))
+
+	for i := 0; i < 5; i++ {
+		write(t, f, "line\n")
+		// Order retention by the file times rather than relying on the
+		// file system's timestamp resolution.
+		require.NoError(t, os.Chtimes(f.file.Name(), c.t, c.t))
+		c.t = c.t.Add(time.Hour)
+	}
+
+	assert.Equal(t, []string{
+		"blab_log_20240101_140000.log",
+		"blab_log_20240101_150000.log",
+		"blab_log_20240101_160000.log",
+		"notes.txt",
+	}, logFiles(t, f.dir))
+}
+
+func TestRotateCompresses(t *testing.T) {
+	f, c := newTestFile(t, RotateConfig{MaxAge: time.Hour, Compress: true})
+
+	write(t, f, "first\n")
+	c.t = c.t.Add(time.Hour)
+	write(t, f, "second\n")
+
+	assert.Equal(t, []string{
+		"blab_log_20240101_120000.log.gz",
+		"blab_log_20240101_130000.log",
+	}, logFiles(t, f.dir))
+
+	file, err := os.Open(filepath.Join(f.dir, "blab_log_20240101_120000.log.gz"))
+	require.NoError(t, err)
+	defer file.Close()
+	zr, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	data, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, "first\n", string(data))
+}
+
+func TestDefaultDir(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "/state")
+	dir, err := DefaultDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("/state", "blab", "logs"), dir)
+
+	// Relative paths are invalid per the XDG spec and ignored.
+	t.Setenv("XDG_STATE_HOME", "state")
+	t.Setenv("HOME", "/home/me")
+	dir, err = DefaultDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("/home/me", ".local", "state", "blab", "logs"), dir)
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bz888/blab/internal/speech/recorder"
+	"sync"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+const framesPerBuffer = 512 * 9
+
+var (
+	portaudioMu          sync.Mutex
+	portaudioInitialized bool
+)
+
+// initPortaudio initialises PortAudio once for the life of the process;
+// Terminate releases it on shutdown.
+func initPortaudio() error {
+	portaudioMu.Lock()
+	defer portaudioMu.Unlock()
+	if portaudioInitialized {
+		return nil
+	}
+	if err := portaudio.Initialize(); err != nil {
+		return fmt.Errorf("initialising portaudio: %w", err)
+	}
+	portaudioInitialized = true
+	return nil
+}
+
+// Terminate releases PortAudio if it was initialised.
+func Terminate() {
+	portaudioMu.Lock()
+	defer portaudioMu.Unlock()
+	if portaudioInitialized {
+		if err := portaudio.Terminate(); err != nil {
+			localLogger.Error("Failed to terminate portaudio", "err", err)
+		}
+		portaudioInitialized = false
+	}
+}
+
+// portaudioInput is a started PortAudio input stream.
+type portaudioInput struct {
+	stream     *portaudio.Stream
+	buffer     []int16
+	sampleRate int
+	channels   int
+}
+
+func openDefaultInput() (recorder.Input, error) {
+	if err := initPortaudio(); err != nil {
+		return nil, err
+	}
+
+	device, err := portaudio.DefaultInputDevice()
+	if err != nil {
+		return nil, fmt.Errorf("finding default input device: %w", err)
+	}
+
+	// Set up the audio stream parameters for LINEAR16 PCM. Samples arrive
+	// interleaved, one per channel for each frame.
+	input := &portaudioInput{
+		buffer:     make([]int16, framesPerBuffer*device.MaxInputChannels),
+		sampleRate: int(device.DefaultSampleRate),
+		channels:   device.MaxInputChannels,
+	}
+	input.stream, err = portaudio.OpenDefaultStream(
+		input.channels, 0, device.DefaultSampleRate, framesPerBuffer, &input.buffer,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("opening stream: %w", err)
+	}
+
+	if err := input.stream.Start(); err != nil {
+		input.stream.Close()
+		return nil, fmt.Errorf("starting stream: %w", err)
+	}
+	localLogger.Info("Recording", "device", device.Name, "sampleRate", device.DefaultSampleRate, "channels", input.channels)
+	return input, nil
+}
+
+func (in *portaudioInput) SampleRate() int { return in.sampleRate }
+func (in *portaudioInput) Channels() int   { return in.channels }
+
+func (in *portaudioInput) Read() ([]int16, error) {
+	err := in.stream.Read()
+	if errors.Is(err, portaudio.InputOverflowed) {
+		// Samples were dropped while we were busy; the buffer is still usable.
+		localLogger.Warn("Input overflowed", "err", err)
+		err = nil
+	}
+	return in.buffer, err
+}
+
+func (in *portaudioInput) Close() error {
+	stopErr := in.stream.Stop()
+	return errors.Join(stopErr, in.stream.Close())
+}
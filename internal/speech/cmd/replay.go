@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	speechConfig "github.com/bz888/blab/internal/speech/config"
+	"github.com/bz888/blab/internal/speech/recorder"
+	"github.com/bz888/blab/internal/speech/session"
+)
+
+// Replay runs the raw capture of a recorded session through the recorder
+// again, with the current VAD and filter settings, and reports each new
+// utterance next to the saved ones it overlaps.
+func Replay(dir string) (string, error) {
+	localLogger = speechConfig.LocalLogger
+
+	if speechConfig.Disable {
+		return "", errors.New("GOOGLE_API_KEY is not set, voice recognition is disabled")
+	}
+
+	_, saved, err := session.Load(dir)
+	if err != nil {
+		return "", fmt.Errorf("loading session: %w", err)
+	}
+
+	cfg := recorderConfig()
+	cfg.Open = func() (recorder.Input, error) {
+		return session.OpenCapture(dir)
+	}
+	cfg.RecordDir = ""
+	results, err := recorder.New(cfg).Start(context.Background())
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		report  strings.Builder
+		matched = make([]bool, len(saved))
+		index   int
+	)
+	for result := range results {
+		if result.Err != nil && result.End == 0 {
+			// Only a failing input ends without an utterance.
+			return "", result.Err
+		}
+		index++
+		fmt.Fprintf(&report, "%03d [%.1fs-%.1fs]\n", index, result.Start.Seconds(), result.End.Seconds())
+		if result.Err != nil {
+			fmt.Fprintf(&report, "  replay: error: %s\n", result.Err)
+		} else {
+			fmt.Fprintf(&report, "  replay: %q (%.2f)\n", result.Transcription.Text, result.Transcription.Confidence)
+		}
+
+		for i, segment := range saved {
+			if !overlaps(segment, result.Start, result.End) {
+				continue
+			}
+			matched[i] = true
+			fmt.Fprintf(&report, "  saved:  %s\n", describeSaved(segment))
+		}
+	}
+
+	for i, segment := range saved {
+		if !matched[i] {
+			fmt.Fprintf(&report, "not replayed: %s\n", describeSaved(segment))
+		}
+	}
+	if report.Len() == 0 {
+		return "", fmt.Errorf("%s: no voice detected in the capture", dir)
+	}
+	return report.String(), nil
+}
+
+func overlaps(segment session.Segment, start, end time.Duration) bool {
+	return segment.Start < end.Seconds() && segment.End > start.Seconds()
+}
+
+func describeSaved(segment session.Segment) string {
+	where := fmt.Sprintf("%03d [%.1fs-%.1fs]", segment.Index, segment.Start, segment.End)
+	if segment.Error != "" {
+		return fmt.Sprintf("%s error: %s", where, segment.Error)
+	}
+	return fmt.Sprintf("%s %q (%.2f)", where, segment.Text, segment.Confidence)
+}
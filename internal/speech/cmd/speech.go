@@ -2,295 +2,162 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/bz888/blab/internal/config"
 	"github.com/bz888/blab/internal/logger"
 	speechConfig "github.com/bz888/blab/internal/speech/config"
 	"github.com/bz888/blab/internal/speech/convert"
 	"github.com/bz888/blab/internal/speech/output_api"
-	"github.com/bz888/blab/internal/speech/sound"
+	"github.com/bz888/blab/internal/speech/pipeline"
+	"github.com/bz888/blab/internal/speech/recorder"
 	vadlib "github.com/bz888/blab/internal/speech/vad"
-	"github.com/go-audio/wav"
-	"github.com/orcaman/writerseeker"
-	"io"
-	"math"
-	"os/signal"
-	"strconv"
-	"sync"
-	"syscall"
+	"strings"
 	"time"
-
-	"github.com/go-audio/audio"
-	"github.com/gordonklaus/portaudio"
 )
 
 const (
-	minMicVolume       = 450
-	sendToVADDelay     = time.Second
 	maxSegmentDuration = time.Second * 25
 )
 
 var localLogger *logger.Logger
 
-func Run() (string, error) {
+// NewRecorder creates the live voice recorder for the default microphone,
+// configured from the command line flags.
+func NewRecorder() *recorder.Recorder {
 	localLogger = speechConfig.LocalLogger
+	return recorder.New(recorderConfig())
+}
 
-	if speechConfig.Disable {
-		localLogger.Warn("GOOGLE_API_KEY is not set, voice recognition is disabled")
-		return "", nil
+// recorderConfig is the live recorder's configuration from the command line
+// flags, which replay reuses with a recorded input.
+func recorderConfig() recorder.Config {
+	return recorder.Config{
+		Open:        openDefaultInput,
+		NewDetector: newVoiceDetector,
+		NewPipeline: NewPipeline,
+		Endpointer:  endpointerConfig(),
+		Filters: recorder.FilterConfig{
+			Channel:  config.MicChannel,
+			HighPass: config.HighPass,
+			Denoise:  config.Denoise,
+		},
+		RecordDir: config.RecordDir,
+		Logger:    localLogger,
 	}
+}
 
-	portaudio.Initialize()
-	defer portaudio.Terminate()
-
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer stop()
+// endpointerConfig is how the microphone loop splits speech into utterances.
+func endpointerConfig() vadlib.EndpointerConfig {
+	cfg := vadlib.DefaultEndpointerConfig()
+	cfg.MaxUtterance = maxSegmentDuration
+	return cfg
+}
 
-	// If there is no selected device, print all of them and exit.
-	var selectedDevice, _ = portaudio.DefaultInputDevice()
+// newEndpointer splits the audio classified by detector as the microphone
+// loop does.
+func newEndpointer(detector vadlib.VoiceDetector, cfg vadlib.EndpointerConfig) (*vadlib.Endpointer, error) {
+	cfg.FrameSize = detector.FrameSize()
+	return vadlib.NewEndpointer(detector, cfg)
+}
 
-	done := make(chan bool)
-	audioCtx, audioCancel := context.WithCancel(ctx)
+// newVoiceDetector creates the VAD engine chosen with the -vad flag.
+func newVoiceDetector() (vadlib.VoiceDetector, error) {
+	switch config.VAD {
+	case "flux":
+		return newFluxDetector(), nil
+	case "silero":
+		return newSileroDetector()
+	case "auto", "":
+		detector, err := newSileroDetector()
+		if err != nil {
+			localLogger.Warn("Silero VAD unavailable, falling back to spectral flux", "err", err)
+			return newFluxDetector(), nil
+		}
+		return detector, nil
+	default:
+		return nil, fmt.Errorf("unknown voice activity detector %q, expected silero, flux or auto", config.VAD)
+	}
+}
 
-	// Set up the audio stream parameters for LINEAR16 PCM
-	in := make([]int16, 512*9) // Use int16 to capture 16-bit samples.
-	audioStream, err := portaudio.OpenDefaultStream(
-		selectedDevice.MaxInputChannels, 0, selectedDevice.DefaultSampleRate, len(in), &in,
-	)
+func newFluxDetector() vadlib.VoiceDetector {
+	return vadlib.NewDetector(vadlib.DefaultSensitivity, vadlib.DefaultQuietTime, vadlib.DefaultFluxWidth)
+}
 
+// Silero VAD - pre-trained Voice Activity Detector. See: https://github.com/snakers4/silero-vad
+func newSileroDetector() (vadlib.VoiceDetector, error) {
+	modelPath, err := speechConfig.SileroFilePath()
 	if err != nil {
-		localLogger.Fatal("opening stream: %v", err)
-	}
-
-	// Start the audio stream
-	if err := audioStream.Start(); err != nil {
-		localLogger.Fatal("starting stream: %v", err)
+		return nil, err
 	}
 
-	// Silero VAD - pre-trained Voice Activity Detector. See: https://github.com/snakers4/silero-vad
-	sileroVAD, err := vadlib.NewSileroDetector(speechConfig.SileroFilePath)
+	sileroVAD, err := vadlib.NewSileroDetector(modelPath)
 	if err != nil {
-		localLogger.Fatal("creating silero detector: %v", err)
+		return nil, fmt.Errorf("creating silero detector: %w", err)
 	}
-
-	var (
-		startListening time.Time
-		processChan    = make(chan []int16, 10)
-		outChan        = make(chan audio.Buffer, 10)
-		buffer         = make([]int16, 512*9)
-		resultChan     = make(chan string)
-	)
-
-	go func() {
-		for {
-			select {
-			case <-audioCtx.Done():
-				if err := audioStream.Close(); err != nil {
-					localLogger.Error(err)
-				}
-				localLogger.Error("got audioCtx.Done exit gracefully...")
-				return
-			default:
-				// Read from the microphone
-				if err := audioStream.Read(); err != nil {
-					localLogger.Info("reading from stream: %v\n", err)
-					continue
-				}
-
-				volume := calculateRMS16(in)
-				if volume > minMicVolume {
-					startListening = time.Now()
-				}
-
-				if time.Since(startListening) < sendToVADDelay && time.Since(startListening) < maxSegmentDuration {
-					buffer = append(buffer, in...)
-
-					localLogger.Info("listening...", volume)
-				} else if len(buffer) > 0 {
-					// Silero accept audio with SampleRate = 16000.
-
-					// Resample also copies the buffer to another slice. Potentially, using a channel instead of a
-					// buffer can achieve better performance.
-					localLogger.Info("Sending to processChannel...", len(buffer))
-					processChan <- sound.ResampleInt16(buffer, int(selectedDevice.DefaultSampleRate), 16000)
-					buffer = buffer[:0]
-				}
-			}
-		}
-	}()
-
-	// Responsible for checking recorded sections for the presence of the user's voice.
-	go vad(sileroVAD, processChan, outChan)
-
-	// Encodes the final sound into wav -> flac
-	var wg sync.WaitGroup
-
-	wg.Add(1)
-
-	go func() {
-		process(outChan, resultChan, &wg)
-	}()
-
-	var result string
-	go func() {
-		for resp := range resultChan {
-			localLogger.Info("Response:", resp)
-			result += resp + "\n"
-			wg.Done()
-		}
-	}()
-	wg.Wait()
-	close(resultChan)
-
-	localLogger.Info("finished")
-
-	// Shutdown.
-	go func() {
-		<-ctx.Done()
-		if err := ctx.Err(); err != nil {
-			localLogger.Error(fmt.Errorf("shutdown: %w", err))
-		}
-		audioCancel()
-		close(done)
-	}()
-
-	return result, nil
+	return sileroVAD, nil
 }
 
-func vad(silero *vadlib.SileroDetector, input <-chan []int16, output chan audio.Buffer) {
-	soundIntBuffer := &audio.IntBuffer{
-		Format: &audio.Format{SampleRate: 16000, NumChannels: 1},
-	}
-
-	for {
-		soundIntBuffer.Data = sound.ConvertInt16ToInt(<-input)
-
-		start := time.Now()
-		detected, err := silero.DetectVoice(soundIntBuffer)
-		if err != nil {
-			localLogger.Error(fmt.Errorf("detect voice: %w", err))
-			continue
-		}
-		localLogger.Info("voice detecting result", time.Since(start), detected)
-
-		if detected {
-			localLogger.Info("sending to output...")
-			output <- soundIntBuffer.Clone()
-		}
-	}
+// NewPipeline wires a detector to the FLAC encoder and the Google recogniser.
+func NewPipeline(detector pipeline.Detector) *pipeline.Pipeline {
+	return pipeline.New(detector, convert.EncodeFLAC, pipeline.TranscriberFunc(sendGoogle))
 }
 
-// google api
-func process(in <-chan audio.Buffer, resultChan chan string, wg *sync.WaitGroup) {
-	defer wg.Done()
-	for {
-		data := <-in
-
-		// Emulate a file in RAM so that we don't have to create a real file.
-		file := &writerseeker.WriterSeeker{}
-		encoder := wav.NewEncoder(file, 16000, 16, 1, 1)
-
-		// Write the audio buffer to the WAV file using the encoder
-		if err := encoder.Write(data.AsIntBuffer()); err != nil {
-			localLogger.Info(fmt.Errorf("encoder write buffer: %w", err))
-			return
-		}
-
-		// Close the encoder to finalize the WAV file headers
-		if err := encoder.Close(); err != nil {
-			localLogger.Info(fmt.Errorf("encoder close: %w", err))
-			return
-		}
-
-		// Read all data from the reader into memory
-		wavData, err := io.ReadAll(file.Reader())
-		if err != nil {
-			localLogger.Info(fmt.Errorf("reading WAV file into memory: %w", err))
-			return
-		}
-
-		localLogger.Info("WAV data length: %d bytes\n", len(wavData))
-		if len(wavData) == 0 {
-			localLogger.Error("WAV data is empty")
-			return
-		}
-
-		localLogger.Info("Encode to FLAC beginning")
-		//flacData, err := convert.EncodeFLAC(wavData)
-		flacData, err := convert.EncodeFLACExecutable(wavData, 16000, 2)
-		localLogger.Info("FLAC data length: %d bytes\n", len(flacData))
-
-		if err != nil {
-			localLogger.Error(fmt.Errorf("FLAC encoding error: %w", err))
-			return
-		}
-		localLogger.Info("Encode to FLAC successfully")
-
-		if len(flacData) == 0 {
-			localLogger.Error("FLAC data is empty")
-			return
-		}
-
-		start := time.Now()
-		localLogger.Info("Sending to out")
-		resp, conf, err := output_api.Send(flacData)
-		if err != nil {
-			localLogger.Error(fmt.Errorf("sending multipart form: %w", err))
-			return
-		}
-
-		resultChan <- resp
-		localLogger.Info(fmt.Sprintf("done in: %s, confidence: %s, result: %s", time.Since(start), int(conf), resp))
+// sendGoogle recognises audio in the language chosen with -lang or /lang.
+// Google cannot identify the language itself, so with several candidates
+// each is tried and the most confident kept.
+func sendGoogle(ctx context.Context, audioData []byte) ([]pipeline.Alternative, error) {
+	languages := speechConfig.Languages()
+	if len(languages) == 1 {
+		return sendGoogleIn(ctx, audioData, languages[0])
 	}
+	return pipeline.DetectLanguage(ctx, audioData, languages, sendGoogleIn)
 }
 
-func printAvailableDevices() {
-	devices, err := portaudio.Devices()
+func sendGoogleIn(ctx context.Context, audioData []byte, language string) ([]pipeline.Alternative, error) {
+	result, err := output_api.Send(ctx, audioData, language)
 	if err != nil {
-		localLogger.Fatal("portaudio.Devices %s", err)
-		return
+		return nil, err
 	}
-	for i, device := range devices {
-		localLogger.Info(
-			"ID: %d, Name: %s, MaxInputChannels: %d, Sample rate: %f\n",
-			i,
-			device.Name,
-			device.MaxInputChannels,
-			device.DefaultSampleRate,
-		)
+	alternatives := make([]pipeline.Alternative, len(result))
+	for i, alternative := range result {
+		alternatives[i] = pipeline.Alternative{
+			Text:       alternative.Transcript,
+			Confidence: alternative.Confidence,
+			Language:   language,
+		}
 	}
+	return alternatives, nil
 }
 
-func selectInputDevice(args []string) (*portaudio.DeviceInfo, error) {
-	deviceID, err := strconv.Atoi(args[0])
-	if err != nil {
-		return nil, fmt.Errorf("parce int %w", err)
+// TranscribeFile runs a recorded WAV file through the speech pipeline and
+// returns the transcribed segments joined into a single line.
+func TranscribeFile(path string) (string, error) {
+	localLogger = speechConfig.LocalLogger
+
+	if speechConfig.Disable {
+		return "", errors.New("GOOGLE_API_KEY is not set, voice recognition is disabled")
 	}
 
-	devices, err := portaudio.Devices()
+	detector, err := newVoiceDetector()
 	if err != nil {
-		return nil, fmt.Errorf("select input device %w", err)
+		return "", err
 	}
+	defer detector.Close()
 
-	selectedDevice, err := portaudio.DefaultInputDevice()
+	// A file may start with speech, which would be taken for the noise floor.
+	cfg := endpointerConfig()
+	cfg.Calibration = 0
+	endpointer, err := newEndpointer(detector, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("find default device %w", err)
+		return "", err
 	}
 
-	// Set default device to device with particular id
-	selectedDevice = devices[deviceID]
-
-	localLogger.Info("selected device:", selectedDevice.Name, selectedDevice.DefaultSampleRate)
-
-	return selectedDevice, nil
-}
-
-// calculateRMS16 calculates the root-mean-square of the audio buffer for int16 samples.
-func calculateRMS16(buffer []int16) float64 {
-	var sumSquares float64
-	for _, sample := range buffer {
-		val := float64(sample) // Convert int16 to float64 for calculation
-		sumSquares += val * val
+	transcripts, err := NewPipeline(detector).TranscribeFile(context.Background(), path, endpointer)
+	if err != nil {
+		return "", err
 	}
-	meanSquares := sumSquares / float64(len(buffer))
-	return math.Sqrt(meanSquares)
+	localLogger.Info("Transcribed file", "path", path, "segments", len(transcripts))
+
+	return strings.Join(transcripts, " "), nil
 }
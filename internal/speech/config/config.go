@@ -1,16 +1,22 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"github.com/bz888/blab/internal/config"
+	"github.com/bz888/blab/internal/files"
 	"github.com/bz888/blab/internal/logger"
 	"github.com/joho/godotenv"
 	"os"
-	"path/filepath"
+	"sync"
 )
 
 var (
-	SileroFilePath string
-	Disable        = false
-	LocalLogger    *logger.Logger
+	Disable     = false
+	LocalLogger *logger.Logger
+
+	sileroMu   sync.Mutex
+	sileroPath string // set once resolved, failures are retried
 )
 
 func Init() {
@@ -21,10 +27,50 @@ func Init() {
 		Disable = true
 	}
 
-	basePath, err := filepath.Abs(filepath.Join("./internal", "files"))
+	if err := SetLanguages(config.Language); err != nil {
+		LocalLogger.Warn("Ignoring -lang", "using", LanguageLabel(), "err", err)
+	}
+}
+
+// SileroFilePath returns the path of the Silero VAD model. The -sileroPath
+// flag takes precedence; otherwise the model embedded in the binary is
+// extracted to the user cache directory on first use. A failure, such as a
+// full disk, is not remembered, so the next call tries again.
+func SileroFilePath() (string, error) {
+	sileroMu.Lock()
+	defer sileroMu.Unlock()
+	if sileroPath != "" {
+		return sileroPath, nil
+	}
+
+	path, err := resolveSileroPath(config.SileroPath)
+	if err != nil {
+		return "", err
+	}
+	sileroPath = path
+	LocalLogger.Info("Using silero model", "path", sileroPath)
+	return sileroPath, nil
+}
+
+func resolveSileroPath(override string) (string, error) {
+	if override != "" {
+		if _, err := os.Stat(override); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return "", fmt.Errorf("silero VAD model not found at %s (set by -sileroPath)", override)
+			}
+			return "", fmt.Errorf("silero VAD model at %s: %w", override, err)
+		}
+		return override, nil
+	}
+
+	dir, err := files.CacheDir()
 	if err != nil {
-		LocalLogger.Fatal("Failed to determine working directory: %v", err)
+		return "", fmt.Errorf("no cache directory for the silero VAD model, use -sileroPath: %w", err)
 	}
 
-	SileroFilePath = filepath.Join(basePath, "silero_vad.onnx")
+	path, err := files.Extract(files.SileroVAD, dir)
+	if err != nil {
+		return "", fmt.Errorf("extracting silero VAD model to %s, use -sileroPath: %w", dir, err)
+	}
+	return path, nil
 }
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bz888/blab/internal/config"
+	"github.com/bz888/blab/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSileroFilePathRetries(t *testing.T) {
+	LocalLogger = logger.NewLogger("test")
+	override := filepath.Join(t.TempDir(), "silero_vad.onnx")
+	config.SileroPath = override
+	t.Cleanup(func() {
+		config.SileroPath = ""
+		sileroPath = ""
+	})
+
+	_, err := SileroFilePath()
+	assert.Error(t, err)
+
+	require.NoError(t, os.WriteFile(override, []byte("model"), 0600))
+	path, err := SileroFilePath()
+	require.NoError(t, err, "a failure is not cached")
+	assert.Equal(t, override, path)
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/language"
+)
+
+// MaxCandidates caps how many languages auto-detection tries. Every
+// utterance is sent to the recogniser once per candidate, multiplying the
+// quota used and waiting on the slowest answer.
+const MaxCandidates = 3
+
+var (
+	languageMu sync.RWMutex
+	languages  = []string{"en-US"}
+)
+
+// ParseLanguages reads a language setting: a single BCP 47 tag such as
+// "de-DE", or "auto" followed by up to MaxCandidates tags to detect between.
+// Detection is costly, so it is only done among languages listed after
+// "auto". Tags may be separated by spaces or commas.
+func ParseLanguages(spec string) ([]string, error) {
+	fields := strings.FieldsFunc(spec, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	if len(fields) == 0 {
+		return nil, errors.New("no language given")
+	}
+
+	auto := strings.EqualFold(fields[0], "auto")
+	if auto {
+		fields = fields[1:]
+	}
+	switch {
+	case auto && len(fields) < 2:
+		return nil, errors.New("auto needs the languages to detect between, e.g. auto en-US de-DE")
+	case auto && len(fields) > MaxCandidates:
+		return nil, fmt.Errorf("auto detects between at most %d languages, each costs a request per utterance", MaxCandidates)
+	case !auto && len(fields) > 1:
+		return nil, fmt.Errorf("give one language, or auto followed by up to %d to detect between", MaxCandidates)
+	}
+
+	tags := make([]string, 0, len(fields))
+	for _, field := range fields {
+		tag, err := language.Parse(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid language %q: %w", field, err)
+		}
+		tags = append(tags, tag.String())
+	}
+	return tags, nil
+}
+
+// SetLanguages changes the recognition language for the following
+// utterances. See ParseLanguages for the accepted forms.
+func SetLanguages(spec string) error {
+	tags, err := ParseLanguages(spec)
+	if err != nil {
+		return err
+	}
+	languageMu.Lock()
+	languages = tags
+	languageMu.Unlock()
+	return nil
+}
+
+// Languages returns the recognition language, or the candidates to detect
+// between when there are several.
+func Languages() []string {
+	languageMu.RLock()
+	defer languageMu.RUnlock()
+	return append([]string(nil), languages...)
+}
+
+// LanguageLabel describes the current setting for display.
+func LanguageLabel() string {
+	tags := Languages()
+	if len(tags) == 1 {
+		return tags[0]
+	}
+	return "auto (" + strings.Join(tags, ", ") + ")"
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLanguages(t *testing.T) {
+	for spec, want := range map[string][]string{
+		"de-DE":            {"de-DE"},
+		"en-us":            {"en-US"},
+		"auto en-US de-DE": {"en-US", "de-DE"},
+		"auto,en-GB,fr-FR": {"en-GB", "fr-FR"},
+	} {
+		got, err := ParseLanguages(spec)
+		require.NoError(t, err, spec)
+		assert.Equal(t, want, got, spec)
+	}
+
+	for _, spec := range []string{"", "  ", "klingon-for-real", "auto", "auto en-US", "en-US, ja-JP", "auto en-US de-DE fr-FR es-ES"} {
+		_, err := ParseLanguages(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestSetLanguages(t *testing.T) {
+	t.Cleanup(func() { languages = []string{"en-US"} })
+
+	require.NoError(t, SetLanguages("de-DE"))
+	assert.Equal(t, "de-DE", LanguageLabel())
+
+	assert.Error(t, SetLanguages("not a tag!"))
+	assert.Equal(t, []string{"de-DE"}, Languages(), "invalid input keeps the setting")
+
+	require.NoError(t, SetLanguages("auto en-US de-DE"))
+	assert.Equal(t, "auto (en-US, de-DE)", LanguageLabel())
+}
@@ -5,120 +5,114 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
+
+	"github.com/go-audio/wav"
 	"github.com/mewkiz/flac"
 	"github.com/mewkiz/flac/frame"
 	"github.com/mewkiz/flac/meta"
 	"github.com/orcaman/writerseeker"
-	"io"
-	"os"
-	"os/exec"
-	"path/filepath"
-	"runtime"
 )
 
 const (
-	blockSize = 4096 // Block size in samples
+	blockSize     = 4096 // Block size in samples
+	minBlockSize  = 16   // Smallest block size STREAMINFO may declare
+	bitsPerSample = 16
+
+	streamInfoOffset = 8
 )
 
-// EncodeFLAC Todo, I am not too such how to get this working without using executables
+// EncodeFLAC converts a signed 16-bit PCM WAV file into a FLAC stream.
 func EncodeFLAC(wavData []byte) ([]byte, error) {
-	file := &writerseeker.WriterSeeker{}
+	decoder := wav.NewDecoder(bytes.NewReader(wavData))
+	if !decoder.IsValidFile() {
+		return nil, errors.New("invalid WAV file")
+	}
+	if decoder.WavAudioFormat != 1 {
+		return nil, fmt.Errorf("unsupported WAV format: %d", decoder.WavAudioFormat)
+	}
+	if decoder.BitDepth != bitsPerSample {
+		return nil, fmt.Errorf("unsupported WAV bit depth: %d", decoder.BitDepth)
+	}
 
-	// Parse WAV header
-	wavReader := bytes.NewReader(wavData)
-	var header [44]byte
-	if _, err := io.ReadFull(wavReader, header[:]); err != nil {
-		return nil, fmt.Errorf("failed to read WAV header: %w", err)
+	buf, err := decoder.FullPCMBuffer()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WAV samples: %w", err)
 	}
 
-	// Extract format information from WAV header
-	audioFormat := binary.LittleEndian.Uint16(header[20:22])
-	if audioFormat != 1 {
-		return nil, fmt.Errorf("unsupported WAV format: %d", audioFormat)
+	return EncodeSamples(buf.Data, int(decoder.SampleRate), int(decoder.NumChans))
+}
+
+// EncodeSamples encodes interleaved signed 16-bit samples into a FLAC stream.
+// Each channel is stored independently using whichever of the constant,
+// verbatim or fixed-predictor subframes is smallest.
+func EncodeSamples(samples []int, sampleRate, numChannels int) ([]byte, error) {
+	if numChannels < 1 || numChannels > 8 {
+		return nil, fmt.Errorf("unsupported channel count: %d", numChannels)
+	}
+	if len(samples) == 0 || len(samples)%numChannels != 0 {
+		return nil, fmt.Errorf("invalid sample count %d for %d channels", len(samples), numChannels)
 	}
-	numChannels := binary.LittleEndian.Uint16(header[22:24])
-	sampleRate := binary.LittleEndian.Uint32(header[24:28])
-	bitsPerSample := binary.LittleEndian.Uint16(header[34:36])
 
-	// Set up FLAC metadata
+	// Emulate a file in RAM; the encoder seeks back to the start on Close to
+	// fill in the MD5 sum and sample count of the STREAMINFO block.
+	file := &writerseeker.WriterSeeker{}
+
 	streamInfo := &meta.StreamInfo{
+		BlockSizeMin:  blockSize,
+		BlockSizeMax:  blockSize,
+		SampleRate:    uint32(sampleRate),
 		NChannels:     uint8(numChannels),
-		BitsPerSample: uint8(bitsPerSample),
-		SampleRate:    sampleRate,
+		BitsPerSample: bitsPerSample,
 	}
 
-	// Write FLAC metadata
 	encoder, err := flac.NewEncoder(file, streamInfo)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create FLAC encoder: %w", err)
 	}
-	defer encoder.Close()
-
-	// Calculate block align
-	blockAlign := int(numChannels) * int(bitsPerSample) / 8
-
-	// Create a buffer to hold the sample data for each block
-	sampleData := make([]byte, blockSize*blockAlign)
-	frameNumber := uint64(0)
-
-	for {
-		// Read the correct number of samples for the block
-		n, err := io.ReadFull(wavReader, sampleData)
-		if err != nil {
-			if err == io.EOF || err == io.ErrUnexpectedEOF {
-				// Handle the case where the last block may be smaller than the block size
-				sampleData = sampleData[:n]
-			} else {
-				return nil, fmt.Errorf("failed to read WAV samples: %w", err)
-			}
-		}
-
-		if n == 0 {
-			break
-		}
 
-		// Create a frame header
-		frameHeader := frame.Header{
-			HasFixedBlockSize: true,
-			BlockSize:         uint16(len(sampleData) / blockAlign),
-			SampleRate:        sampleRate,
-			Channels:          frame.Channels(numChannels - 1),
-			BitsPerSample:     uint8(bitsPerSample),
-			Num:               frameNumber,
+	numFrames := len(samples) / numChannels
+	for start := 0; start < numFrames; start += blockSize {
+		n := blockSize
+		if start+n > numFrames {
+			n = numFrames - start
 		}
 
-		// Encode the sample data into FLAC frames
 		flacFrame := &frame.Frame{
-			Header:    frameHeader,
+			Header: frame.Header{
+				HasFixedBlockSize: true,
+				BlockSize:         uint16(n),
+				SampleRate:        uint32(sampleRate),
+				Channels:          frame.Channels(numChannels - 1),
+				BitsPerSample:     bitsPerSample,
+			},
 			Subframes: make([]*frame.Subframe, numChannels),
 		}
 
-		// For each channel, create a subframe
-		for ch := 0; ch < int(numChannels); ch++ {
-			samples := make([]int32, len(sampleData)/blockAlign)
-			for i := 0; i < len(sampleData)/blockAlign; i++ {
-				offset := i*blockAlign + ch*int(bitsPerSample/8)
-				samples[i] = int32(binary.LittleEndian.Uint16(sampleData[offset:]))
-			}
-			flacFrame.Subframes[ch] = &frame.Subframe{
-				Samples: samples,
+		// Deinterleave each channel into its own subframe
+		for ch := 0; ch < numChannels; ch++ {
+			channel := make([]int32, n)
+			for i := range channel {
+				channel[i] = int32(samples[(start+i)*numChannels+ch])
 			}
+			flacFrame.Subframes[ch] = newSubframe(channel, bitsPerSample)
 		}
 
-		// Write frame to FLAC encoder
 		if err := encoder.WriteFrame(flacFrame); err != nil {
 			return nil, fmt.Errorf("failed to write FLAC frame: %w", err)
 		}
+	}
 
-		frameNumber++
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close FLAC encoder: %w", err)
 	}
 
-	// Seek back to the beginning of the emulated file
-	if _, err := file.Seek(0, io.SeekStart); err != nil {
-		return nil, fmt.Errorf("failed to seek in emulated file: %w", err)
+	// The encoder records the short final block as the minimum block size,
+	// which decoders reject; the minimum is defined excluding the last block.
+	if err := writeBlockSizes(file, numFrames); err != nil {
+		return nil, err
 	}
 
-	// Read the entire FLAC data from the emulated file
 	flacData, err := io.ReadAll(file.Reader())
 	if err != nil {
 		return nil, fmt.Errorf("failed to read FLAC data: %w", err)
@@ -127,78 +121,23 @@ func EncodeFLAC(wavData []byte) ([]byte, error) {
 	return flacData, nil
 }
 
-func getExecutableFLAC() (string, error) {
-	flacConverter, err := exec.LookPath("flac")
-	if err != nil {
-		basePath, err := filepath.Abs(filepath.Join("..", "files"))
-		if err != nil {
-			return "", err
-		}
-		system, arch := runtime.GOOS, runtime.GOARCH
-		// todo add support for windows
-		switch {
-		case system == "windows" && (arch == "386" || arch == "amd64"):
-			flacConverter = filepath.Join(basePath, "flac-win32.exe")
-		case system == "darwin" && (arch == "386" || arch == "amd64" || arch == "arm64"):
-			flacConverter = filepath.Join(basePath, "flac-macos")
-		case system == "linux" && (arch == "386"):
-			// TODO needs support on linux x86
-			//flacConverter = filepath.Join(basePath, "flac-linux-x86")
-			return "", errors.New("go does not support this arch yet, try installing flac for global access")
-		case system == "linux" && (arch == "amd64" || arch == "x86_64"):
-			flacConverter = filepath.Join(basePath, "flac-linux-x86_64")
-		default:
-			return "", errors.New("go does not support this arch")
-		}
-
-		// Ensure the FLAC converter is executable
-		if err := ensureExecutable(flacConverter); err != nil {
-			return "", err
-		}
+// writeBlockSizes overwrites the minimum and maximum block size of the
+// STREAMINFO block, which follow the "fLaC" signature and block header.
+func writeBlockSizes(file io.WriteSeeker, numFrames int) error {
+	size := blockSize
+	if numFrames < blockSize {
+		size = max(numFrames, minBlockSize)
 	}
 
-	return flacConverter, nil
-}
+	var sizes [4]byte
+	binary.BigEndian.PutUint16(sizes[0:], uint16(size))
+	binary.BigEndian.PutUint16(sizes[2:], uint16(size))
 
-// ensureExecutable ensures that the file at the given path is executable.
-func ensureExecutable(path string) error {
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return err
+	if _, err := file.Seek(streamInfoOffset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek in emulated file: %w", err)
 	}
-	if err := os.Chmod(path, 0755); err != nil {
-		return err
+	if _, err := file.Write(sizes[:]); err != nil {
+		return fmt.Errorf("failed to update STREAMINFO: %w", err)
 	}
 	return nil
 }
-
-// AudioData represents the audio data structure.
-type AudioData struct {
-	sampleWidth int
-}
-
-func EncodeFLACExecutable(wavData []byte, sampleWidth, convertWidth int) ([]byte, error) {
-	// EncodeFLACExecutable returns a byte slice representing the contents of a FLAC file containing the audio represented by the AudioData instance.
-	if convertWidth != 0 && (convertWidth < 1 || convertWidth > 3) {
-		return nil, errors.New("sample width to convert to must be between 1 and 3 inclusive")
-	}
-
-	if sampleWidth > 3 && convertWidth == 0 {
-		convertWidth = 3 // Limit the sample width to 24-bit if the original is 32-bit
-	}
-
-	flacConverter, err := getExecutableFLAC()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get FLAC converter: %w", err)
-	}
-
-	cmd := exec.Command(flacConverter, "--stdout", "--totally-silent", "--best", "-")
-	cmd.Stdin = bytes.NewReader(wavData)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("failed to run FLAC converter: %w", err)
-	}
-
-	return out.Bytes(), nil
-}
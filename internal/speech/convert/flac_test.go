@@ -0,0 +1,148 @@
+package convert
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"io"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/go-audio/audio"
+	"github.com/go-audio/wav"
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/orcaman/writerseeker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decode reads a FLAC stream back into interleaved samples.
+func decode(t *testing.T, flacData []byte) (*flac.Stream, []int) {
+	t.Helper()
+	stream, err := flac.New(bytes.NewReader(flacData))
+	require.NoError(t, err)
+
+	var samples []int
+	for {
+		f, err := stream.ParseNext()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		for i := 0; i < int(f.BlockSize); i++ {
+			for _, sub := range f.Subframes {
+				samples = append(samples, int(sub.Samples[i]))
+			}
+		}
+	}
+	return stream, samples
+}
+
+func md5Sum(samples []int) [md5.Size]byte {
+	buf := make([]byte, 2*len(samples))
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(buf[2*i:], uint16(int16(s)))
+	}
+	return md5.Sum(buf)
+}
+
+func TestEncodeSamplesRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	tests := map[string]struct {
+		channels int
+		samples  []int
+	}{
+		"silence": {1, make([]int, 3000)},
+		"sine": {1, func() []int {
+			s := make([]int, 10000)
+			for i := range s {
+				s[i] = int(20000 * math.Sin(float64(i)*0.05))
+			}
+			return s
+		}()},
+		"noise": {1, func() []int {
+			s := make([]int, 5000)
+			for i := range s {
+				s[i] = rng.Intn(65536) - 32768
+			}
+			return s
+		}()},
+		"extremes": {1, []int{math.MinInt16, math.MaxInt16, math.MinInt16, 0, -1, 1, math.MaxInt16}},
+		"stereo": {2, func() []int {
+			s := make([]int, 2*blockSize+20)
+			for i := range s {
+				s[i] = int(-12000 * math.Cos(float64(i/2)*0.01*float64(1+i%2)))
+			}
+			return s
+		}()},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			flacData, err := EncodeSamples(tc.samples, 16000, tc.channels)
+			require.NoError(t, err)
+
+			stream, decoded := decode(t, flacData)
+			assert.Equal(t, tc.samples, decoded)
+
+			info := stream.Info
+			assert.Equal(t, uint32(16000), info.SampleRate)
+			assert.Equal(t, uint8(tc.channels), info.NChannels)
+			assert.Equal(t, uint8(16), info.BitsPerSample)
+			assert.Equal(t, uint64(len(tc.samples)/tc.channels), info.NSamples)
+			assert.Equal(t, md5Sum(tc.samples), info.MD5sum)
+		})
+	}
+}
+
+func TestEncodeFLACFromWAV(t *testing.T) {
+	samples := make([]int, 16000)
+	for i := range samples {
+		samples[i] = int(15000 * math.Sin(float64(i)*2*math.Pi*440/16000))
+	}
+
+	file := &writerseeker.WriterSeeker{}
+	encoder := wav.NewEncoder(file, 16000, 16, 1, 1)
+	require.NoError(t, encoder.Write(&audio.IntBuffer{
+		Format:         &audio.Format{SampleRate: 16000, NumChannels: 1},
+		Data:           samples,
+		SourceBitDepth: 16,
+	}))
+	require.NoError(t, encoder.Close())
+	wavData, err := io.ReadAll(file.Reader())
+	require.NoError(t, err)
+
+	flacData, err := EncodeFLAC(wavData)
+	require.NoError(t, err)
+	assert.Less(t, len(flacData), len(wavData)/2, "a pure tone should compress")
+
+	_, decoded := decode(t, flacData)
+	assert.Equal(t, samples, decoded)
+}
+
+func TestNewSubframePrediction(t *testing.T) {
+	constant := []int32{7, 7, 7, 7}
+	assert.Equal(t, frame.PredConstant, newSubframe(constant, 16).Pred)
+
+	ramp := make([]int32, 256)
+	for i := range ramp {
+		ramp[i] = int32(i*3 - 300)
+	}
+	sub := newSubframe(ramp, 16)
+	assert.Equal(t, frame.PredFixed, sub.Pred)
+	assert.Equal(t, 2, sub.Order, "a linear ramp is predicted exactly by order 2")
+}
+
+func TestEncodeFLACRejectsUnsupportedInput(t *testing.T) {
+	_, err := EncodeFLAC([]byte("not a wav file"))
+	assert.Error(t, err)
+
+	_, err = EncodeSamples(nil, 16000, 1)
+	assert.Error(t, err)
+
+	_, err = EncodeSamples([]int{1, 2, 3}, 16000, 2)
+	assert.Error(t, err)
+}
@@ -0,0 +1,105 @@
+package convert
+
+import (
+	"github.com/mewkiz/flac/frame"
+)
+
+const (
+	maxFixedOrder = 4
+	// Rice parameters above this need the 5-bit parameter coding method;
+	// 15 is the escape code of the 4-bit method.
+	maxRice1Param = 14
+	maxRice2Param = 30
+)
+
+// newSubframe picks the cheapest encoding for one channel of a block.
+func newSubframe(samples []int32, bps uint) *frame.Subframe {
+	subframe := &frame.Subframe{
+		Samples:  samples,
+		NSamples: len(samples),
+	}
+
+	if isConstant(samples) {
+		subframe.Pred = frame.PredConstant
+		return subframe
+	}
+
+	subframe.Pred = frame.PredVerbatim
+	bestBits := uint64(len(samples)) * uint64(bps)
+
+	for order := 0; order <= maxFixedOrder && order < len(samples); order++ {
+		param, residualBits := riceParam(fixedResiduals(samples, order))
+
+		// 2 bits coding method, 4 bits partition order, then the parameter.
+		paramBits := uint64(4)
+		if param > maxRice1Param {
+			paramBits = 5
+		}
+		bits := uint64(order)*uint64(bps) + 6 + paramBits + residualBits
+
+		if bits < bestBits {
+			bestBits = bits
+			subframe.Pred = frame.PredFixed
+			subframe.Order = order
+			subframe.ResidualCodingMethod = frame.ResidualCodingMethodRice1
+			if param > maxRice1Param {
+				subframe.ResidualCodingMethod = frame.ResidualCodingMethodRice2
+			}
+			subframe.RiceSubframe = &frame.RiceSubframe{
+				PartOrder:  0,
+				Partitions: []frame.RicePartition{{Param: param}},
+			}
+		}
+	}
+
+	return subframe
+}
+
+func isConstant(samples []int32) bool {
+	for _, s := range samples[1:] {
+		if s != samples[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// fixedResiduals returns the prediction error of the fixed polynomial of the
+// given order, matching the residuals the FLAC encoder computes.
+func fixedResiduals(samples []int32, order int) []int32 {
+	coeffs := frame.FixedCoeffs[order]
+	residuals := make([]int32, 0, len(samples)-order)
+	for i := order; i < len(samples); i++ {
+		var prediction int64
+		for j, c := range coeffs {
+			prediction += int64(c) * int64(samples[i-j-1])
+		}
+		residuals = append(residuals, samples[i]-int32(prediction))
+	}
+	return residuals
+}
+
+// riceParam returns the Rice parameter that codes residuals in the fewest
+// bits, along with that size.
+func riceParam(residuals []int32) (uint, uint64) {
+	folded := make([]uint32, len(residuals))
+	for i, r := range residuals {
+		folded[i] = uint32(r<<1) ^ uint32(r>>31) // ZigZag
+	}
+
+	var (
+		bestParam uint
+		bestBits  = ^uint64(0)
+	)
+	for k := uint(0); k <= maxRice2Param; k++ {
+		// Each residual takes a unary high part, a stop bit and k low bits.
+		bits := uint64(len(folded)) * uint64(k+1)
+		for _, f := range folded {
+			bits += uint64(f >> k)
+		}
+		if bits < bestBits {
+			bestParam, bestBits = k, bits
+		}
+	}
+	return bestParam, bestBits
+}
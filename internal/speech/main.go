@@ -1,20 +1,57 @@
 package speech
 
 import (
+	"context"
+	"errors"
 	speechCmd "github.com/bz888/blab/internal/speech/cmd"
 	"github.com/bz888/blab/internal/speech/config"
 	"github.com/bz888/blab/internal/speech/output_api"
+	"github.com/bz888/blab/internal/speech/pipeline"
+	"github.com/bz888/blab/internal/speech/recorder"
 )
 
+var voiceRecorder *recorder.Recorder
+
 func Init() {
 	config.Init()
 	output_api.Init()
+	voiceRecorder = speechCmd.NewRecorder()
 }
 
-func Run() (string, error) {
-	outputText, err := speechCmd.Run()
-	if err != nil {
-		return "", err
+// Run listens on the microphone until one utterance has been transcribed,
+// or ctx is cancelled.
+func Run(ctx context.Context) (pipeline.Transcription, error) {
+	if config.Disable {
+		return pipeline.Transcription{}, errors.New("GOOGLE_API_KEY is not set, voice recognition is disabled")
 	}
-	return outputText, nil
+	return voiceRecorder.Run(ctx)
+}
+
+// Stop cancels a voice recording in progress.
+func Stop() {
+	voiceRecorder.Stop()
+}
+
+// Close stops recording and releases the audio device library.
+func Close() {
+	voiceRecorder.Stop()
+	speechCmd.Terminate()
+}
+
+func TranscribeFile(path string) (string, error) {
+	return speechCmd.TranscribeFile(path)
+}
+
+func Replay(dir string) (string, error) {
+	return speechCmd.Replay(dir)
+}
+
+// SetLanguage changes the recognition language, see config.ParseLanguages.
+func SetLanguage(spec string) error {
+	return config.SetLanguages(spec)
+}
+
+// Language describes the current recognition language.
+func Language() string {
+	return config.LanguageLabel()
 }
@@ -2,6 +2,7 @@ package output_api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,6 +13,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"time"
 )
 
 type Alternative struct {
@@ -35,31 +37,39 @@ type OutputParser struct {
 
 var localLogger *logger.Logger
 
+// requestTimeout bounds a recognition request, so a stalled connection
+// cannot hold a recording open.
+const requestTimeout = 30 * time.Second
+
+var httpClient = &http.Client{Timeout: requestTimeout}
+
 func Init() {
 	localLogger = logger.NewLogger("google")
 }
 
-func buildRecogniserRequestGoogle(audioData []byte) *http.Request {
-	err := godotenv.Load()
-	if err != nil {
-		localLogger.Fatal("Error loadin .env file: %v", err)
+func buildRecogniserRequestGoogle(ctx context.Context, audioData []byte, lang string) (*http.Request, error) {
+	// The key may come from the environment instead of a .env file.
+	if err := godotenv.Load(); err != nil {
+		localLogger.Debug("No .env file loaded", "err", err)
 	}
 	key := os.Getenv("GOOGLE_API_KEY")
+	if key == "" {
+		return nil, errors.New("GOOGLE_API_KEY is not set")
+	}
 	apiURL := "http://www.google.com/speech-api/v2/recognize"
 	data := url.Values{}
 	data.Set("client", "chromium")
-	data.Set("lang", "en-US")
+	data.Set("lang", lang)
 	data.Set("key", key)
 	data.Set("pFilter", "0")
 
-	req, err := http.NewRequest("POST", apiURL+"?"+data.Encode(), bytes.NewReader(audioData))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL+"?"+data.Encode(), bytes.NewReader(audioData))
 	if err != nil {
-		fmt.Println(err)
-		return nil
+		return nil, err
 	}
 
 	req.Header.Add("Content-Type", "audio/x-flac; rate=16000")
-	return req
+	return req, nil
 }
 
 func convertToResult(responseText string) (Result, error) {
@@ -106,82 +116,78 @@ func findBestHypothesis(alternatives []Alternative) (Alternative, error) {
 	return bestHypothesis, nil
 }
 
-func (op *OutputParser) parse(responseText string) (string, float64, error) {
+// parse returns the hypotheses in the response, best first. Without ShowAll
+// only the best one is kept; without WithConfidence scores are cleared.
+func (op *OutputParser) parse(responseText string) ([]Alternative, error) {
 	actualResult, err := convertToResult(responseText)
 	if err != nil {
-		return "", 0, err
-	}
-
-	if op.ShowAll {
-		return fmt.Sprintf("%+v", actualResult), 0, nil
+		return nil, err
 	}
 
 	bestHypothesis, err := findBestHypothesis(actualResult.Alternative)
 	if err != nil {
-		return "", 0, err
+		return nil, err
 	}
 
-	confidence := bestHypothesis.Confidence
-	if confidence == 0 {
-		confidence = 0.5
+	alternatives := []Alternative{bestHypothesis}
+	if op.ShowAll {
+		for _, alternative := range actualResult.Alternative {
+			if alternative != bestHypothesis && alternative.Transcript != "" {
+				alternatives = append(alternatives, alternative)
+			}
+		}
 	}
 
-	if op.WithConfidence {
-		return bestHypothesis.Transcript, confidence, nil
+	if !op.WithConfidence {
+		for i := range alternatives {
+			alternatives[i].Confidence = 0
+		}
 	}
 
-	return bestHypothesis.Transcript, 0, nil
+	return alternatives, nil
 }
 
-func sendRecogniserRequestGoogle(req *http.Request) (string, float64, error) {
-	client := &http.Client{}
-	resp, err := client.Do(req)
+func sendRecogniserRequestGoogle(req *http.Request) ([]Alternative, error) {
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		localLogger.Error("Error sending request:", err)
-		return "", 0, err
+		localLogger.Error("Error sending request", "err", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	localLogger.Info("Response Status: %s\n", resp.Status)
+	localLogger.Info("Response received", "status", resp.Status)
 
 	// Log the response headers
 	//localLogger.Info("Response Headers:")
 	//for key, values := range resp.Header {
 	//	for _, value := range values {
-	//		localLogger.Info("%s: %s\n", key, value)
+	//		localLogger.Debug("Response header", "key", key, "value", value)
 	//	}
 	//}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		localLogger.Info("Error reading response body:", err)
-		return "", 0, err
+		localLogger.Error("Error reading response body", "err", err)
+		return nil, err
 	}
 
 	op := OutputParser{
-		ShowAll:        false,
+		ShowAll:        true,
 		WithConfidence: true,
 	}
 
-	transcript, confidence, err := op.parse(string(body))
-	if err != nil {
-		return "", 0, err
-	}
-
-	return transcript, confidence, nil
+	return op.parse(string(body))
 }
 
-func Send(audioData []byte) (string, float64, error) {
-	req := buildRecogniserRequestGoogle(audioData)
-	if req == nil {
-		return "", 0, errors.New("failed to build request")
-	}
-
-	localLogger.Info("Sent")
-	transcript, confidence, err := sendRecogniserRequestGoogle(req)
+// Send transcribes FLAC audio spoken in lang, a BCP 47 tag, and returns every
+// alternative Google offers, best first. Google usually scores only the
+// first one. It gives up once ctx is done.
+func Send(ctx context.Context, audioData []byte, lang string) ([]Alternative, error) {
+	req, err := buildRecogniserRequestGoogle(ctx, audioData, lang)
 	if err != nil {
-		return "", 0, err
+		return nil, fmt.Errorf("building recognition request: %w", err)
 	}
 
-	return transcript, confidence, nil
+	localLogger.Info("Sent", "bytes", len(audioData), "lang", lang)
+	return sendRecogniserRequestGoogle(req)
 }
@@ -0,0 +1,110 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/bz888/blab/internal/speech/vad"
+	"github.com/go-audio/wav"
+)
+
+// ReadWAV decodes a PCM WAV file into mono 16-bit samples, returning them
+// with the file's sample rate. Multichannel files are averaged down to mono.
+func ReadWAV(r io.ReadSeeker) ([]int16, int, error) {
+	decoder := wav.NewDecoder(r)
+	if !decoder.IsValidFile() {
+		return nil, 0, errors.New("invalid WAV file")
+	}
+
+	buf, err := decoder.FullPCMBuffer()
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading PCM data: %w", err)
+	}
+
+	channels := buf.Format.NumChannels
+	if channels < 1 {
+		return nil, 0, fmt.Errorf("invalid channel count: %d", channels)
+	}
+
+	shift := int(decoder.BitDepth) - 16
+	samples := make([]int16, len(buf.Data)/channels)
+	for i := range samples {
+		var sum int
+		for ch := 0; ch < channels; ch++ {
+			sum += buf.Data[i*channels+ch]
+		}
+		sample := sum / channels
+		switch {
+		case shift > 0:
+			sample >>= shift
+		case shift < 0:
+			// 8-bit WAV is unsigned.
+			sample = (sample - 128) << -shift
+		}
+		samples[i] = int16(sample)
+	}
+
+	return samples, buf.Format.SampleRate, nil
+}
+
+// Segment splits 16 kHz samples into utterances with a new endpointer, the
+// way the microphone loop splits a live stream. The classifier sees every
+// frame in order, so detectors that keep state between frames, such as
+// Silero, classify speech spanning many frames correctly.
+func Segment(samples []int16, endpointer *vad.Endpointer) ([]vad.Utterance, error) {
+	utterances, err := endpointer.Write(samples)
+	if err != nil {
+		return nil, fmt.Errorf("detect voice: %w", err)
+	}
+	if u := endpointer.Flush(); u != nil {
+		utterances = append(utterances, *u)
+	}
+	return utterances, nil
+}
+
+// TranscribeFile segments a recorded WAV file by voice activity with a new
+// endpointer and transcribes each segment in order.
+func (p *Pipeline) TranscribeFile(ctx context.Context, path string, endpointer *vad.Endpointer) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	samples, rate, err := ReadWAV(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	segments, err := Segment(Resample(samples, rate), endpointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 0 {
+		return nil, ErrNoVoice
+	}
+
+	var (
+		transcripts []string
+		errs        []error
+	)
+	for i, segment := range segments {
+		text, _, err := p.Transcribe(ctx, segment.Samples)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("segment %d: %w", i, err))
+			continue
+		}
+		if text = strings.TrimSpace(text); text != "" {
+			transcripts = append(transcripts, text)
+		}
+	}
+
+	if len(transcripts) == 0 && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return transcripts, nil
+}
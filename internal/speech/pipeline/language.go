@@ -0,0 +1,58 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// LanguageTranscriber recognises audio in the given language.
+type LanguageTranscriber func(ctx context.Context, audioData []byte, language string) ([]Alternative, error)
+
+// DetectLanguage sends the audio in every candidate language at once and
+// keeps the hypotheses of the language whose best one scored highest. It is
+// for backends that cannot identify the language themselves, and costs one
+// request per candidate. Confidences from different language models are
+// only roughly comparable, so callers should keep the candidates few and
+// distinct. Ties go to the earlier candidate, and results are tagged with
+// their language.
+func DetectLanguage(ctx context.Context, audioData []byte, languages []string, send LanguageTranscriber) ([]Alternative, error) {
+	type result struct {
+		alternatives []Alternative
+		err          error
+	}
+	results := make([]result, len(languages))
+	done := make(chan struct{})
+	for i, language := range languages {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			alternatives, err := send(ctx, audioData, language)
+			for j := range alternatives {
+				alternatives[j].Language = language
+			}
+			results[i] = result{alternatives, err}
+		}()
+	}
+	for range languages {
+		<-done
+	}
+
+	best := -1
+	var errs []error
+	for i, r := range results {
+		switch {
+		case r.err != nil:
+			errs = append(errs, fmt.Errorf("%s: %w", languages[i], r.err))
+		case len(r.alternatives) == 0:
+		case best < 0 || r.alternatives[0].Confidence > results[best].alternatives[0].Confidence:
+			best = i
+		}
+	}
+	if best < 0 {
+		if len(errs) == 0 {
+			return nil, errors.New("no transcript in any language")
+		}
+		return nil, errors.Join(errs...)
+	}
+	return results[best].alternatives, nil
+}
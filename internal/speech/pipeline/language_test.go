@@ -0,0 +1,39 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectLanguagePicksMostConfident(t *testing.T) {
+	send := func(_ context.Context, _ []byte, language string) ([]Alternative, error) {
+		switch language {
+		case "en-US":
+			return []Alternative{{Text: "guten tack", Confidence: 0.4}}, nil
+		case "de-DE":
+			return []Alternative{{Text: "guten Tag", Confidence: 0.92}, {Text: "guten Tak"}}, nil
+		default:
+			return nil, errors.New("no result")
+		}
+	}
+
+	alternatives, err := DetectLanguage(context.Background(), nil, []string{"en-US", "fr-FR", "de-DE"}, send)
+	require.NoError(t, err)
+	require.Len(t, alternatives, 2)
+	assert.Equal(t, "guten Tag", alternatives[0].Text)
+	assert.Equal(t, "de-DE", alternatives[0].Language)
+	assert.Equal(t, "de-DE", alternatives[1].Language)
+}
+
+func TestDetectLanguageAllFail(t *testing.T) {
+	send := func(_ context.Context, _ []byte, language string) ([]Alternative, error) {
+		return nil, errors.New("backend down")
+	}
+
+	_, err := DetectLanguage(context.Background(), nil, []string{"en-US", "de-DE"}, send)
+	assert.EqualError(t, err, "en-US: backend down\nde-DE: backend down")
+}
@@ -0,0 +1,179 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/bz888/blab/internal/speech/sound"
+	"github.com/go-audio/audio"
+	"github.com/go-audio/wav"
+	"github.com/orcaman/writerseeker"
+)
+
+// SampleRate is the rate every stage after resampling works with.
+// Silero and the Google recogniser both expect 16 kHz mono.
+const SampleRate = 16000
+
+var ErrNoVoice = errors.New("no voice detected")
+
+// Detector reports whether a 16 kHz mono buffer contains speech.
+type Detector interface {
+	DetectVoice(buffer *audio.IntBuffer) (bool, error)
+}
+
+// Encoder turns a WAV file into the format the transcriber accepts.
+type Encoder func(wavData []byte) ([]byte, error)
+
+// Alternative is one hypothesis returned by the transcriber. Confidence is
+// zero when the backend did not score it.
+type Alternative struct {
+	Text       string  `json:"text"`
+	Confidence float64 `json:"confidence"`
+	// Language is the BCP 47 tag the audio was recognised as.
+	Language string `json:"language,omitempty"`
+}
+
+// Transcriber sends encoded audio to a speech recognition backend and
+// returns its hypotheses, best first. It gives up once ctx is done.
+type Transcriber interface {
+	Send(ctx context.Context, audioData []byte) ([]Alternative, error)
+}
+
+// TranscriberFunc adapts a plain function to the Transcriber interface.
+type TranscriberFunc func(ctx context.Context, audioData []byte) ([]Alternative, error)
+
+func (f TranscriberFunc) Send(ctx context.Context, audioData []byte) ([]Alternative, error) {
+	return f(ctx, audioData)
+}
+
+// Pipeline holds the post-capture stages: resample -> VAD -> WAV -> FLAC -> transcriber.
+// It does not know where samples come from, so the microphone loop and file
+// transcription share it.
+type Pipeline struct {
+	detector    Detector
+	encoder     Encoder
+	transcriber Transcriber
+}
+
+func New(detector Detector, encoder Encoder, transcriber Transcriber) *Pipeline {
+	return &Pipeline{
+		detector:    detector,
+		encoder:     encoder,
+		transcriber: transcriber,
+	}
+}
+
+// Resample converts samples captured at inputRate to SampleRate. The result
+// never aliases samples, so callers may reuse their capture buffer.
+func Resample(samples []int16, inputRate int) []int16 {
+	if inputRate == SampleRate || len(samples) == 0 {
+		return append([]int16(nil), samples...)
+	}
+	return sound.ResampleInt16(samples, inputRate, SampleRate)
+}
+
+// Detect runs the voice activity detector over a 16 kHz segment.
+func (p *Pipeline) Detect(samples []int16) (bool, error) {
+	detected, err := p.detector.DetectVoice(newIntBuffer(samples))
+	if err != nil {
+		return false, fmt.Errorf("detect voice: %w", err)
+	}
+	return detected, nil
+}
+
+// Transcription holds everything produced while transcribing one segment,
+// so a session can be recorded and inspected later. Text, Confidence and
+// Language repeat the best of Alternatives.
+type Transcription struct {
+	WAV          []byte
+	FLAC         []byte
+	Text         string
+	Confidence   float64
+	Language     string
+	Alternatives []Alternative
+}
+
+// Transcribe encodes a 16 kHz segment and sends it to the transcriber.
+func (p *Pipeline) Transcribe(ctx context.Context, samples []int16) (string, float64, error) {
+	t, err := p.TranscribeSegment(ctx, samples)
+	return t.Text, t.Confidence, err
+}
+
+// TranscribeSegment is Transcribe keeping the intermediate audio. On error
+// the artifacts produced before the failing stage are still returned.
+func (p *Pipeline) TranscribeSegment(ctx context.Context, samples []int16) (Transcription, error) {
+	var t Transcription
+	wavData, err := EncodeWAV(samples)
+	if err != nil {
+		return t, err
+	}
+	t.WAV = wavData
+
+	flacData, err := p.encoder(wavData)
+	if err != nil {
+		return t, fmt.Errorf("FLAC encoding error: %w", err)
+	}
+	if len(flacData) == 0 {
+		return t, errors.New("FLAC data is empty")
+	}
+	t.FLAC = flacData
+
+	t.Alternatives, err = p.transcriber.Send(ctx, flacData)
+	if err != nil {
+		return t, err
+	}
+	if len(t.Alternatives) > 0 {
+		t.Text = t.Alternatives[0].Text
+		t.Confidence = t.Alternatives[0].Confidence
+		t.Language = t.Alternatives[0].Language
+	}
+	return t, nil
+}
+
+// Process runs the detector and, when voice is present, the transcriber.
+// It returns ErrNoVoice when the segment holds no speech.
+func (p *Pipeline) Process(ctx context.Context, samples []int16) (string, float64, error) {
+	detected, err := p.Detect(samples)
+	if err != nil {
+		return "", 0, err
+	}
+	if !detected {
+		return "", 0, ErrNoVoice
+	}
+	return p.Transcribe(ctx, samples)
+}
+
+// EncodeWAV writes 16 kHz mono samples into an in-memory WAV file.
+func EncodeWAV(samples []int16) ([]byte, error) {
+	// Emulate a file in RAM so that we don't have to create a real file.
+	file := &writerseeker.WriterSeeker{}
+	encoder := wav.NewEncoder(file, SampleRate, 16, 1, 1)
+
+	if err := encoder.Write(newIntBuffer(samples)); err != nil {
+		return nil, fmt.Errorf("encoder write buffer: %w", err)
+	}
+
+	// Close the encoder to finalize the WAV file headers
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("encoder close: %w", err)
+	}
+
+	wavData, err := io.ReadAll(file.Reader())
+	if err != nil {
+		return nil, fmt.Errorf("reading WAV file into memory: %w", err)
+	}
+	if len(wavData) == 0 {
+		return nil, errors.New("WAV data is empty")
+	}
+	return wavData, nil
+}
+
+func newIntBuffer(samples []int16) *audio.IntBuffer {
+	return &audio.IntBuffer{
+		Format:         &audio.Format{SampleRate: SampleRate, NumChannels: 1},
+		Data:           sound.ConvertInt16ToInt(samples),
+		SourceBitDepth: 16,
+	}
+}
@@ -0,0 +1,249 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bz888/blab/internal/speech/sound"
+	"github.com/bz888/blab/internal/speech/vad"
+	"github.com/go-audio/audio"
+	"github.com/go-audio/wav"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// energyDetector stands in for Silero: any window louder than threshold is voice.
+type energyDetector struct {
+	threshold float64
+}
+
+func (d energyDetector) DetectVoice(buffer *audio.IntBuffer) (bool, error) {
+	return level(buffer.Data) > d.threshold, nil
+}
+
+func (d energyDetector) IsSpeech(frame []int16) (bool, error) {
+	return level(sound.ConvertInt16ToInt(frame)) > d.threshold, nil
+}
+
+// statefulDetector behaves like Silero: it remembers between calls whether
+// speech is under way, and DetectVoice fails when speech that started in an
+// earlier call ends in this one.
+type statefulDetector struct {
+	energyDetector
+	speaking bool
+}
+
+func (d *statefulDetector) DetectVoice(buffer *audio.IntBuffer) (bool, error) {
+	started := d.speaking
+	detected := false
+	for start := 0; start+testFrameSize <= len(buffer.Data); start += testFrameSize {
+		voice := level(buffer.Data[start:start+testFrameSize]) > d.threshold
+		if started && !voice {
+			return false, errors.New("unexpected speech end")
+		}
+		d.speaking = voice
+		detected = detected || voice
+	}
+	return detected, nil
+}
+
+func (d *statefulDetector) IsSpeech(frame []int16) (bool, error) {
+	d.speaking = level(sound.ConvertInt16ToInt(frame)) > d.threshold
+	return d.speaking, nil
+}
+
+func level(samples []int) float64 {
+	var sumSquares float64
+	for _, s := range samples {
+		sumSquares += float64(s) * float64(s)
+	}
+	return math.Sqrt(sumSquares / float64(len(samples)))
+}
+
+const testFrameSize = 512
+
+func newTestEndpointer(t *testing.T, classifier vad.FrameClassifier) *vad.Endpointer {
+	t.Helper()
+	cfg := vad.DefaultEndpointerConfig()
+	cfg.FrameSize = testFrameSize
+	cfg.MaxUtterance = 25 * time.Second
+	e, err := vad.NewEndpointer(classifier, cfg)
+	require.NoError(t, err)
+	return e
+}
+
+// recordingTranscriber returns a numbered transcript and remembers what it was sent.
+type recordingTranscriber struct {
+	sent [][]byte
+}
+
+func (r *recordingTranscriber) Send(_ context.Context, audioData []byte) ([]Alternative, error) {
+	r.sent = append(r.sent, audioData)
+	return []Alternative{{Text: fmt.Sprintf("segment %d", len(r.sent)), Confidence: 0.9}}, nil
+}
+
+func passthrough(wavData []byte) ([]byte, error) {
+	return wavData, nil
+}
+
+type span struct {
+	seconds float64
+	tone    bool
+}
+
+// synth builds mono samples alternating between silence and a 440 Hz tone.
+func synth(rate int, spans ...span) []int16 {
+	var samples []int16
+	for _, s := range spans {
+		n := int(s.seconds * float64(rate))
+		for i := 0; i < n; i++ {
+			var v float64
+			if s.tone {
+				v = 8000 * math.Sin(2*math.Pi*440*float64(i)/float64(rate))
+			}
+			samples = append(samples, int16(v))
+		}
+	}
+	return samples
+}
+
+// writeFixture writes samples to a WAV file, duplicating them across channels.
+func writeFixture(t *testing.T, samples []int16, rate, channels int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fixture.wav")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	data := make([]int, 0, len(samples)*channels)
+	for _, s := range samples {
+		for ch := 0; ch < channels; ch++ {
+			data = append(data, int(s))
+		}
+	}
+
+	encoder := wav.NewEncoder(file, rate, 16, channels, 1)
+	require.NoError(t, encoder.Write(&audio.IntBuffer{
+		Format:         &audio.Format{SampleRate: rate, NumChannels: channels},
+		Data:           data,
+		SourceBitDepth: 16,
+	}))
+	require.NoError(t, encoder.Close())
+	return path
+}
+
+func TestTranscribeFileSegmentsByVoice(t *testing.T) {
+	samples := synth(SampleRate,
+		span{1, false}, span{1, true}, span{1, false}, span{1.5, true}, span{0.5, false},
+	)
+	path := writeFixture(t, samples, SampleRate, 1)
+
+	transcriber := &recordingTranscriber{}
+	detector := energyDetector{threshold: 500}
+	p := New(detector, passthrough, transcriber)
+
+	transcripts, err := p.TranscribeFile(context.Background(), path, newTestEndpointer(t, detector))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"segment 1", "segment 2"}, transcripts)
+
+	// Each segment holds its tone with pre-roll before it and the silence
+	// waited out after it.
+	require.Len(t, transcriber.sent, 2)
+	for i, tone := range []float64{1, 1.5} {
+		got, rate, err := ReadWAV(bytes.NewReader(transcriber.sent[i]))
+		require.NoError(t, err)
+		assert.Equal(t, SampleRate, rate)
+		assert.Greater(t, len(got), int(tone*SampleRate))
+		assert.Less(t, len(got), int((tone+1.2)*SampleRate))
+	}
+}
+
+func TestTranscribeFileWithStatefulDetector(t *testing.T) {
+	// Speech lasting longer than any window the detector could be handed at
+	// once must not trip over state kept from earlier calls.
+	samples := synth(SampleRate, span{1, false}, span{3, true}, span{1, false}, span{2, true}, span{1, false})
+	path := writeFixture(t, samples, SampleRate, 1)
+
+	transcriber := &recordingTranscriber{}
+	detector := &statefulDetector{energyDetector: energyDetector{threshold: 500}}
+	p := New(detector, passthrough, transcriber)
+
+	transcripts, err := p.TranscribeFile(context.Background(), path, newTestEndpointer(t, detector))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"segment 1", "segment 2"}, transcripts)
+}
+
+func TestTranscribeFileWithoutVoice(t *testing.T) {
+	path := writeFixture(t, synth(SampleRate, span{2, false}), SampleRate, 1)
+
+	transcriber := &recordingTranscriber{}
+	detector := energyDetector{threshold: 500}
+	p := New(detector, passthrough, transcriber)
+
+	_, err := p.TranscribeFile(context.Background(), path, newTestEndpointer(t, detector))
+	assert.ErrorIs(t, err, ErrNoVoice)
+	assert.Empty(t, transcriber.sent)
+}
+
+func TestReadWAVDownmixesStereo(t *testing.T) {
+	samples := synth(48000, span{0.5, true})
+	path := writeFixture(t, samples, 48000, 2)
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	got, rate, err := ReadWAV(file)
+	require.NoError(t, err)
+	assert.Equal(t, 48000, rate)
+	assert.Equal(t, samples, got)
+
+	assert.InDelta(t, len(samples)/3, len(Resample(got, rate)), 1)
+}
+
+func TestSegmentSplitsLongSpeech(t *testing.T) {
+	samples := synth(SampleRate, span{1, false}, span{30, true})
+
+	segments, err := Segment(samples, newTestEndpointer(t, energyDetector{threshold: 500}))
+	require.NoError(t, err)
+	require.Len(t, segments, 2)
+	assert.InDelta(t, 25*SampleRate, len(segments[0].Samples), testFrameSize)
+	// The speech runs on into the second segment, up to the last whole frame.
+	assert.InDelta(t, len(samples), len(segments[0].Samples)+len(segments[1].Samples)+int(segments[0].Start.Seconds()*SampleRate), testFrameSize)
+}
+
+func TestProcessSkipsSilence(t *testing.T) {
+	transcriber := &recordingTranscriber{}
+	p := New(energyDetector{threshold: 500}, passthrough, transcriber)
+
+	_, _, err := p.Process(context.Background(), synth(SampleRate, span{1, false}))
+	assert.ErrorIs(t, err, ErrNoVoice)
+
+	text, conf, err := p.Process(context.Background(), synth(SampleRate, span{1, true}))
+	require.NoError(t, err)
+	assert.Equal(t, "segment 1", text)
+	assert.Equal(t, 0.9, conf)
+}
+
+func TestTranscribeSegmentKeepsArtifactsOnError(t *testing.T) {
+	failing := TranscriberFunc(func(context.Context, []byte) ([]Alternative, error) {
+		return nil, errors.New("backend down")
+	})
+	flac := func(wavData []byte) ([]byte, error) {
+		return append([]byte("fLaC"), wavData...), nil
+	}
+	p := New(energyDetector{threshold: 500}, flac, failing)
+
+	result, err := p.TranscribeSegment(context.Background(), synth(SampleRate, span{1, true}))
+	assert.EqualError(t, err, "backend down")
+	require.NotEmpty(t, result.WAV)
+	assert.Equal(t, append([]byte("fLaC"), result.WAV...), result.FLAC)
+	assert.Empty(t, result.Text)
+}
@@ -0,0 +1,75 @@
+package pipeline
+
+import (
+	"strings"
+)
+
+// LowConfidence is the score below which a transcript should be confirmed
+// by the user before it is sent.
+const LowConfidence = 0.75
+
+// Word is one word of the best transcript.
+type Word struct {
+	Text string
+	// Uncertain is set when the word may have been misheard.
+	Uncertain bool
+}
+
+// Words splits the best transcript into words and flags the doubtful ones.
+// Google only scores whole transcripts, so a word is uncertain when another
+// alternative leaves it out; with a single low-confidence alternative every
+// word is.
+func (t Transcription) Words() []Word {
+	fields := strings.Fields(t.Text)
+	words := make([]Word, len(fields))
+	lowConfidence := t.Confidence > 0 && t.Confidence < LowConfidence
+
+	others := make([]map[string]int, 0, len(t.Alternatives))
+	for _, alt := range t.Alternatives {
+		if alt.Text == t.Text {
+			continue
+		}
+		others = append(others, countWords(alt.Text))
+	}
+
+	used := make([]map[string]int, len(others))
+	for i := range used {
+		used[i] = map[string]int{}
+	}
+
+	for i, field := range fields {
+		words[i].Text = field
+		key := normalizeWord(field)
+		uncertain := lowConfidence && len(others) == 0
+		for j, counts := range others {
+			// Repeated words must appear as often in the alternative.
+			used[j][key]++
+			if used[j][key] > counts[key] {
+				uncertain = true
+			}
+		}
+		words[i].Uncertain = uncertain
+	}
+	return words
+}
+
+// NeedsReview reports whether the user should choose or confirm the
+// transcript rather than having it sent straight away.
+func (t Transcription) NeedsReview() bool {
+	if len(t.Alternatives) > 1 {
+		return true
+	}
+	return t.Confidence > 0 && t.Confidence < LowConfidence
+}
+
+func countWords(text string) map[string]int {
+	counts := map[string]int{}
+	for _, field := range strings.Fields(text) {
+		counts[normalizeWord(field)]++
+	}
+	return counts
+}
+
+func normalizeWord(word string) string {
+	return strings.ToLower(strings.Trim(word, ".,!?;:\"'"))
+}
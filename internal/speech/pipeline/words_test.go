@@ -0,0 +1,52 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func uncertain(words []Word) []string {
+	var out []string
+	for _, w := range words {
+		if w.Uncertain {
+			out = append(out, w.Text)
+		}
+	}
+	return out
+}
+
+func TestWordsFlagsDisagreement(t *testing.T) {
+	tr := Transcription{
+		Text:       "recognize speech with a fan on",
+		Confidence: 0.9,
+		Alternatives: []Alternative{
+			{Text: "recognize speech with a fan on", Confidence: 0.9},
+			{Text: "wreck a nice beach with a fan on"},
+			{Text: "recognize speech with the fan on"},
+		},
+	}
+	assert.Equal(t, []string{"recognize", "speech", "a"}, uncertain(tr.Words()))
+	assert.True(t, tr.NeedsReview())
+}
+
+func TestWordsRepeatedWords(t *testing.T) {
+	tr := Transcription{
+		Text:         "no no no",
+		Confidence:   0.9,
+		Alternatives: []Alternative{{Text: "no no no", Confidence: 0.9}, {Text: "No, no."}},
+	}
+	assert.Equal(t, []string{"no"}, uncertain(tr.Words()))
+}
+
+func TestWordsSingleAlternative(t *testing.T) {
+	confident := Transcription{Text: "hello there", Confidence: 0.95,
+		Alternatives: []Alternative{{Text: "hello there", Confidence: 0.95}}}
+	assert.Empty(t, uncertain(confident.Words()))
+	assert.False(t, confident.NeedsReview())
+
+	doubtful := Transcription{Text: "hello there", Confidence: 0.4,
+		Alternatives: []Alternative{{Text: "hello there", Confidence: 0.4}}}
+	assert.Equal(t, []string{"hello", "there"}, uncertain(doubtful.Words()))
+	assert.True(t, doubtful.NeedsReview())
+}
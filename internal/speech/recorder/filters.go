@@ -0,0 +1,65 @@
+package recorder
+
+import (
+	"fmt"
+
+	"github.com/bz888/blab/internal/speech/pipeline"
+	"github.com/bz888/blab/internal/speech/sound"
+)
+
+// FilterConfig is the clean-up applied to microphone audio before VAD.
+type FilterConfig struct {
+	// Channel is the channel to keep, or -1 to downmix all of them.
+	Channel int
+	// HighPass is the high-pass cutoff in Hz, 0 to disable.
+	HighPass float64
+	// Denoise enables the noise gate.
+	Denoise bool
+}
+
+// filters turns interleaved microphone buffers into the 16 kHz mono stream
+// the VAD expects: channel selection or downmix, optional clean-up filters,
+// then resampling.
+type filters struct {
+	channels  int
+	channel   int
+	highPass  *sound.HighPass
+	gate      *sound.NoiseGate
+	resampler *sound.Resampler
+}
+
+func newFilters(cfg FilterConfig, channels, sampleRate int) (*filters, error) {
+	f := &filters{
+		channels:  channels,
+		channel:   cfg.Channel,
+		resampler: sound.NewResampler(sampleRate, pipeline.SampleRate),
+	}
+	if f.channel >= channels {
+		return nil, fmt.Errorf("microphone channel %d requested but the device has %d", f.channel, channels)
+	}
+	if cfg.HighPass > 0 {
+		f.highPass = sound.NewHighPass(cfg.HighPass, sampleRate)
+	}
+	if cfg.Denoise {
+		f.gate = sound.NewNoiseGate()
+	}
+	return f, nil
+}
+
+func (f *filters) process(in []int16) []int16 {
+	var mono []int16
+	if f.channel < 0 {
+		mono = sound.Downmix(in, f.channels)
+	} else {
+		// Range checked in newFilters.
+		mono, _ = sound.SelectChannel(in, f.channels, f.channel)
+	}
+
+	if f.highPass != nil {
+		f.highPass.Process(mono)
+	}
+	if f.gate != nil {
+		f.gate.Process(mono)
+	}
+	return f.resampler.ProcessInt16(mono)
+}
@@ -0,0 +1,301 @@
+// Package recorder runs live voice capture: it reads the microphone, cuts
+// the stream into utterances and transcribes them, and can be started and
+// stopped repeatedly over the life of the application.
+package recorder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/bz888/blab/internal/logger"
+	"github.com/bz888/blab/internal/speech/pipeline"
+	"github.com/bz888/blab/internal/speech/session"
+	"github.com/bz888/blab/internal/speech/vad"
+)
+
+var (
+	ErrRunning = errors.New("voice recorder is already running")
+	ErrStopped = errors.New("voice recorder stopped")
+)
+
+// Input is an open audio input device delivering interleaved 16-bit frames.
+type Input interface {
+	SampleRate() int
+	Channels() int
+	// Read blocks until the next buffer has been captured. The returned
+	// slice is only valid until the next call. A recorded input returns
+	// io.EOF once it is used up, which ends the recording after its last
+	// utterance.
+	Read() ([]int16, error)
+	Close() error
+}
+
+// Config wires a Recorder to its device and processing stages.
+type Config struct {
+	// Open starts capturing from the input device.
+	Open func() (Input, error)
+	// NewDetector creates the voice detector for one recording.
+	NewDetector func() (vad.VoiceDetector, error)
+	// NewPipeline wraps the detector with the encoder and transcriber.
+	NewPipeline func(detector pipeline.Detector) *pipeline.Pipeline
+	// Endpointer tunes utterance detection; FrameSize is taken from the detector.
+	Endpointer vad.EndpointerConfig
+	Filters    FilterConfig
+	// RecordDir, when set, saves each recording with the session package.
+	RecordDir string
+	Logger    *logger.Logger
+}
+
+// Result is one transcribed utterance, or the error that prevented it.
+type Result struct {
+	Transcription pipeline.Transcription
+	// Start and End are the utterance's offsets from the start of the
+	// recording; both are zero for an input failure.
+	Start, End time.Duration
+	Err        error
+}
+
+// Recorder owns the capture goroutines of one recording at a time.
+type Recorder struct {
+	cfg Config
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func New(cfg Config) *Recorder {
+	return &Recorder{cfg: cfg}
+}
+
+// Start opens the input and begins listening. Utterances are transcribed in
+// order and delivered on the returned channel, which is closed once the
+// recording has ended: ctx was cancelled, Stop was called, or the input
+// failed, in which case the failure is the last Result.
+func (r *Recorder) Start(ctx context.Context) (<-chan Result, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.done != nil {
+		select {
+		case <-r.done:
+			// Ended on its own after an input failure.
+		default:
+			return nil, ErrRunning
+		}
+	}
+
+	run, err := r.open()
+	if err != nil {
+		return nil, err
+	}
+
+	if r.cancel != nil {
+		// Release the context of the recording that ended on its own.
+		r.cancel()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	results := make(chan Result)
+	done := make(chan struct{})
+	r.cancel, r.done = cancel, done
+
+	go func() {
+		defer close(done)
+		defer close(results)
+		defer run.close()
+		run.loop(ctx, results)
+	}()
+	return results, nil
+}
+
+// Stop ends the current recording and waits until the device is closed and
+// every goroutine has exited. It is a no-op when not running.
+func (r *Recorder) Stop() {
+	r.mu.Lock()
+	cancel, done := r.cancel, r.done
+	r.cancel, r.done = nil, nil
+	r.mu.Unlock()
+
+	if done == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// Run records until the first utterance has been transcribed, then stops.
+func (r *Recorder) Run(ctx context.Context) (pipeline.Transcription, error) {
+	results, err := r.Start(ctx)
+	if err != nil {
+		return pipeline.Transcription{}, err
+	}
+	defer r.Stop()
+
+	result, ok := <-results
+	if !ok {
+		if err := ctx.Err(); err != nil {
+			return pipeline.Transcription{}, err
+		}
+		return pipeline.Transcription{}, ErrStopped
+	}
+	return result.Transcription, result.Err
+}
+
+// recording is the state of one Start.
+type recording struct {
+	log        *logger.Logger
+	input      Input
+	detector   vad.VoiceDetector
+	pipeline   *pipeline.Pipeline
+	endpointer *vad.Endpointer
+	filters    *filters
+	session    *session.Session
+}
+
+func (r *Recorder) open() (_ *recording, err error) {
+	run := &recording{log: r.cfg.Logger}
+	defer func() {
+		if err != nil {
+			run.close()
+		}
+	}()
+
+	if run.detector, err = r.cfg.NewDetector(); err != nil {
+		return nil, err
+	}
+	run.pipeline = r.cfg.NewPipeline(run.detector)
+
+	endpointerConfig := r.cfg.Endpointer
+	endpointerConfig.FrameSize = run.detector.FrameSize()
+	if run.endpointer, err = vad.NewEndpointer(run.detector, endpointerConfig); err != nil {
+		return nil, err
+	}
+
+	if run.input, err = r.cfg.Open(); err != nil {
+		return nil, fmt.Errorf("opening audio input: %w", err)
+	}
+	if run.filters, err = newFilters(r.cfg.Filters, run.input.Channels(), run.input.SampleRate()); err != nil {
+		return nil, err
+	}
+
+	if r.cfg.RecordDir != "" {
+		if run.session, err = session.Create(r.cfg.RecordDir, run.input.SampleRate(), run.input.Channels()); err != nil {
+			return nil, err
+		}
+		run.log.Info("Recording session", "dir", run.session.Dir())
+	}
+	return run, nil
+}
+
+func (run *recording) close() {
+	if run.input != nil {
+		if err := run.input.Close(); err != nil {
+			run.log.Error("Failed to close audio input", "err", err)
+		}
+	}
+	if run.session != nil {
+		if err := run.session.Close(); err != nil {
+			run.log.Error("Failed to close session", "err", err)
+		}
+	}
+	if run.detector != nil {
+		if err := run.detector.Close(); err != nil {
+			run.log.Error("Failed to close voice detector", "err", err)
+		}
+	}
+}
+
+// loop captures until ctx is done, handing utterances to a transcribing
+// goroutine so slow requests do not stall the microphone.
+func (run *recording) loop(ctx context.Context, results chan<- Result) {
+	utterances := make(chan vad.Utterance, 10)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		run.transcribe(ctx, utterances, results)
+	}()
+
+	err := run.capture(ctx, utterances)
+	close(utterances)
+	if err != nil {
+		send(ctx, results, Result{Err: err})
+	}
+	wg.Wait()
+}
+
+func (run *recording) capture(ctx context.Context, utterances chan<- vad.Utterance) error {
+	deliver := func(found []vad.Utterance) bool {
+		for _, u := range found {
+			run.log.Info("Utterance", "start", u.Start, "end", u.End, "noiseFloor", run.endpointer.NoiseFloor())
+			select {
+			case utterances <- u:
+			case <-ctx.Done():
+				return false
+			}
+		}
+		return true
+	}
+
+	for ctx.Err() == nil {
+		in, err := run.input.Read()
+		if errors.Is(err, io.EOF) {
+			if u := run.endpointer.Flush(); u != nil {
+				deliver([]vad.Utterance{*u})
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading audio input: %w", err)
+		}
+
+		if run.session != nil {
+			if err := run.session.WriteCapture(in); err != nil {
+				run.log.Error("Failed to record capture", "err", err)
+			}
+		}
+
+		// Silero accept audio with SampleRate = 16000.
+		found, err := run.endpointer.Write(run.filters.process(in))
+		if err != nil {
+			return fmt.Errorf("detect voice: %w", err)
+		}
+
+		if !deliver(found) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (run *recording) transcribe(ctx context.Context, utterances <-chan vad.Utterance, results chan<- Result) {
+	for u := range utterances {
+		if ctx.Err() != nil {
+			continue
+		}
+		start := time.Now()
+		t, err := run.pipeline.TranscribeSegment(ctx, u.Samples)
+		if run.session != nil {
+			if err := run.session.SaveSegment(u.Start, u.End, time.Since(start), t, err); err != nil {
+				run.log.Error("Failed to record segment", "err", err)
+			}
+		}
+		if err != nil {
+			err = fmt.Errorf("transcribe: %w", err)
+		} else {
+			run.log.Info("Transcribed", "elapsed", time.Since(start), "confidence", t.Confidence, "text", t.Text)
+		}
+		send(ctx, results, Result{Transcription: t, Start: u.Start, End: u.End, Err: err})
+	}
+}
+
+// send delivers a result unless the recording is being stopped.
+func send(ctx context.Context, results chan<- Result, result Result) {
+	select {
+	case results <- result:
+	case <-ctx.Done():
+	}
+}
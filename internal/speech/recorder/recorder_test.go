@@ -0,0 +1,244 @@
+package recorder
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bz888/blab/internal/logger"
+	"github.com/bz888/blab/internal/speech/pipeline"
+	"github.com/bz888/blab/internal/speech/vad"
+	"github.com/go-audio/audio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	logger.InitLogger(logger.Options{})
+}
+
+const (
+	inputRate     = 48000
+	inputChannels = 2
+	bufferFrames  = 4096
+)
+
+// fakeInput plays a stereo 48 kHz signal: a second of silence, then a
+// second of tone, then silence forever.
+type fakeInput struct {
+	frame  int
+	speech bool
+	fail   int // fail on this read, counting from 1
+	eof    int // end with io.EOF on this read, as a recording does
+	reads  int
+	closed atomic.Bool
+	buffer []int16
+}
+
+func (in *fakeInput) SampleRate() int { return inputRate }
+func (in *fakeInput) Channels() int   { return inputChannels }
+
+func (in *fakeInput) Read() ([]int16, error) {
+	in.reads++
+	if in.reads == in.fail {
+		return nil, errors.New("device unplugged")
+	}
+	if in.reads == in.eof {
+		return nil, io.EOF
+	}
+	// Pace reads a little so idle recordings do not spin.
+	time.Sleep(time.Millisecond)
+
+	if in.buffer == nil {
+		in.buffer = make([]int16, bufferFrames*inputChannels)
+	}
+	for i := 0; i < bufferFrames; i++ {
+		var v int16
+		if in.speech && in.frame >= inputRate && in.frame < 2*inputRate {
+			v = int16(8000 * math.Sin(2*math.Pi*220*float64(in.frame)/inputRate))
+		}
+		in.buffer[2*i], in.buffer[2*i+1] = v, v
+		in.frame++
+	}
+	return in.buffer, nil
+}
+
+func (in *fakeInput) Close() error {
+	in.closed.Store(true)
+	return nil
+}
+
+// levelDetector treats loud frames as speech.
+type levelDetector struct {
+	closed atomic.Bool
+}
+
+func (d *levelDetector) IsSpeech(frame []int16) (bool, error) {
+	var sum float64
+	for _, s := range frame {
+		sum += float64(s) * float64(s)
+	}
+	return math.Sqrt(sum/float64(len(frame))) > 1000, nil
+}
+
+func (d *levelDetector) DetectVoice(*audio.IntBuffer) (bool, error) { return true, nil }
+func (d *levelDetector) FrameSize() int                             { return 512 }
+func (d *levelDetector) Close() error {
+	d.closed.Store(true)
+	return nil
+}
+
+type fixture struct {
+	inputs    []*fakeInput
+	detectors []*levelDetector
+	recorder  *Recorder
+}
+
+func newFixture(newInput func() *fakeInput) *fixture {
+	f := &fixture{}
+	f.recorder = New(Config{
+		Open: func() (Input, error) {
+			in := newInput()
+			f.inputs = append(f.inputs, in)
+			return in, nil
+		},
+		NewDetector: func() (vad.VoiceDetector, error) {
+			d := &levelDetector{}
+			f.detectors = append(f.detectors, d)
+			return d, nil
+		},
+		NewPipeline: func(detector pipeline.Detector) *pipeline.Pipeline {
+			encode := func(wav []byte) ([]byte, error) { return wav, nil }
+			transcribe := pipeline.TranscriberFunc(func(context.Context, []byte) ([]pipeline.Alternative, error) {
+				return []pipeline.Alternative{{Text: "hello", Confidence: 0.9}}, nil
+			})
+			return pipeline.New(detector, encode, transcribe)
+		},
+		Endpointer: vad.DefaultEndpointerConfig(),
+		Filters:    FilterConfig{Channel: -1, HighPass: 80},
+		Logger:     logger.NewLogger("recorder test"),
+	})
+	return f
+}
+
+// assertClosed checks every device and detector opened was released.
+func (f *fixture) assertClosed(t *testing.T) {
+	t.Helper()
+	for i, in := range f.inputs {
+		assert.True(t, in.closed.Load(), "input %d closed", i)
+	}
+	for i, d := range f.detectors {
+		assert.True(t, d.closed.Load(), "detector %d closed", i)
+	}
+}
+
+// waitForGoroutines waits for the goroutine count to settle back to n.
+func waitForGoroutines(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > n && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), n, "goroutines leaked")
+}
+
+func TestRecorderRepeatedRunsDoNotLeak(t *testing.T) {
+	f := newFixture(func() *fakeInput { return &fakeInput{speech: true} })
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 10; i++ {
+		transcription, err := f.recorder.Run(context.Background())
+		require.NoError(t, err, "run %d", i)
+		assert.Equal(t, "hello", transcription.Text)
+	}
+
+	assert.Len(t, f.inputs, 10)
+	f.assertClosed(t)
+	waitForGoroutines(t, before)
+}
+
+func TestRecorderStop(t *testing.T) {
+	f := newFixture(func() *fakeInput { return &fakeInput{} })
+	before := runtime.NumGoroutine()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		f.recorder.Stop()
+	}()
+	_, err := f.recorder.Run(context.Background())
+	assert.ErrorIs(t, err, ErrStopped)
+
+	f.assertClosed(t)
+	waitForGoroutines(t, before)
+}
+
+func TestRecorderContextCancel(t *testing.T) {
+	f := newFixture(func() *fakeInput { return &fakeInput{} })
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := f.recorder.Run(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	f.assertClosed(t)
+	waitForGoroutines(t, before)
+}
+
+func TestRecorderInputError(t *testing.T) {
+	f := newFixture(func() *fakeInput { return &fakeInput{fail: 3} })
+
+	_, err := f.recorder.Run(context.Background())
+	assert.ErrorContains(t, err, "device unplugged")
+	f.assertClosed(t)
+
+	// The recorder can be used again after a failure.
+	_, err = f.recorder.Run(context.Background())
+	assert.ErrorContains(t, err, "device unplugged")
+}
+
+func TestRecorderStartWhileRunning(t *testing.T) {
+	f := newFixture(func() *fakeInput { return &fakeInput{} })
+
+	_, err := f.recorder.Start(context.Background())
+	require.NoError(t, err)
+	_, err = f.recorder.Start(context.Background())
+	assert.ErrorIs(t, err, ErrRunning)
+
+	f.recorder.Stop()
+	f.recorder.Stop()
+	f.assertClosed(t)
+}
+
+func TestRecorderBadChannel(t *testing.T) {
+	f := newFixture(func() *fakeInput { return &fakeInput{} })
+	f.recorder.cfg.Filters.Channel = 2
+
+	_, err := f.recorder.Start(context.Background())
+	assert.ErrorContains(t, err, "channel 2")
+	f.assertClosed(t)
+}
+
+func TestRecorderRecordedInput(t *testing.T) {
+	// The tone is still playing when the recording ends.
+	f := newFixture(func() *fakeInput { return &fakeInput{speech: true, eof: 20} })
+
+	results, err := f.recorder.Start(context.Background())
+	require.NoError(t, err)
+	var all []Result
+	for result := range results {
+		all = append(all, result)
+	}
+
+	require.Len(t, all, 1)
+	require.NoError(t, all[0].Err)
+	assert.Equal(t, "hello", all[0].Transcription.Text)
+	assert.InDelta(t, 0.7, all[0].Start.Seconds(), 0.1, "pre-roll before the tone")
+	assert.InDelta(t, 1.7, all[0].End.Seconds(), 0.1, "cut off at the end of the input")
+	f.assertClosed(t)
+}
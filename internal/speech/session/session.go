@@ -0,0 +1,272 @@
+// Package session saves what the microphone loop captured and sent, so a
+// voice session can be inspected or replayed through the pipeline later.
+//
+// A session directory holds:
+//
+//	session.json  capture format and start time
+//	capture.pcm   raw interleaved little-endian 16-bit samples from the device
+//	001.wav       first utterance after VAD, 16 kHz mono
+//	001.flac      the audio sent to the transcriber
+//	001.json      transcript, confidence, alternatives and any error
+package session
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bz888/blab/internal/speech/pipeline"
+)
+
+const (
+	infoFile    = "session.json"
+	captureFile = "capture.pcm"
+)
+
+// Info describes the raw capture of a session.
+type Info struct {
+	Started    time.Time `json:"started"`
+	SampleRate int       `json:"sampleRate"`
+	Channels   int       `json:"channels"`
+}
+
+// Record is the outcome of transcribing one segment. Start and End are in
+// seconds from the beginning of the capture.
+type Record struct {
+	Index      int     `json:"index"`
+	Start      float64 `json:"start"`
+	End        float64 `json:"end"`
+	Text       string  `json:"text"`
+	Confidence float64 `json:"confidence"`
+	// Alternatives are all the transcriber's hypotheses, best first.
+	Alternatives []pipeline.Alternative `json:"alternatives,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+	Elapsed      float64                `json:"elapsed"`
+}
+
+// Session writes one recorded voice session. It is safe for the capture
+// loop and the transcriber to use concurrently.
+type Session struct {
+	dir string
+
+	mu       sync.Mutex
+	capture  *os.File
+	segments int
+}
+
+// Create makes a new timestamped session directory under root. Sessions
+// started in the same second get a numbered suffix.
+func Create(root string, sampleRate, channels int) (*Session, error) {
+	info := Info{Started: time.Now(), SampleRate: sampleRate, Channels: channels}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("creating session directory: %w", err)
+	}
+	base := filepath.Join(root, info.Started.Format("20060102-150405"))
+	dir := base
+	for i := 1; ; i++ {
+		err := os.Mkdir(dir, 0o755)
+		if errors.Is(err, fs.ErrExist) {
+			dir = fmt.Sprintf("%s_%d", base, i)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("creating session directory: %w", err)
+		}
+		break
+	}
+	if err := writeJSON(filepath.Join(dir, infoFile), info); err != nil {
+		return nil, err
+	}
+
+	capture, err := os.Create(filepath.Join(dir, captureFile))
+	if err != nil {
+		return nil, fmt.Errorf("creating capture file: %w", err)
+	}
+	return &Session{dir: dir, capture: capture}, nil
+}
+
+// Dir returns the session directory.
+func (s *Session) Dir() string {
+	return s.dir
+}
+
+// WriteCapture appends raw device samples to the capture file.
+func (s *Session) WriteCapture(samples []int16) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return binary.Write(s.capture, binary.LittleEndian, samples)
+}
+
+// SaveSegment stores the artifacts of one transcribed utterance, including
+// the partial ones left by a failed transcription.
+func (s *Session) SaveSegment(start, end, elapsed time.Duration, t pipeline.Transcription, transcribeErr error) error {
+	s.mu.Lock()
+	s.segments++
+	index := s.segments
+	s.mu.Unlock()
+
+	base := filepath.Join(s.dir, fmt.Sprintf("%03d", index))
+	if len(t.WAV) > 0 {
+		if err := os.WriteFile(base+".wav", t.WAV, 0o644); err != nil {
+			return err
+		}
+	}
+	if len(t.FLAC) > 0 {
+		if err := os.WriteFile(base+".flac", t.FLAC, 0o644); err != nil {
+			return err
+		}
+	}
+
+	record := Record{
+		Index:        index,
+		Start:        start.Seconds(),
+		End:          end.Seconds(),
+		Text:         t.Text,
+		Confidence:   t.Confidence,
+		Alternatives: t.Alternatives,
+		Elapsed:      elapsed.Seconds(),
+	}
+	if transcribeErr != nil {
+		record.Error = transcribeErr.Error()
+	}
+	return writeJSON(base+".json", record)
+}
+
+// Close finishes the capture file.
+func (s *Session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.capture.Close()
+}
+
+// Segment is a saved utterance found by Load.
+type Segment struct {
+	Record
+	// WAV is the path of the utterance audio, empty if it was not saved.
+	WAV string
+}
+
+// Load reads the capture format and saved segments of a session, in order.
+func Load(dir string) (Info, []Segment, error) {
+	var info Info
+	if err := readJSON(filepath.Join(dir, infoFile), &info); err != nil {
+		return info, nil, err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "[0-9][0-9][0-9]*.json"))
+	if err != nil {
+		return info, nil, err
+	}
+	sort.Strings(paths)
+
+	segments := make([]Segment, 0, len(paths))
+	for _, path := range paths {
+		var segment Segment
+		if err := readJSON(path, &segment.Record); err != nil {
+			return info, nil, err
+		}
+		wavPath := strings.TrimSuffix(path, ".json") + ".wav"
+		if _, err := os.Stat(wavPath); err == nil {
+			segment.WAV = wavPath
+		}
+		segments = append(segments, segment)
+	}
+	return info, segments, nil
+}
+
+// CapturePath returns the raw capture file of a session directory.
+func CapturePath(dir string) string {
+	return filepath.Join(dir, captureFile)
+}
+
+// captureFrames is the number of frames a Capture returns per Read.
+const captureFrames = 1024
+
+// Capture plays the raw capture of a session back as a recorder input, so
+// it can be split into utterances again with other settings.
+type Capture struct {
+	info   Info
+	file   *os.File
+	reader *bufio.Reader
+	data   []byte
+	buffer []int16
+}
+
+// OpenCapture opens the raw capture of the session in dir.
+func OpenCapture(dir string) (*Capture, error) {
+	var info Info
+	if err := readJSON(filepath.Join(dir, infoFile), &info); err != nil {
+		return nil, err
+	}
+	if info.SampleRate <= 0 || info.Channels <= 0 {
+		return nil, fmt.Errorf("%s: invalid capture format %d Hz, %d channels", dir, info.SampleRate, info.Channels)
+	}
+
+	file, err := os.Open(CapturePath(dir))
+	if err != nil {
+		return nil, err
+	}
+	return &Capture{
+		info:   info,
+		file:   file,
+		reader: bufio.NewReader(file),
+		data:   make([]byte, captureFrames*info.Channels*2),
+		buffer: make([]int16, captureFrames*info.Channels),
+	}, nil
+}
+
+func (c *Capture) SampleRate() int { return c.info.SampleRate }
+func (c *Capture) Channels() int   { return c.info.Channels }
+
+// Read returns the next interleaved samples, and io.EOF after the last
+// whole frame. The returned slice is only valid until the next call.
+func (c *Capture) Read() ([]int16, error) {
+	n, err := io.ReadFull(c.reader, c.data)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	// A capture cut short, as by a crash, may end mid-frame.
+	n -= n % (c.info.Channels * 2)
+	if n == 0 {
+		return nil, io.EOF
+	}
+
+	samples := c.buffer[:n/2]
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(c.data[2*i:]))
+	}
+	return samples, nil
+}
+
+func (c *Capture) Close() error {
+	return c.file.Close()
+}
+
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package session
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bz888/blab/internal/speech/pipeline"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionRoundTrip(t *testing.T) {
+	s, err := Create(t.TempDir(), 48000, 2)
+	require.NoError(t, err)
+
+	require.NoError(t, s.WriteCapture([]int16{1, -1, 2, -2}))
+	require.NoError(t, s.WriteCapture([]int16{3, -3}))
+
+	require.NoError(t, s.SaveSegment(time.Second, 3*time.Second, 400*time.Millisecond,
+		pipeline.Transcription{WAV: []byte("wav"), FLAC: []byte("flac"), Text: "hello", Confidence: 0.8}, nil))
+	require.NoError(t, s.SaveSegment(4*time.Second, 5*time.Second, time.Second,
+		pipeline.Transcription{WAV: []byte("wav")}, errors.New("backend down")))
+	require.NoError(t, s.SaveSegment(6*time.Second, 7*time.Second, 0, pipeline.Transcription{}, errors.New("encode failed")))
+	require.NoError(t, s.Close())
+
+	info, segments, err := Load(s.Dir())
+	require.NoError(t, err)
+	assert.Equal(t, 48000, info.SampleRate)
+	assert.Equal(t, 2, info.Channels)
+
+	require.Len(t, segments, 3)
+	assert.Equal(t, 1, segments[0].Index)
+	assert.Equal(t, "hello", segments[0].Text)
+	assert.Equal(t, 0.8, segments[0].Confidence)
+	assert.Equal(t, 1.0, segments[0].Start)
+	assert.Equal(t, 3.0, segments[0].End)
+	assert.FileExists(t, segments[0].WAV)
+
+	assert.Equal(t, "backend down", segments[1].Error)
+	assert.NoFileExists(t, s.Dir()+"/002.flac")
+	assert.Empty(t, segments[2].WAV)
+
+	capture, err := os.ReadFile(CapturePath(s.Dir()))
+	require.NoError(t, err)
+	samples := make([]int16, len(capture)/2)
+	require.NoError(t, binary.Read(bytes.NewReader(capture), binary.LittleEndian, samples))
+	assert.Equal(t, []int16{1, -1, 2, -2, 3, -3}, samples)
+}
+
+func TestCreateSameSecond(t *testing.T) {
+	root := t.TempDir()
+	dirs := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		s, err := Create(root, 16000, 1)
+		require.NoError(t, err)
+		defer s.Close()
+		dirs[s.Dir()] = true
+	}
+	assert.Len(t, dirs, 3, "each session gets its own directory")
+}
+
+func TestOpenCapture(t *testing.T) {
+	s, err := Create(t.TempDir(), 16000, 2)
+	require.NoError(t, err)
+	samples := make([]int16, 2*captureFrames+6)
+	for i := range samples {
+		samples[i] = int16(i - 1000)
+	}
+	require.NoError(t, s.WriteCapture(samples))
+	// A capture cut short mid-frame drops the partial frame.
+	_, err = s.capture.Write([]byte{1, 2})
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	capture, err := OpenCapture(s.Dir())
+	require.NoError(t, err)
+	defer capture.Close()
+	assert.Equal(t, 16000, capture.SampleRate())
+	assert.Equal(t, 2, capture.Channels())
+
+	var got []int16
+	for {
+		buffer, err := capture.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		got = append(got, buffer...)
+	}
+	assert.Equal(t, samples, got)
+}
@@ -0,0 +1,35 @@
+package sound
+
+import (
+	"fmt"
+)
+
+// Downmix averages an interleaved buffer down to mono.
+func Downmix(input []int16, channels int) []int16 {
+	if channels == 1 {
+		return append([]int16(nil), input...)
+	}
+	frames := len(input) / channels
+	output := make([]int16, frames)
+	for i := range output {
+		var sum int
+		for ch := 0; ch < channels; ch++ {
+			sum += int(input[i*channels+ch])
+		}
+		output[i] = int16(sum / channels)
+	}
+	return output
+}
+
+// SelectChannel extracts a single channel from an interleaved buffer.
+func SelectChannel(input []int16, channels, channel int) ([]int16, error) {
+	if channel < 0 || channel >= channels {
+		return nil, fmt.Errorf("channel %d out of range for %d channels", channel, channels)
+	}
+	frames := len(input) / channels
+	output := make([]int16, frames)
+	for i := range output {
+		output[i] = input[i*channels+channel]
+	}
+	return output, nil
+}
@@ -0,0 +1,27 @@
+package sound
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownmix(t *testing.T) {
+	assert.Equal(t, []int16{5, -2}, Downmix([]int16{4, 6, -1, -3}, 2))
+
+	mono := []int16{1, 2, 3}
+	out := Downmix(mono, 1)
+	assert.Equal(t, mono, out)
+	out[0] = 9
+	assert.Equal(t, int16(1), mono[0], "mono input is copied")
+}
+
+func TestSelectChannel(t *testing.T) {
+	out, err := SelectChannel([]int16{1, 10, 100, 2, 20, 200}, 3, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int16{100, 200}, out)
+
+	_, err = SelectChannel([]int16{1, 2}, 2, 2)
+	assert.Error(t, err)
+}
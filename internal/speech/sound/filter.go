@@ -0,0 +1,105 @@
+package sound
+
+import (
+	"math"
+)
+
+// HighPass is a second-order Butterworth high-pass filter. It removes DC
+// offset and low rumble (desk knocks, fans, mains hum) that would otherwise
+// raise the RMS of every frame. State is kept between calls.
+type HighPass struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func NewHighPass(cutoff float64, sampleRate int) *HighPass {
+	// RBJ audio EQ cookbook, Q = 1/sqrt(2).
+	w0 := 2 * math.Pi * cutoff / float64(sampleRate)
+	alpha := math.Sin(w0) / math.Sqrt2
+	cos := math.Cos(w0)
+	a0 := 1 + alpha
+
+	return &HighPass{
+		b0: (1 + cos) / 2 / a0,
+		b1: -(1 + cos) / a0,
+		b2: (1 + cos) / 2 / a0,
+		a1: -2 * cos / a0,
+		a2: (1 - alpha) / a0,
+	}
+}
+
+// Process filters samples in place.
+func (h *HighPass) Process(samples []int16) {
+	for i, s := range samples {
+		x := float64(s)
+		y := h.b0*x + h.b1*h.x1 + h.b2*h.x2 - h.a1*h.y1 - h.a2*h.y2
+		h.x2, h.x1 = h.x1, x
+		h.y2, h.y1 = h.y1, y
+		samples[i] = clip(y)
+	}
+}
+
+const (
+	// gateThreshold is how far above the noise floor a block must be to open.
+	gateThreshold = 2.0
+	// gateReduction is the gain applied to blocks judged to be noise (-20 dB).
+	gateReduction = 0.1
+	// gateFloorRise lets the floor creep up per block so it follows rising
+	// background noise; it falls immediately to quieter blocks.
+	gateFloorRise = 1.02
+)
+
+// NoiseGate is a simple downward expander: blocks close to the tracked noise
+// floor are attenuated, louder blocks pass unchanged. Gain changes are ramped
+// across each block to avoid clicks.
+type NoiseGate struct {
+	floor float64
+	gain  float64
+}
+
+func NewNoiseGate() *NoiseGate {
+	return &NoiseGate{gain: 1}
+}
+
+// Process attenuates samples in place when they look like background noise.
+func (g *NoiseGate) Process(samples []int16) {
+	if len(samples) == 0 {
+		return
+	}
+
+	var sumSquares float64
+	for _, s := range samples {
+		sumSquares += float64(s) * float64(s)
+	}
+	level := math.Sqrt(sumSquares / float64(len(samples)))
+
+	switch {
+	case g.floor == 0 || level < g.floor:
+		g.floor = level
+	default:
+		g.floor *= gateFloorRise
+	}
+
+	target := 1.0
+	if level < g.floor*gateThreshold {
+		target = gateReduction
+	}
+
+	step := (target - g.gain) / float64(len(samples))
+	for i, s := range samples {
+		g.gain += step
+		samples[i] = clip(float64(s) * g.gain)
+	}
+	g.gain = target
+}
+
+func clip(v float64) int16 {
+	v = math.Round(v)
+	switch {
+	case v > math.MaxInt16:
+		return math.MaxInt16
+	case v < math.MinInt16:
+		return math.MinInt16
+	}
+	return int16(v)
+}
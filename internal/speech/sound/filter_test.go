@@ -0,0 +1,70 @@
+package sound
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func toneInt16(freq float64, rate, n int, amplitude, offset float64) []int16 {
+	out := make([]int16, n)
+	for i := range out {
+		out[i] = int16(offset + amplitude*math.Sin(2*math.Pi*freq*float64(i)/float64(rate)))
+	}
+	return out
+}
+
+func TestHighPass(t *testing.T) {
+	const rate = 48000
+
+	// Filter in chunks to check state carries across calls.
+	filter := func(samples []int16) []float32 {
+		h := NewHighPass(80, rate)
+		for start := 0; start < len(samples); start += 512 {
+			h.Process(samples[start:min(start+512, len(samples))])
+		}
+		return toFloat32(samples)
+	}
+
+	speech := filter(toneInt16(1000, rate, rate, 10000, 3000))
+	assert.InDelta(t, 10000, amplitudeAt(speech, 1000, rate), 100, "1 kHz passes")
+	assert.InDelta(t, 0, amplitudeAt(speech, 0, rate)/2, 50, "DC offset removed")
+
+	rumble := filter(toneInt16(20, rate, rate, 10000, 0))
+	assert.Less(t, decibels(amplitudeAt(rumble, 20, rate)/10000), -20.0, "20 Hz rumble attenuated")
+}
+
+func TestNoiseGate(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	noise := func() []int16 {
+		out := make([]int16, 1024)
+		for i := range out {
+			out[i] = int16((rng.Float64()*2 - 1) * 300)
+		}
+		return out
+	}
+	level := func(samples []int16) float64 {
+		return rmsFloat32(toFloat32(samples))
+	}
+
+	g := NewNoiseGate()
+	var quiet []int16
+	for i := 0; i < 20; i++ {
+		quiet = noise()
+		before := level(quiet)
+		g.Process(quiet)
+		if i > 2 {
+			assert.Less(t, level(quiet), before*gateReduction*1.1, "block %d of steady noise is attenuated", i)
+		}
+	}
+
+	loud := toneInt16(300, 16000, 1024, 8000, 0)
+	g.Process(loud)
+	for i := 0; i < 3; i++ {
+		loud = toneInt16(300, 16000, 1024, 8000, 0)
+		g.Process(loud)
+		assert.InDelta(t, 8000/math.Sqrt2, level(loud), 200, "speech passes unchanged")
+	}
+}
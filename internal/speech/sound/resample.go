@@ -1,38 +1,157 @@
 package sound
 
-func ResampleInt16(input []int16, inputRate, outputRate int) []int16 {
-	// Calculate the resampling ratio
-	ratio := float64(inputRate) / float64(outputRate)
+import (
+	"math"
+)
+
+const (
+	// zeroCrossings is the number of sinc lobes kept on each side of the
+	// filter centre. More lobes give a sharper cutoff at a higher cost.
+	zeroCrossings = 16
+	// rolloff places the cutoff slightly below the output Nyquist frequency
+	// so the transition band does not fold back into the passband.
+	rolloff = 0.92
+	// kaiserBeta trades main-lobe width for stopband attenuation (~80 dB).
+	kaiserBeta = 8.0
+)
 
-	// Calculate the length of the resampled output
-	outputLength := int(float64(len(input)) / ratio)
+// Resampler converts a stream between two sample rates using a polyphase
+// windowed-sinc low-pass filter. It keeps the tail of each chunk, so
+// consecutive calls produce the same output as resampling everything at once
+// and chunk boundaries don't click.
+type Resampler struct {
+	up, down int         // output = input * up / down, reduced by their GCD
+	taps     int         // coefficients per phase
+	phases   [][]float32 // phases[p] filters an output at input time q + p/up
+
+	history []float32 // input not yet fully consumed, starting at input index base
+	base    int
+	q, p    int // input position of the next output sample: q + p/up
+	written int // total input samples received
+	emitted int // total output samples produced
+}
 
-	// Allocate a slice for the resampled output
-	output := make([]int16, outputLength)
+func NewResampler(inputRate, outputRate int) *Resampler {
+	g := gcd(inputRate, outputRate)
+	up, down := outputRate/g, inputRate/g
 
-	// Perform linear interpolation for resampling
-	for i := 0; i < outputLength-1; i++ {
-		// Calculate the corresponding position in the input
-		pos := float64(i) * ratio
+	// Cutoff as a fraction of the input Nyquist frequency.
+	cutoff := rolloff * math.Min(1, float64(up)/float64(down))
+	halfWidth := zeroCrossings / cutoff
+	n := int(math.Ceil(halfWidth))
 
-		// Calculate the indices of the surrounding input samples
-		indexBefore := int(pos)
-		indexAfter := indexBefore + 1
-		if indexAfter >= len(input) {
-			indexAfter = len(input) - 1
+	r := &Resampler{
+		up:     up,
+		down:   down,
+		taps:   2 * n,
+		phases: make([][]float32, up),
+	}
+
+	for p := range r.phases {
+		coeffs := make([]float32, r.taps)
+		var sum float64
+		values := make([]float64, r.taps)
+		for j := range values {
+			// Distance between the output instant and input sample q-n+1+j.
+			x := float64(p)/float64(up) + float64(n-1-j)
+			values[j] = cutoff * sinc(cutoff*x) * kaiser(x/halfWidth)
+			sum += values[j]
+		}
+		// Normalise each phase to unity gain at DC.
+		for j, v := range values {
+			coeffs[j] = float32(v / sum)
 		}
+		r.phases[p] = coeffs
+	}
+
+	r.Reset()
+	return r
+}
 
-		// Calculate the fractional part of the position
-		frac := pos - float64(indexBefore)
+// Reset discards buffered input so the resampler can start a new stream.
+func (r *Resampler) Reset() {
+	// Samples before the stream starts are silence.
+	n := r.taps / 2
+	r.history = make([]float32, n-1, n-1+4096)
+	r.base = -(n - 1)
+	r.q, r.p = 0, 0
+	r.written, r.emitted = 0, 0
+}
 
-		// Linearly interpolate between the two surrounding input samples
-		output[i] = int16((1-frac)*float64(input[indexBefore]) + frac*float64(input[indexAfter]))
+// ProcessFloat32 resamples the next chunk of the stream.
+func (r *Resampler) ProcessFloat32(input []float32) []float32 {
+	r.history = append(r.history, input...)
+	r.written += len(input)
+	return r.drain(nil)
+}
+
+// ProcessInt16 resamples the next chunk of 16-bit samples.
+func (r *Resampler) ProcessInt16(input []int16) []int16 {
+	return toInt16(r.ProcessFloat32(toFloat32(input)))
+}
+
+// FlushFloat32 emits the samples still held back waiting for future input,
+// treating the stream as ended. The resampler is reset afterwards.
+func (r *Resampler) FlushFloat32() []float32 {
+	// Total output for the whole stream, rounded up.
+	total := (r.written*r.up + r.down - 1) / r.down
+	r.history = append(r.history, make([]float32, r.taps)...)
+	out := r.drain(nil)
+	if extra := r.emitted - total; extra > 0 {
+		out = out[:len(out)-extra]
 	}
+	r.Reset()
+	return out
+}
 
-	// Handle the last sample explicitly to avoid index out of range
-	output[outputLength-1] = input[len(input)-1]
+// FlushInt16 is FlushFloat32 for 16-bit samples.
+func (r *Resampler) FlushInt16() []int16 {
+	return toInt16(r.FlushFloat32())
+}
 
-	return output
+// drain produces every output whose filter window is fully buffered.
+func (r *Resampler) drain(out []float32) []float32 {
+	n := r.taps / 2
+	for {
+		first := r.q - n + 1 - r.base
+		if first+r.taps > len(r.history) {
+			break
+		}
+
+		var acc float32
+		window := r.history[first : first+r.taps]
+		for j, c := range r.phases[r.p] {
+			acc += c * window[j]
+		}
+		out = append(out, acc)
+		r.emitted++
+
+		r.p += r.down
+		r.q += r.p / r.up
+		r.p %= r.up
+	}
+
+	// Drop input that no future output can reach.
+	if drop := r.q - n + 1 - r.base; drop > 0 {
+		if drop > len(r.history) {
+			drop = len(r.history)
+		}
+		r.history = append(r.history[:0], r.history[drop:]...)
+		r.base += drop
+	}
+	return out
+}
+
+// ResampleFloat32 resamples a complete signal.
+func ResampleFloat32(input []float32, inputRate, outputRate int) []float32 {
+	r := NewResampler(inputRate, outputRate)
+	out := r.ProcessFloat32(input)
+	return append(out, r.FlushFloat32()...)
+}
+
+// ResampleInt16 resamples a complete 16-bit signal.
+func ResampleInt16(input []int16, inputRate, outputRate int) []int16 {
+	return toInt16(ResampleFloat32(toFloat32(input), inputRate, outputRate))
 }
 
 func ConvertInt16ToInt(input []int16) []int {
@@ -42,3 +161,53 @@ func ConvertInt16ToInt(input []int16) []int {
 	}
 	return output // Return the converted slice
 }
+
+func toFloat32(input []int16) []float32 {
+	output := make([]float32, len(input))
+	for i, value := range input {
+		output[i] = float32(value)
+	}
+	return output
+}
+
+// toInt16 rounds and clips filtered samples back into the int16 range; the
+// filter can overshoot on full-scale input.
+func toInt16(input []float32) []int16 {
+	output := make([]int16, len(input))
+	for i, value := range input {
+		output[i] = clip(float64(value))
+	}
+	return output
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// kaiser evaluates the Kaiser window at r in [-1, 1].
+func kaiser(r float64) float64 {
+	if r < -1 || r > 1 {
+		return 0
+	}
+	return besselI0(kaiserBeta*math.Sqrt(1-r*r)) / besselI0(kaiserBeta)
+}
+
+// besselI0 is the zeroth-order modified Bessel function of the first kind.
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; term > 1e-12*sum; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+	}
+	return sum
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
@@ -0,0 +1,132 @@
+package sound
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func toneFloat32(freq float64, rate, n int, amplitude float64) []float32 {
+	out := make([]float32, n)
+	for i := range out {
+		out[i] = float32(amplitude * math.Sin(2*math.Pi*freq*float64(i)/float64(rate)))
+	}
+	return out
+}
+
+// amplitudeAt measures the amplitude of freq in signal by correlating it
+// with a sine and cosine, skipping the edges where the filter ramps up.
+func amplitudeAt(signal []float32, freq float64, rate int) float64 {
+	edge := len(signal) / 10
+	var sinSum, cosSum float64
+	for i := edge; i < len(signal)-edge; i++ {
+		phase := 2 * math.Pi * freq * float64(i) / float64(rate)
+		sinSum += float64(signal[i]) * math.Sin(phase)
+		cosSum += float64(signal[i]) * math.Cos(phase)
+	}
+	n := float64(len(signal) - 2*edge)
+	return 2 * math.Hypot(sinSum, cosSum) / n
+}
+
+func rmsFloat32(signal []float32) float64 {
+	edge := len(signal) / 10
+	var sum float64
+	for _, s := range signal[edge : len(signal)-edge] {
+		sum += float64(s) * float64(s)
+	}
+	return math.Sqrt(sum / float64(len(signal)-2*edge))
+}
+
+func decibels(ratio float64) float64 {
+	return 20 * math.Log10(ratio)
+}
+
+func TestResamplePassband(t *testing.T) {
+	for _, inputRate := range []int{48000, 44100, 22050, 8000} {
+		input := toneFloat32(1000, inputRate, inputRate, 10000)
+		output := ResampleFloat32(input, inputRate, 16000)
+
+		assert.InDelta(t, 16000, len(output), 1, "rate %d length", inputRate)
+		gain := decibels(amplitudeAt(output, 1000, 16000) / 10000)
+		assert.InDelta(t, 0, gain, 0.1, "rate %d: 1 kHz should pass unchanged, got %.2f dB", inputRate, gain)
+	}
+}
+
+func TestResampleRejectsAliases(t *testing.T) {
+	// Above 8 kHz these tones cannot be represented at 16 kHz; a resampler
+	// without an anti-aliasing filter folds them back into the speech band.
+	for _, freq := range []float64{10000, 12000, 15000, 21000} {
+		input := toneFloat32(freq, 48000, 48000, 10000)
+		output := ResampleFloat32(input, 48000, 16000)
+
+		attenuation := decibels(rmsFloat32(output) / (10000 / math.Sqrt2))
+		assert.Less(t, attenuation, -60.0, "%.0f Hz leaked through at %.1f dB", freq, attenuation)
+	}
+}
+
+func TestResampleStreamingMatchesOneShot(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	input := make([]float32, 44100)
+	for i := range input {
+		input[i] = float32(rng.Float64()*20000 - 10000)
+	}
+	want := ResampleFloat32(input, 44100, 16000)
+
+	r := NewResampler(44100, 16000)
+	var got []float32
+	for start := 0; start < len(input); {
+		end := min(start+1+rng.Intn(3000), len(input))
+		got = append(got, r.ProcessFloat32(input[start:end])...)
+		start = end
+	}
+	got = append(got, r.FlushFloat32()...)
+
+	require.Len(t, got, len(want))
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("sample %d differs: streaming %v, one-shot %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestResampleChunkBoundariesDoNotClick(t *testing.T) {
+	r := NewResampler(48000, 16000)
+	input := toneFloat32(440, 48000, 48000, 10000)
+
+	var output []int16
+	for start := 0; start < len(input); start += 512 * 9 {
+		end := min(start+512*9, len(input))
+		chunk := make([]int16, end-start)
+		for i, s := range input[start:end] {
+			chunk[i] = int16(s)
+		}
+		output = append(output, r.ProcessInt16(chunk)...)
+	}
+
+	// A 440 Hz tone at 16 kHz moves at most ~1730 per sample; a dropped or
+	// duplicated sample at a chunk boundary would jump much further.
+	maxStep := 2 * math.Pi * 440 / 16000 * 10000 * 1.05
+	for i := 1; i < len(output); i++ {
+		step := math.Abs(float64(output[i]) - float64(output[i-1]))
+		if i > 100 && step > maxStep {
+			t.Fatalf("discontinuity of %.0f at sample %d", step, i)
+		}
+	}
+}
+
+func TestResampleInt16Clips(t *testing.T) {
+	// A full-scale square wave makes the filter overshoot.
+	input := make([]int16, 4800)
+	for i := range input {
+		input[i] = math.MaxInt16
+		if (i/24)%2 == 1 {
+			input[i] = math.MinInt16
+		}
+	}
+	output := ResampleInt16(input, 48000, 16000)
+	assert.Len(t, output, 1600)
+	assert.Contains(t, output, int16(math.MaxInt16))
+}
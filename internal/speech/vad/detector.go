@@ -1,60 +1,117 @@
 package vad
 
 import (
+	"errors"
 	"time"
+
+	"github.com/go-audio/audio"
 )
 
-const DefaultQuietTime = time.Millisecond * 1000
+const (
+	DefaultQuietTime   = time.Millisecond * 1000
+	DefaultSensitivity = 1.5
+	DefaultFluxWidth   = sileroWindowSize
+
+	// detectorSampleRate is the rate the speech pipeline feeds detectors at.
+	detectorSampleRate = 16000
+)
 
+// Detector is a spectral flux voice activity detector. It needs no model or
+// native library, so it works where onnxruntime is not installed.
 type Detector struct {
-	lastFlux       float64
-	sensitivity    float64
-	start          time.Time
-	quietTimeDelay time.Duration
-	vad            *VAD
+	lastFlux     float64
+	sensitivity  float64
+	quietSamples int // samples heard since flux last crossed the threshold
+	quietDelay   int // quietTimeDelay in samples
+	heard        bool
+	primed       bool
+	width        int
+	vad          *VAD
 }
 
 func NewDetector(sensitivity float64, delay time.Duration, width int) *Detector {
 	return &Detector{
-		sensitivity:    sensitivity,
-		quietTimeDelay: delay,
-		vad:            NewVAD(width),
+		sensitivity: sensitivity,
+		quietDelay:  int(delay.Seconds() * detectorSampleRate),
+		width:       width,
+		vad:         NewVAD(width),
+	}
+}
+
+// HearSomething reports whether little-endian 16-bit samples contain voice.
+func (d *Detector) HearSomething(samples []byte) (bool, error) {
+	int16s, err := bytesToInt16sLE(samples)
+	if err != nil {
+		return false, err
 	}
+	return d.IsSpeech(int16s)
 }
 
-func (d *Detector) HearSomething(samples []byte) bool {
-	flux := d.vad.Flux(bytesToInt16sLE(samples))
+// IsSpeech classifies one frame of up to width samples. Quiet time is
+// counted in samples rather than wall-clock time, so files are classified
+// the same way as a live stream.
+func (d *Detector) IsSpeech(frame []int16) (bool, error) {
+	flux, err := d.vad.Flux(frame)
+	if err != nil {
+		return false, err
+	}
+
+	// The first frame is compared against silence, so its flux says nothing
+	// about the background; only use it to prime the spectrum.
+	if !d.primed {
+		d.primed = true
+		return false, nil
+	}
 
 	if d.lastFlux == 0 {
 		d.lastFlux = flux * d.sensitivity
-		return false
+		return false, nil
 	}
 
 	if flux >= d.lastFlux {
-		d.start = time.Now()
-		return true
+		d.heard = true
+		d.quietSamples = 0
+		return true, nil
 	}
 
-	if time.Since(d.start) < d.quietTimeDelay {
-		return true
-	}
+	d.quietSamples += len(frame)
+	return d.heard && d.quietSamples < d.quietDelay, nil
+}
+
+// DetectVoice reports whether any frame of the buffer contains voice.
+func (d *Detector) DetectVoice(buffer *audio.IntBuffer) (bool, error) {
+	detected := false
+	for start := 0; start+d.width <= len(buffer.Data); start += d.width {
+		frame := make([]int16, d.width)
+		for i := range frame {
+			frame[i] = int16(buffer.Data[start+i])
+		}
 
-	if flux*d.sensitivity <= d.lastFlux {
-		return false
+		voice, err := d.IsSpeech(frame)
+		if err != nil {
+			return false, err
+		}
+		detected = detected || voice
 	}
+	return detected, nil
+}
+
+func (d *Detector) FrameSize() int {
+	return d.width
+}
 
-	return false
+func (d *Detector) Close() error {
+	return nil
 }
 
-func bytesToInt16sLE(bytes []byte) []int16 {
-	// Ensure the byte slice length is even
+func bytesToInt16sLE(bytes []byte) ([]int16, error) {
 	if len(bytes)%2 != 0 {
-		panic("bytesToInt16sLE: input bytes slice has odd length, must be even")
+		return nil, errors.New("bytesToInt16sLE: input bytes slice has odd length, must be even")
 	}
 
 	int16s := make([]int16, len(bytes)/2)
 	for i := 0; i < len(int16s); i++ {
 		int16s[i] = int16(bytes[2*i]) | int16(bytes[2*i+1])<<8
 	}
-	return int16s
+	return int16s, nil
 }
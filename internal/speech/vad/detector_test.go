@@ -0,0 +1,69 @@
+package vad
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-audio/audio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBytesToInt16sLE(t *testing.T) {
+	got, err := bytesToInt16sLE([]byte{0x01, 0x00, 0xff, 0xff, 0x00, 0x80})
+	require.NoError(t, err)
+	assert.Equal(t, []int16{1, -1, -32768}, got)
+
+	_, err = bytesToInt16sLE([]byte{0x01, 0x00, 0x02})
+	assert.Error(t, err)
+}
+
+func TestDetectorErrorsInsteadOfPanicking(t *testing.T) {
+	d := NewDetector(DefaultSensitivity, DefaultQuietTime, 256)
+
+	_, err := d.HearSomething([]byte{0x01})
+	assert.Error(t, err)
+
+	_, err = d.IsSpeech(make([]int16, 512))
+	assert.Error(t, err, "frames longer than the FFT width are rejected")
+}
+
+func TestDetectorHearsToneOnset(t *testing.T) {
+	pcm := newSignal().
+		noise(time.Second, 50).
+		tone(500*time.Millisecond, 5000).
+		noise(2*time.Second, 50).
+		samples
+
+	d := NewDetector(DefaultSensitivity, 200*time.Millisecond, DefaultFluxWidth)
+	var heard []bool
+	for start := 0; start+DefaultFluxWidth <= len(pcm); start += DefaultFluxWidth {
+		voice, err := d.IsSpeech(pcm[start : start+DefaultFluxWidth])
+		require.NoError(t, err)
+		heard = append(heard, voice)
+	}
+
+	onset := samples(time.Second) / DefaultFluxWidth
+	assert.NotContains(t, heard[:onset], true, "background noise is quiet")
+	assert.Contains(t, heard[onset:onset+2], true, "tone onset is heard")
+	assert.NotContains(t, heard[len(heard)-10:], true, "quiet again after the delay")
+}
+
+func TestDetectorDetectVoice(t *testing.T) {
+	buffer := func(pcm []int16) *audio.IntBuffer {
+		data := make([]int, len(pcm))
+		for i, s := range pcm {
+			data[i] = int(s)
+		}
+		return &audio.IntBuffer{Data: data, Format: &audio.Format{SampleRate: testRate, NumChannels: 1}}
+	}
+
+	d := NewDetector(DefaultSensitivity, DefaultQuietTime, DefaultFluxWidth)
+	detected, err := d.DetectVoice(buffer(newSignal().noise(time.Second, 50).samples))
+	require.NoError(t, err)
+	assert.False(t, detected)
+
+	detected, err = d.DetectVoice(buffer(newSignal().tone(time.Second, 5000).samples))
+	require.NoError(t, err)
+	assert.True(t, detected)
+}
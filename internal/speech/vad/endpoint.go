@@ -0,0 +1,253 @@
+package vad
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// FrameClassifier scores one fixed-size window of audio at a time. It is fed
+// every frame of the stream in order, so implementations may keep state.
+type FrameClassifier interface {
+	IsSpeech(frame []int16) (bool, error)
+}
+
+// EndpointerConfig tunes how an Endpointer turns frames into utterances.
+type EndpointerConfig struct {
+	SampleRate int
+	// FrameSize is the number of samples handed to the classifier per call.
+	FrameSize int
+	// PreRoll is the audio kept from before speech starts, so soft onsets
+	// are not clipped.
+	PreRoll time.Duration
+	// Hangover is how long speech may pause before the utterance is closed.
+	Hangover time.Duration
+	// MinSpeech drops utterances with less voiced audio than this.
+	MinSpeech time.Duration
+	// MaxUtterance splits long speech so segments stay within backend limits.
+	MaxUtterance time.Duration
+	// Calibration is the leading audio used to measure the noise floor.
+	// Frames in this period are never treated as speech.
+	Calibration time.Duration
+	// NoiseMargin is how far above the noise floor a frame's RMS must be for
+	// the classifier's verdict to count.
+	NoiseMargin float64
+}
+
+// DefaultEndpointerConfig suits 16 kHz audio classified by Silero.
+func DefaultEndpointerConfig() EndpointerConfig {
+	return EndpointerConfig{
+		SampleRate:   16000,
+		FrameSize:    sileroWindowSize,
+		PreRoll:      300 * time.Millisecond,
+		Hangover:     800 * time.Millisecond,
+		MinSpeech:    250 * time.Millisecond,
+		MaxUtterance: 25 * time.Second,
+		Calibration:  500 * time.Millisecond,
+		NoiseMargin:  2,
+	}
+}
+
+// Utterance is a run of speech cut from the stream. Start and End are
+// offsets from the first sample written to the Endpointer.
+type Utterance struct {
+	Samples []int16
+	Start   time.Duration
+	End     time.Duration
+}
+
+// noiseFloorAlpha is the weight of each silent frame in the noise floor's
+// moving average.
+const noiseFloorAlpha = 0.05
+
+// Endpointer is a streaming voice activity state machine. Samples of any
+// length are written to it; it classifies them frame by frame and emits an
+// Utterance once speech has been followed by Hangover of silence.
+type Endpointer struct {
+	classifier FrameClassifier
+	cfg        EndpointerConfig
+
+	pending []int16 // samples not yet making up a whole frame
+	frames  int     // frames consumed so far
+
+	preRoll    [][]int16 // most recent silent frames, oldest first
+	preRollLen int       // capacity of preRoll in frames
+
+	calibrationFrames int
+	noiseFloor        float64
+
+	speaking     bool
+	utterance    []int16
+	start        int // first frame of the current utterance, including pre-roll
+	voicedFrames int
+	silentFrames int
+}
+
+func NewEndpointer(classifier FrameClassifier, cfg EndpointerConfig) (*Endpointer, error) {
+	if cfg.SampleRate <= 0 || cfg.FrameSize <= 0 {
+		return nil, errors.New("endpointer: sample rate and frame size must be positive")
+	}
+	return &Endpointer{
+		classifier:        classifier,
+		cfg:               cfg,
+		preRollLen:        cfg.framesIn(cfg.PreRoll),
+		calibrationFrames: cfg.framesIn(cfg.Calibration),
+	}, nil
+}
+
+// Write feeds samples into the endpointer and returns any utterances that
+// were completed by them.
+func (e *Endpointer) Write(samples []int16) ([]Utterance, error) {
+	e.pending = append(e.pending, samples...)
+
+	var utterances []Utterance
+	for len(e.pending) >= e.cfg.FrameSize {
+		frame := make([]int16, e.cfg.FrameSize)
+		copy(frame, e.pending)
+		e.pending = e.pending[e.cfg.FrameSize:]
+
+		u, err := e.processFrame(frame)
+		if err != nil {
+			return utterances, err
+		}
+		if u != nil {
+			utterances = append(utterances, *u)
+		}
+	}
+
+	// Keep the leftover at the front so pending does not grow without bound.
+	e.pending = append(e.pending[:0], e.pending...)
+	return utterances, nil
+}
+
+// Flush closes the utterance in progress, if any, as if the stream ended.
+func (e *Endpointer) Flush() *Utterance {
+	if !e.speaking {
+		return nil
+	}
+	return e.finish(e.frames)
+}
+
+// Speaking reports whether an utterance is in progress.
+func (e *Endpointer) Speaking() bool {
+	return e.speaking
+}
+
+// NoiseFloor returns the current estimate of background RMS.
+func (e *Endpointer) NoiseFloor() float64 {
+	return e.noiseFloor
+}
+
+func (e *Endpointer) processFrame(frame []int16) (*Utterance, error) {
+	index := e.frames
+	e.frames++
+
+	detected, err := e.classifier.IsSpeech(frame)
+	if err != nil {
+		return nil, err
+	}
+
+	level := rms(frame)
+	if index < e.calibrationFrames {
+		// Running mean over the calibration period.
+		e.noiseFloor += (level - e.noiseFloor) / float64(index+1)
+		e.remember(frame)
+		return nil, nil
+	}
+
+	voiced := detected && level > e.noiseFloor*e.cfg.NoiseMargin
+	if !voiced && !e.speaking {
+		e.noiseFloor += noiseFloorAlpha * (level - e.noiseFloor)
+	}
+
+	if !e.speaking {
+		if !voiced {
+			e.remember(frame)
+			return nil, nil
+		}
+		e.speaking = true
+		e.start = index - len(e.preRoll)
+		e.utterance = e.utterance[:0]
+		for _, f := range e.preRoll {
+			e.utterance = append(e.utterance, f...)
+		}
+		e.preRoll = e.preRoll[:0]
+		e.voicedFrames = 0
+		e.silentFrames = 0
+	}
+
+	e.utterance = append(e.utterance, frame...)
+	if voiced {
+		e.voicedFrames++
+		e.silentFrames = 0
+	} else {
+		e.silentFrames++
+	}
+
+	switch {
+	case e.silentFrames >= e.cfg.framesIn(e.cfg.Hangover):
+		return e.finish(index + 1), nil
+	case len(e.utterance) >= e.cfg.samplesIn(e.cfg.MaxUtterance):
+		// Split without leaving the speaking state; the next frame starts a
+		// fresh utterance.
+		u := e.finish(index + 1)
+		e.speaking = true
+		e.start = index + 1
+		e.voicedFrames = 0
+		e.silentFrames = 0
+		return u, nil
+	}
+	return nil, nil
+}
+
+// finish closes the current utterance at frame end, returning nil when it
+// holds too little speech.
+func (e *Endpointer) finish(end int) *Utterance {
+	e.speaking = false
+	voiced := e.voicedFrames
+	samples := e.utterance
+	e.utterance = nil
+
+	if voiced < e.cfg.framesIn(e.cfg.MinSpeech) {
+		return nil
+	}
+	return &Utterance{
+		Samples: samples,
+		Start:   e.cfg.frameTime(e.start),
+		End:     e.cfg.frameTime(end),
+	}
+}
+
+// remember keeps a silent frame for pre-roll.
+func (e *Endpointer) remember(frame []int16) {
+	if e.preRollLen == 0 {
+		return
+	}
+	if len(e.preRoll) == e.preRollLen {
+		e.preRoll = append(e.preRoll[:0], e.preRoll[1:]...)
+	}
+	e.preRoll = append(e.preRoll, frame)
+}
+
+func (c EndpointerConfig) samplesIn(d time.Duration) int {
+	return int(d.Seconds() * float64(c.SampleRate))
+}
+
+// framesIn rounds a duration up to whole frames.
+func (c EndpointerConfig) framesIn(d time.Duration) int {
+	return (c.samplesIn(d) + c.FrameSize - 1) / c.FrameSize
+}
+
+func (c EndpointerConfig) frameTime(frame int) time.Duration {
+	return time.Duration(frame*c.FrameSize) * time.Second / time.Duration(c.SampleRate)
+}
+
+// rms calculates the root-mean-square of int16 samples.
+func rms(samples []int16) float64 {
+	var sumSquares float64
+	for _, sample := range samples {
+		val := float64(sample)
+		sumSquares += val * val
+	}
+	return math.Sqrt(sumSquares / float64(len(samples)))
+}
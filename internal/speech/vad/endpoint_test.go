@@ -0,0 +1,188 @@
+package vad
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRate = 16000
+
+// levelClassifier stands in for Silero: frames louder than level are speech.
+type levelClassifier struct {
+	level float64
+	calls int
+}
+
+func (c *levelClassifier) IsSpeech(frame []int16) (bool, error) {
+	c.calls++
+	return rms(frame) > c.level, nil
+}
+
+// signal builds synthetic PCM from spans of noise and tone.
+type signal struct {
+	rng     *rand.Rand
+	samples []int16
+}
+
+func newSignal() *signal {
+	return &signal{rng: rand.New(rand.NewSource(42))}
+}
+
+func (s *signal) noise(d time.Duration, amplitude float64) *signal {
+	for i := 0; i < samples(d); i++ {
+		s.samples = append(s.samples, int16((s.rng.Float64()*2-1)*amplitude))
+	}
+	return s
+}
+
+func (s *signal) tone(d time.Duration, amplitude float64) *signal {
+	for i := 0; i < samples(d); i++ {
+		s.samples = append(s.samples, int16(amplitude*math.Sin(2*math.Pi*220*float64(i)/testRate)))
+	}
+	return s
+}
+
+func samples(d time.Duration) int {
+	return int(d.Seconds() * testRate)
+}
+
+func newTestEndpointer(t *testing.T, classifier FrameClassifier, modify func(*EndpointerConfig)) *Endpointer {
+	t.Helper()
+	cfg := DefaultEndpointerConfig()
+	if modify != nil {
+		modify(&cfg)
+	}
+	e, err := NewEndpointer(classifier, cfg)
+	require.NoError(t, err)
+	return e
+}
+
+// run writes the signal in uneven chunks and flushes at the end.
+func run(t *testing.T, e *Endpointer, pcm []int16) []Utterance {
+	t.Helper()
+	var utterances []Utterance
+	for start := 0; start < len(pcm); start += 333 {
+		end := min(start+333, len(pcm))
+		u, err := e.Write(pcm[start:end])
+		require.NoError(t, err)
+		utterances = append(utterances, u...)
+	}
+	if u := e.Flush(); u != nil {
+		utterances = append(utterances, *u)
+	}
+	return utterances
+}
+
+const frameDuration = time.Second * sileroWindowSize / testRate
+
+func TestEndpointerSingleUtterance(t *testing.T) {
+	pcm := newSignal().
+		noise(time.Second, 50).
+		tone(time.Second, 5000).
+		noise(2*time.Second, 50).
+		samples
+
+	e := newTestEndpointer(t, &levelClassifier{level: 200}, nil)
+	utterances := run(t, e, pcm)
+	require.Len(t, utterances, 1)
+
+	u := utterances[0]
+	cfg := DefaultEndpointerConfig()
+	assert.InDelta(t, time.Second-cfg.PreRoll, u.Start, float64(2*frameDuration), "starts with pre-roll")
+	assert.InDelta(t, 2*time.Second+cfg.Hangover, u.End, float64(2*frameDuration), "ends after hangover")
+	assert.Len(t, u.Samples, samples(u.End-u.Start))
+	assert.False(t, e.Speaking())
+}
+
+func TestEndpointerHangoverBridgesPauses(t *testing.T) {
+	short := newSignal().
+		noise(time.Second, 50).
+		tone(500*time.Millisecond, 5000).
+		noise(400*time.Millisecond, 50).
+		tone(500*time.Millisecond, 5000).
+		noise(2*time.Second, 50).
+		samples
+	assert.Len(t, run(t, newTestEndpointer(t, &levelClassifier{level: 200}, nil), short), 1)
+
+	long := newSignal().
+		noise(time.Second, 50).
+		tone(500*time.Millisecond, 5000).
+		noise(1200*time.Millisecond, 50).
+		tone(500*time.Millisecond, 5000).
+		noise(2*time.Second, 50).
+		samples
+	assert.Len(t, run(t, newTestEndpointer(t, &levelClassifier{level: 200}, nil), long), 2)
+}
+
+func TestEndpointerDropsShortBursts(t *testing.T) {
+	pcm := newSignal().
+		noise(time.Second, 50).
+		tone(100*time.Millisecond, 5000).
+		noise(2*time.Second, 50).
+		samples
+
+	assert.Empty(t, run(t, newTestEndpointer(t, &levelClassifier{level: 200}, nil), pcm))
+}
+
+func TestEndpointerSplitsLongSpeech(t *testing.T) {
+	pcm := newSignal().
+		noise(time.Second, 50).
+		tone(5*time.Second, 5000).
+		noise(2*time.Second, 50).
+		samples
+
+	e := newTestEndpointer(t, &levelClassifier{level: 200}, func(cfg *EndpointerConfig) {
+		cfg.MaxUtterance = 2 * time.Second
+	})
+	utterances := run(t, e, pcm)
+	require.Len(t, utterances, 3)
+	for i, u := range utterances {
+		assert.LessOrEqual(t, len(u.Samples), samples(2*time.Second)+sileroWindowSize, "utterance %d", i)
+		if i > 0 {
+			assert.Equal(t, utterances[i-1].End, u.Start, "splits are contiguous")
+		}
+	}
+}
+
+func TestEndpointerCalibratesNoiseFloor(t *testing.T) {
+	// A classifier fooled by steady background noise, like a fan.
+	fooled := &levelClassifier{level: 0}
+
+	pcm := newSignal().
+		noise(2*time.Second, 1500).
+		tone(time.Second, 8000).
+		noise(2*time.Second, 1500).
+		samples
+
+	e := newTestEndpointer(t, fooled, nil)
+	utterances := run(t, e, pcm)
+	require.Len(t, utterances, 1)
+	assert.InDelta(t, 2*time.Second, utterances[0].Start, float64(DefaultEndpointerConfig().PreRoll+2*frameDuration))
+	assert.InDelta(t, 1500/math.Sqrt(3), e.NoiseFloor(), 100, "uniform noise RMS")
+	assert.Equal(t, len(pcm)/sileroWindowSize, fooled.calls, "every frame is classified")
+}
+
+func TestEndpointerFlushesOpenUtterance(t *testing.T) {
+	pcm := newSignal().
+		noise(time.Second, 50).
+		tone(time.Second, 5000).
+		samples
+
+	e := newTestEndpointer(t, &levelClassifier{level: 200}, nil)
+	for start := 0; start < len(pcm); start += 1000 {
+		u, err := e.Write(pcm[start:min(start+1000, len(pcm))])
+		require.NoError(t, err)
+		assert.Empty(t, u)
+	}
+	assert.True(t, e.Speaking())
+
+	u := e.Flush()
+	require.NotNil(t, u)
+	assert.InDelta(t, 2*time.Second, u.End, float64(frameDuration))
+	assert.Nil(t, e.Flush())
+}
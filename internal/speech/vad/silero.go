@@ -1,7 +1,10 @@
+//go:build !nosilero
+
 package vad
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/go-audio/audio"
 	"github.com/streamer45/silero-vad-go/speech"
@@ -9,13 +12,14 @@ import (
 
 type SileroDetector struct {
 	detector *speech.Detector
+	speaking bool
 }
 
 func NewSileroDetector(filepath string) (*SileroDetector, error) {
 	sd, err := speech.NewDetector(speech.DetectorConfig{
 		ModelPath:            filepath,
 		SampleRate:           16000,
-		WindowSize:           1024,
+		WindowSize:           sileroWindowSize,
 		Threshold:            0.5,
 		MinSilenceDurationMs: 0,
 		SpeechPadMs:          0,
@@ -39,6 +43,12 @@ func NewSileroDetector(filepath string) (*SileroDetector, error) {
 //	  }
 //	}
 func (s *SileroDetector) DetectVoice(buffer *audio.IntBuffer) (bool, error) {
+	// Each buffer is classified on its own. Speech left open by an earlier
+	// call would otherwise end here as an "unexpected speech end" error.
+	if err := s.detector.Reset(); err != nil {
+		return false, fmt.Errorf("reset: %w", err)
+	}
+	s.speaking = false
 	pcmBuf := buffer.AsFloat32Buffer()
 
 	segments, err := s.detector.Detect(pcmBuf.Data)
@@ -48,3 +58,41 @@ func (s *SileroDetector) DetectVoice(buffer *audio.IntBuffer) (bool, error) {
 
 	return len(segments) > 0, nil
 }
+
+// IsSpeech classifies a single window of sileroWindowSize samples. The model
+// state carries over between calls, so it should be fed a continuous stream.
+func (s *SileroDetector) IsSpeech(frame []int16) (bool, error) {
+	if len(frame) != sileroWindowSize {
+		return false, fmt.Errorf("silero expects %d samples per frame, got %d", sileroWindowSize, len(frame))
+	}
+
+	// Detect only scores a window when at least one more sample follows it.
+	pcm := make([]float32, len(frame)+1)
+	for i, sample := range frame {
+		pcm[i] = float32(sample) / 32768
+	}
+
+	segments, err := s.detector.Detect(pcm)
+	if err != nil {
+		// A speech end whose start was reported by an earlier call comes back
+		// as an error, after the detector has already switched to silence.
+		if strings.Contains(err.Error(), "unexpected speech end") {
+			s.speaking = false
+			return false, nil
+		}
+		return false, fmt.Errorf("detect: %w", err)
+	}
+
+	if len(segments) > 0 {
+		s.speaking = segments[len(segments)-1].SpeechEndAt == 0
+	}
+	return s.speaking, nil
+}
+
+func (s *SileroDetector) FrameSize() int {
+	return sileroWindowSize
+}
+
+func (s *SileroDetector) Close() error {
+	return s.detector.Destroy()
+}
@@ -0,0 +1,35 @@
+//go:build nosilero
+
+package vad
+
+import (
+	"errors"
+
+	"github.com/go-audio/audio"
+)
+
+// ErrSileroUnavailable is returned when blab is built with the nosilero tag,
+// which drops the onnxruntime dependency.
+var ErrSileroUnavailable = errors.New("silero VAD is not available in this build (built with -tags nosilero)")
+
+type SileroDetector struct{}
+
+func NewSileroDetector(filepath string) (*SileroDetector, error) {
+	return nil, ErrSileroUnavailable
+}
+
+func (s *SileroDetector) DetectVoice(buffer *audio.IntBuffer) (bool, error) {
+	return false, ErrSileroUnavailable
+}
+
+func (s *SileroDetector) IsSpeech(frame []int16) (bool, error) {
+	return false, ErrSileroUnavailable
+}
+
+func (s *SileroDetector) FrameSize() int {
+	return sileroWindowSize
+}
+
+func (s *SileroDetector) Close() error {
+	return nil
+}
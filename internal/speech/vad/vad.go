@@ -1,6 +1,7 @@
 package vad
 
 import (
+	"fmt"
 	"github.com/mjibson/go-dsp/fft"
 	"math"
 )
@@ -21,26 +22,38 @@ func NewVAD(width int) *VAD {
 }
 
 // Flux Given the samples, return the spectral flux value as compared to the previous samples.
-func (v *VAD) Flux(samples []int16) float64 {
-	for i, s := range samples {
+// Frames shorter than the width are zero padded.
+func (v *VAD) Flux(samples []int16) (float64, error) {
+	if len(samples) > len(v.samples) {
+		return 0, fmt.Errorf("frame of %d samples exceeds FFT width %d", len(samples), len(v.samples))
+	}
+
+	for i := range v.samples {
+		var s int16
+		if i < len(samples) {
+			s = samples[i]
+		}
 		v.samples[i] = complex(float64(s), 0)
 	}
 
 	v.fft = fft.FFT(v.samples)
-	copy(v.spectrum, v.lastSpectrum)
+	copy(v.lastSpectrum, v.spectrum)
 
-	for i, _ := range v.spectrum {
+	for i := range v.spectrum {
 		c := v.fft[i]
 		v.spectrum[i] = math.Sqrt(real(c)*real(c) + imag(c)*imag(c))
 	}
 
+	// Only rising energy counts towards flux, so a sound fading out does not
+	// register as an onset.
 	var flux float64
-
 	for i, s := range v.spectrum {
-		flux += s - v.lastSpectrum[i]
+		if diff := s - v.lastSpectrum[i]; diff > 0 {
+			flux += diff
+		}
 	}
 
-	return flux
+	return flux, nil
 }
 
 func (v *VAD) FFT() []complex128 {
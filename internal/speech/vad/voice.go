@@ -0,0 +1,25 @@
+package vad
+
+import (
+	"github.com/go-audio/audio"
+)
+
+// sileroWindowSize is the number of 16 kHz samples Silero scores at a time.
+const sileroWindowSize = 512
+
+// VoiceDetector is implemented by each VAD engine so the capture loop and
+// file transcription can use either one.
+type VoiceDetector interface {
+	FrameClassifier
+	// DetectVoice reports whether a whole 16 kHz buffer contains speech.
+	DetectVoice(buffer *audio.IntBuffer) (bool, error)
+	// FrameSize is the number of samples IsSpeech expects.
+	FrameSize() int
+	// Close releases any resources held by the engine.
+	Close() error
+}
+
+var (
+	_ VoiceDetector = (*SileroDetector)(nil)
+	_ VoiceDetector = (*Detector)(nil)
+)
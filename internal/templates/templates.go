@@ -0,0 +1,317 @@
+// Package templates stores reusable prompts as text/template files, filled in
+// with variables and the file and clipboard built-ins.
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// Ext is the extension of template files; the rest of the file name is the
+// template's name.
+const Ext = ".tmpl"
+
+var ErrNotFound = errors.New("template not found")
+
+// Template is a prompt with {{.Var}} placeholders.
+type Template struct {
+	Name string
+	// Description is the comment the file starts with, if any, e.g.
+	// {{/* Review a diff */}}.
+	Description string
+	// Variables are the placeholders the template uses, in order.
+	Variables []string
+	text      string
+}
+
+// Store reads the templates from a directory. Files are read on every call,
+// so edits apply without a restart.
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Dir is where the templates are kept.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// List reads every template, sorted by name. A missing directory holds no
+// templates. A template that cannot be read is still listed, with the error
+// as its description, so one broken file leaves the others usable.
+func (s *Store) List() ([]Template, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Template
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), Ext)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		t, err := s.Get(name)
+		if errors.Is(err, ErrNotFound) {
+			continue // removed since ReadDir
+		}
+		if err != nil {
+			t = Template{Name: name, Description: "error: " + err.Error()}
+		}
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// Get reads one template.
+func (s *Store) Get(name string) (Template, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return Template{}, fmt.Errorf("%w: %q", ErrNotFound, name)
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, name+Ext))
+	if errors.Is(err, os.ErrNotExist) {
+		return Template{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return Template{}, err
+	}
+
+	text := string(data)
+	parsed, err := parseText(name, text)
+	if err != nil {
+		return Template{}, err
+	}
+	return Template{
+		Name:        name,
+		Description: description(text),
+		Variables:   variables(parsed.Tree.Root),
+		text:        text,
+	}, nil
+}
+
+// Expand fills a template in with vars. Every variable it uses must be set.
+func (s *Store) Expand(name string, vars map[string]string) (string, error) {
+	t, err := s.Get(name)
+	if err != nil {
+		return "", err
+	}
+	return t.Expand(vars)
+}
+
+// Expand fills the template in with vars. Every variable it uses must be set.
+func (t Template) Expand(vars map[string]string) (string, error) {
+	var missing []string
+	for _, name := range t.Variables {
+		if _, ok := vars[name]; !ok {
+			missing = append(missing, name+"=")
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("template %s needs %s", t.Name, strings.Join(missing, " "))
+	}
+
+	parsed, err := parseText(t.Name, t.text)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	if err := parsed.Execute(&out, vars); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+func parseText(name, text string) (*template.Template, error) {
+	return template.New(name).
+		Option("missingkey=error").
+		Funcs(template.FuncMap{"file": readFile, "clipboard": readClipboard}).
+		Parse(text)
+}
+
+// description is the text of the comment a template starts with.
+func description(text string) string {
+	comment, ok := strings.CutPrefix(strings.TrimSpace(text), "{{/*")
+	if !ok {
+		return ""
+	}
+	comment, _, ok = strings.Cut(comment, "*/}}")
+	if !ok {
+		return ""
+	}
+	return strings.Join(strings.Fields(comment), " ")
+}
+
+// variables lists the {{.Var}} fields used under node, once each.
+func variables(node parse.Node) []string {
+	var names []string
+	seen := map[string]bool{}
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	var walk func(parse.Node)
+	walk = func(node parse.Node) {
+		switch node := node.(type) {
+		case *parse.ListNode:
+			if node == nil {
+				return
+			}
+			for _, n := range node.Nodes {
+				walk(n)
+			}
+		case *parse.ActionNode:
+			walk(node.Pipe)
+		case *parse.PipeNode:
+			if node == nil {
+				return
+			}
+			for _, cmd := range node.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			for _, arg := range node.Args {
+				walk(arg)
+			}
+		case *parse.IfNode:
+			walk(node.Pipe)
+			walk(node.List)
+			walk(node.ElseList)
+		// Inside range and with, . is the element or value rather than the
+		// variables, so only their pipeline and else branch name any.
+		case *parse.RangeNode:
+			walk(node.Pipe)
+			walk(node.ElseList)
+		case *parse.WithNode:
+			walk(node.Pipe)
+			walk(node.ElseList)
+		case *parse.FieldNode:
+			add(node.Ident[0])
+		case *parse.VariableNode:
+			// $ is the variables wherever it is used.
+			if node.Ident[0] == "$" && len(node.Ident) > 1 {
+				add(node.Ident[1])
+			}
+		}
+	}
+	walk(node)
+	return names
+}
+
+// readFile is the {{file "path"}} built-in. A leading ~ is the home
+// directory, and relative paths are relative to where blab was started.
+func readFile(path string) (string, error) {
+	if rest, ok := strings.CutPrefix(path, "~"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, rest)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// readClipboard is the {{clipboard}} built-in. It is a variable so tests can
+// replace it.
+var readClipboard = func() (string, error) {
+	for _, command := range clipboardCommands() {
+		if _, err := exec.LookPath(command[0]); err != nil {
+			continue
+		}
+		out, err := exec.Command(command[0], command[1:]...).Output()
+		if err != nil {
+			return "", fmt.Errorf("reading clipboard with %s: %w", command[0], err)
+		}
+		return string(out), nil
+	}
+	return "", errors.New("no clipboard tool found, install wl-clipboard, xclip or xsel")
+}
+
+func clipboardCommands() [][]string {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"pbpaste"}}
+	case "windows":
+		return [][]string{{"powershell", "-NoProfile", "-Command", "Get-Clipboard"}}
+	}
+	return [][]string{
+		{"wl-paste", "--no-newline"},
+		{"xclip", "-selection", "clipboard", "-out"},
+		{"xsel", "--clipboard", "--output"},
+	}
+}
+
+// ParseArgs splits the arguments of /t into the template name and its
+// key=value variables. Values may be quoted with ' or " to hold spaces.
+func ParseArgs(args string) (string, map[string]string, error) {
+	words, err := splitWords(args)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(words) == 0 {
+		return "", nil, errors.New("no template named")
+	}
+
+	vars := map[string]string{}
+	for _, word := range words[1:] {
+		key, value, ok := strings.Cut(word, "=")
+		if !ok || key == "" {
+			return "", nil, fmt.Errorf("%q is not key=value", word)
+		}
+		vars[key] = value
+	}
+	return words[0], vars, nil
+}
+
+func splitWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	var quote rune
+	inWord := false
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,114 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTemplate(t *testing.T, dir, name, text string) {
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name+Ext), []byte(text), 0644))
+}
+
+func TestList(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "tests", "Write {{.Lang}} tests for:\n{{.Code}}")
+	writeTemplate(t, dir, "review", "{{/* Review a diff\n for bugs */}}\nReview this {{.Lang}} diff:\n{{.Diff}}\nIn {{.Lang}}.")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a template"), 0644))
+
+	list, err := NewStore(dir).List()
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "review", list[0].Name)
+	assert.Equal(t, "Review a diff for bugs", list[0].Description)
+	assert.Equal(t, []string{"Lang", "Diff"}, list[0].Variables)
+	assert.Equal(t, "tests", list[1].Name)
+	assert.Equal(t, "", list[1].Description)
+
+	writeTemplate(t, dir, "broken", "{{.Lang")
+	list, err = NewStore(dir).List()
+	require.NoError(t, err)
+	require.Len(t, list, 3, "a broken template does not hide the others")
+	assert.Equal(t, "broken", list[0].Name)
+	assert.Contains(t, list[0].Description, "error: ")
+
+	list, err = NewStore(filepath.Join(dir, "missing")).List()
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+func TestExpand(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir)
+	writeTemplate(t, dir, "review", "{{/* Review a diff */}}\nReview this {{.Lang}} diff:\n{{.Diff}}\n")
+
+	text, err := store.Expand("review", map[string]string{"Lang": "Go", "Diff": "+x := 1"})
+	require.NoError(t, err)
+	assert.Equal(t, "Review this Go diff:\n+x := 1", text)
+
+	_, err = store.Expand("review", map[string]string{"Lang": "Go"})
+	assert.EqualError(t, err, "template review needs Diff=")
+
+	_, err = store.Expand("missing", nil)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = store.Expand("../review", nil)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	writeTemplate(t, dir, "broken", "{{.Lang")
+	_, err = store.Expand("broken", nil)
+	assert.Error(t, err)
+}
+
+func TestVariablesInsideBlocks(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir)
+	writeTemplate(t, dir, "blocks",
+		"{{with .Lang}}In {{.}}{{else}}{{.Fallback}}{{end}}\n"+
+			"{{range .Items}}{{.Name}}{{end}}\n"+
+			"{{with .Code}}{{.Field}} {{$.Lang}}{{end}}")
+
+	tmpl, err := store.Get("blocks")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Lang", "Fallback", "Items", "Code"}, tmpl.Variables)
+
+	writeTemplate(t, dir, "with", "{{with .Lang}}Written in {{.}}{{end}}")
+	text, err := store.Expand("with", map[string]string{"Lang": "Go"})
+	require.NoError(t, err)
+	assert.Equal(t, "Written in Go", text)
+}
+
+func TestBuiltins(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir)
+	code := filepath.Join(dir, "main.go")
+	require.NoError(t, os.WriteFile(code, []byte("package main"), 0644))
+	writeTemplate(t, dir, "explain", `Explain {{file .Path}} and {{clipboard}}`)
+
+	previous := readClipboard
+	readClipboard = func() (string, error) { return "copied text", nil }
+	t.Cleanup(func() { readClipboard = previous })
+
+	text, err := store.Expand("explain", map[string]string{"Path": code})
+	require.NoError(t, err)
+	assert.Equal(t, "Explain package main and copied text", text)
+
+	_, err = store.Expand("explain", map[string]string{"Path": filepath.Join(dir, "gone.go")})
+	assert.ErrorContains(t, err, "gone.go")
+}
+
+func TestParseArgs(t *testing.T) {
+	name, vars, err := ParseArgs(` review Lang=go Diff="a b  c" Note='it''s' Empty=`)
+	require.NoError(t, err)
+	assert.Equal(t, "review", name)
+	assert.Equal(t, map[string]string{"Lang": "go", "Diff": "a b  c", "Note": "its", "Empty": ""}, vars)
+
+	_, _, err = ParseArgs("")
+	assert.Error(t, err)
+	_, _, err = ParseArgs("review Lang")
+	assert.ErrorContains(t, err, `"Lang" is not key=value`)
+	_, _, err = ParseArgs(`review Diff="open`)
+	assert.ErrorContains(t, err, "unterminated")
+}
@@ -0,0 +1,75 @@
+package ui
+
+import (
+	"fmt"
+	"github.com/bz888/blab/internal/speech/pipeline"
+	"github.com/rivo/tview"
+	"strings"
+)
+
+// showTranscription prints what was heard, highlighting words that may have
+// been misheard.
+func showTranscription(t pipeline.Transcription) {
+	words := t.Words()
+	parts := make([]string, len(words))
+	for i, word := range words {
+		if word.Uncertain {
+			parts[i] = "[yellow::u]" + tview.Escape(word.Text) + "[-::-]"
+		} else {
+			parts[i] = tview.Escape(word.Text)
+		}
+	}
+	label := confidenceLabel(t.Confidence)
+	if t.Language != "" {
+		label = t.Language + ", " + label
+	}
+	fmt.Fprintf(textView, "\n[blue::]Heard:[-] %s (%s)\n", strings.Join(parts, " "), label)
+}
+
+// pickAlternative lets the user choose which hypothesis to send, or move it
+// into the input to edit it first. Must be called on the UI goroutine.
+func pickAlternative(t pipeline.Transcription, mainFlex *tview.Flex, send func(alternative pipeline.Alternative)) {
+	var pages *tview.Pages
+	closeModal := func() {
+		pages.RemovePage("alternativesModal")
+		app.SetRoot(mainFlex, true).SetFocus(textArea)
+	}
+
+	list := tview.NewList()
+	list.SetBorder(true).SetTitle("Did you say?")
+	for i, alternative := range t.Alternatives {
+		// Only the first nine get a digit, the rest no shortcut.
+		var shortcut rune
+		if i < 9 {
+			shortcut = '1' + rune(i)
+		}
+		list.AddItem(tview.Escape(alternative.Text), confidenceLabel(alternative.Confidence), shortcut, func() {
+			localLogger.Info("Selected alternative", "text", alternative.Text, "confidence", alternative.Confidence)
+			closeModal()
+			send(alternative)
+		})
+	}
+	list.
+		AddItem("Edit", "Move the best transcript into the input", 'e', func() {
+			closeModal()
+			textArea.SetText(t.Text, true)
+			textArea.SetDisabled(false)
+		}).
+		AddItem("Cancel", "", 'q', func() {
+			closeModal()
+			textArea.SetDisabled(false)
+		})
+
+	height := 2*list.GetItemCount() + 2
+	pages = tview.NewPages().
+		AddPage("main", mainFlex, true, true).
+		AddPage("alternativesModal", createModal(list, 60, height), true, true)
+	app.SetRoot(pages, true).SetFocus(list)
+}
+
+func confidenceLabel(confidence float64) string {
+	if confidence == 0 {
+		return "confidence unknown"
+	}
+	return fmt.Sprintf("confidence %.0f%%", confidence*100)
+}
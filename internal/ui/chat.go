@@ -0,0 +1,128 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bz888/blab/pkg/blabclient"
+	"github.com/rivo/tview"
+)
+
+// chatSession is the server session holding the conversation, created with
+// its first message. Messages are sent one at a time, with the input
+// disabled, so it needs no lock.
+var chatSession string
+
+// chat sends content to model and shows the reply as it streams. A non-empty
+// language asks the model to reply in it. It blocks until the reply ends, so
+// it must not run on the UI goroutine.
+func chat(model, content, language string) {
+	if content == "" {
+		localLogger.Warn("No content parsed")
+		return
+	}
+	app.QueueUpdateDraw(func() {
+		fmt.Fprintln(textView, "\n\n[red::]You:[-]")
+		fmt.Fprintf(textView, "%s\n\n", tview.Escape(content))
+	})
+
+	ctx := context.Background()
+	if chatSession == "" {
+		session, err := apiClient.CreateSession(ctx)
+		if err != nil {
+			localLogger.Error("Failed to start session", "err", err)
+			showChatError(err)
+			return
+		}
+		chatSession = session.ID
+	}
+
+	localLogger.Info("Input request", "model", model, "text", content, "session", chatSession)
+	events, err := apiClient.Chat(ctx, blabclient.ChatRequest{Model: model, Text: content, Language: language, Session: chatSession})
+	var statusErr *blabclient.StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		// The server forgot the session, e.g. after a restart.
+		chatSession = ""
+	}
+	if err != nil {
+		localLogger.Error("Chat request refused", "err", err)
+		showChatError(err)
+		return
+	}
+
+	app.QueueUpdateDraw(func() {
+		fmt.Fprintf(textView, "[green::]Bot:[-]\n")
+	})
+	for event := range events {
+		if failed, ok := event.(blabclient.Failed); ok {
+			localLogger.Error("Chat reply failed", "err", failed.Err)
+		}
+		text := formatChatEvent(event)
+		app.QueueUpdateDraw(func() {
+			fmt.Fprint(textView, text)
+		})
+	}
+}
+
+func showChatError(err error) {
+	app.QueueUpdateDraw(func() {
+		fmt.Fprint(textView, formatChatEvent(blabclient.Failed{Err: err}))
+	})
+}
+
+// formatChatEvent is how an event of a streamed reply shows in the chat.
+func formatChatEvent(event blabclient.Event) string {
+	switch event := event.(type) {
+	case blabclient.Queued:
+		return fmt.Sprintf("[gray]Waiting for the model, position %d in the queue[-]\n", event.Position)
+	case blabclient.Delta:
+		return tview.Escape(event.Text)
+	case blabclient.Failed:
+		return fmt.Sprintf("\n[red]%s[-]\n", tview.Escape(strings.TrimSpace(event.Err.Error())))
+	}
+	return ""
+}
+
+// sessionCommand shows the conversation's session, or with "new" starts a
+// new conversation, forgetting the current one.
+func sessionCommand(args string) {
+	switch strings.TrimSpace(args) {
+	case "":
+		if chatSession == "" {
+			fmt.Fprintf(textView, "\nNo messages sent yet\n")
+			textArea.SetDisabled(false)
+			return
+		}
+		go func() {
+			session, err := apiClient.Session(context.Background(), chatSession)
+			app.QueueUpdateDraw(func() {
+				textArea.SetDisabled(false)
+				if err != nil {
+					fmt.Fprintf(textView, "\nFailed to fetch session: %s\n", err)
+					return
+				}
+				fmt.Fprintf(textView, "\nSession %s, started %s, %d messages\n",
+					session.ID, session.Created.Format(time.TimeOnly), len(session.Messages))
+			})
+		}()
+	case "new":
+		previous := chatSession
+		chatSession = ""
+		fmt.Fprintf(textView, "\nStarted a new conversation\n")
+		textArea.SetDisabled(false)
+		if previous != "" {
+			go func() {
+				if err := apiClient.DeleteSession(context.Background(), previous); err != nil {
+					localLogger.Warn("Failed to delete session", "session", previous, "err", err)
+				}
+			}()
+		}
+	default:
+		fmt.Fprintf(textView, "\nUsage: /session [new]\n")
+		textArea.SetDisabled(false)
+	}
+}
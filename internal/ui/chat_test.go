@@ -0,0 +1,16 @@
+package ui
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bz888/blab/pkg/blabclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatChatEvent(t *testing.T) {
+	assert.Equal(t, "[gray]Waiting for the model, position 2 in the queue[-]\n", formatChatEvent(blabclient.Queued{Position: 2}))
+	assert.Equal(t, "see [red[]", formatChatEvent(blabclient.Delta{Text: "see [red]"}), "replies are not read as colour tags")
+	assert.Equal(t, "\n[red]ollama is busy[-]\n", formatChatEvent(blabclient.Failed{Err: errors.New("ollama is busy\n")}))
+	assert.Equal(t, "", formatChatEvent(blabclient.Done{Reply: "Hi"}))
+}
@@ -0,0 +1,294 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/bz888/blab/internal/logger"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+var levelColors = map[string]string{
+	"DEBUG": "gray",
+	"INFO":  "green",
+	"WARN":  "yellow",
+	"ERROR": "red",
+	"FATAL": "red",
+}
+
+// consoleFilter selects the entries the debug console shows.
+type consoleFilter struct {
+	level slog.Level
+	tags  map[string]bool // lowercase; empty shows every tag
+	query string          // lowercase text the line must contain
+}
+
+func (f consoleFilter) match(e logger.Entry) bool {
+	if e.Level < f.level {
+		return false
+	}
+	if len(f.tags) > 0 && !f.tags[strings.ToLower(e.Tag)] {
+		return false
+	}
+	return f.query == "" || strings.Contains(strings.ToLower(plainLine(e)), f.query)
+}
+
+// logBuffer is a ring buffer keeping the newest entries.
+type logBuffer struct {
+	entries []logger.Entry
+	start   int
+	size    int
+}
+
+func newLogBuffer(capacity int) *logBuffer {
+	return &logBuffer{entries: make([]logger.Entry, max(capacity, 1))}
+}
+
+func (b *logBuffer) add(e logger.Entry) {
+	if b.size < len(b.entries) {
+		b.entries[(b.start+b.size)%len(b.entries)] = e
+		b.size++
+		return
+	}
+	b.entries[b.start] = e
+	b.start = (b.start + 1) % len(b.entries)
+}
+
+// each calls fn on the entries from oldest to newest.
+func (b *logBuffer) each(fn func(logger.Entry)) {
+	for i := 0; i < b.size; i++ {
+		fn(b.entries[(b.start+i)%len(b.entries)])
+	}
+}
+
+// DebugConsole shows log entries with level, tag and text filters. Entries
+// arrive from the log worker and are drawn on the UI goroutine; everything
+// but Add must be called from the UI goroutine.
+type DebugConsole struct {
+	*tview.Flex
+	app    *tview.Application
+	view   *tview.TextView
+	search *tview.InputField
+	status *tview.TextView
+
+	mu        sync.Mutex
+	buffer    *logBuffer
+	pending   []logger.Entry // added since the last draw
+	scheduled bool
+
+	filter consoleFilter
+	paused bool
+}
+
+func newDebugConsole(app *tview.Application, lines int) *DebugConsole {
+	c := &DebugConsole{
+		app:    app,
+		buffer: newLogBuffer(lines),
+		filter: consoleFilter{level: slog.LevelDebug},
+	}
+
+	c.view = tview.NewTextView().
+		SetDynamicColors(true).
+		SetWordWrap(true).
+		SetMaxLines(lines)
+	c.view.ScrollToEnd()
+	c.view.SetInputCapture(c.handleKey)
+
+	c.search = tview.NewInputField().
+		SetLabel("Search: ").
+		SetFieldBackgroundColor(tcell.ColorDefault).
+		SetChangedFunc(func(text string) {
+			c.filter.query = strings.ToLower(text)
+			c.render()
+		}).
+		SetDoneFunc(func(tcell.Key) {
+			app.SetFocus(c.view)
+		})
+
+	c.status = tview.NewTextView().SetDynamicColors(true)
+
+	c.Flex = tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(c.status, 1, 0, false).
+		AddItem(c.search, 1, 0, false).
+		AddItem(c.view, 0, 1, true)
+	c.Flex.SetTitle("Debugger").SetBorder(true)
+	c.updateStatus()
+	return c
+}
+
+// Add queues an entry to be drawn. It never blocks on the UI.
+func (c *DebugConsole) Add(e logger.Entry) {
+	c.mu.Lock()
+	c.buffer.add(e)
+	c.pending = append(c.pending, e)
+	schedule := !c.scheduled
+	c.scheduled = true
+	c.mu.Unlock()
+
+	if schedule {
+		// QueueUpdateDraw blocks until the UI loop runs it, and never returns
+		// once the app has stopped, so it must not hold up the log worker.
+		go c.app.QueueUpdateDraw(c.flush)
+	}
+}
+
+// flush appends the entries added since the last draw.
+func (c *DebugConsole) flush() {
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = nil
+	c.scheduled = false
+	c.mu.Unlock()
+
+	w := c.view.BatchWriter()
+	for _, e := range pending {
+		if c.filter.match(e) {
+			fmt.Fprint(w, formatLine(e))
+		}
+	}
+	w.Close()
+	c.updateStatus()
+}
+
+// render redraws every buffered entry after the filter changed.
+func (c *DebugConsole) render() {
+	c.mu.Lock()
+	c.pending = nil
+	var lines []string
+	c.buffer.each(func(e logger.Entry) {
+		if c.filter.match(e) {
+			lines = append(lines, formatLine(e))
+		}
+	})
+	c.mu.Unlock()
+
+	w := c.view.BatchWriter()
+	w.Clear()
+	for _, line := range lines {
+		fmt.Fprint(w, line)
+	}
+	w.Close()
+	if !c.paused {
+		c.view.ScrollToEnd()
+	}
+	c.updateStatus()
+}
+
+func (c *DebugConsole) handleKey(event *tcell.EventKey) *tcell.EventKey {
+	switch event.Rune() {
+	case 'p':
+		c.SetPaused(!c.paused)
+	case '/':
+		c.app.SetFocus(c.search)
+	case 'd':
+		c.SetLevel(slog.LevelDebug)
+	case 'i':
+		c.SetLevel(slog.LevelInfo)
+	case 'w':
+		c.SetLevel(slog.LevelWarn)
+	case 'e':
+		c.SetLevel(slog.LevelError)
+	default:
+		return event
+	}
+	return nil
+}
+
+// SetLevel hides entries below level.
+func (c *DebugConsole) SetLevel(level slog.Level) {
+	c.filter.level = level
+	c.render()
+}
+
+// SetTags shows only entries with one of the tags, or every entry when
+// there are none.
+func (c *DebugConsole) SetTags(tags []string) {
+	c.filter.tags = map[string]bool{}
+	for _, tag := range tags {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			c.filter.tags[tag] = true
+		}
+	}
+	c.render()
+}
+
+// SetPaused stops or resumes following new entries. New entries are still
+// added while paused, without moving the view.
+func (c *DebugConsole) SetPaused(paused bool) {
+	c.paused = paused
+	if paused {
+		row, column := c.view.GetScrollOffset()
+		c.view.ScrollTo(row, column)
+	} else {
+		c.view.ScrollToEnd()
+	}
+	c.updateStatus()
+}
+
+func (c *DebugConsole) Paused() bool {
+	return c.paused
+}
+
+// Dump writes every buffered entry, ignoring the filters, to path.
+func (c *DebugConsole) Dump(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+
+	c.mu.Lock()
+	c.buffer.each(func(e logger.Entry) {
+		fmt.Fprintf(w, "%s %s\n", e.Time.Format("2006-01-02T15:04:05.000"), plainLine(e))
+	})
+	c.mu.Unlock()
+
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (c *DebugConsole) updateStatus() {
+	tags := "all"
+	if len(c.filter.tags) > 0 {
+		var names []string
+		for tag := range c.filter.tags {
+			names = append(names, tag)
+		}
+		tags = tview.Escape(strings.Join(names, ", "))
+	}
+	state := "[green]following[-]"
+	if c.paused {
+		state = "[yellow]paused[-]"
+	}
+
+	c.mu.Lock()
+	buffered, capacity := c.buffer.size, len(c.buffer.entries)
+	c.mu.Unlock()
+
+	c.status.SetText(fmt.Sprintf("%s  level %s  tags %s  lines %d/%d  [gray]p pause, / search, d/i/w/e level[-]",
+		state, logger.LevelName(c.filter.level), tags, buffered, capacity))
+}
+
+// formatLine renders an entry as one coloured console line.
+func formatLine(e logger.Entry) string {
+	level := logger.LevelName(e.Level)
+	color := levelColors[strings.SplitN(level, "+", 2)[0]]
+	if color == "" {
+		color = "white"
+	}
+	return fmt.Sprintf("[%s]%s %s[-]\n", color, e.Time.Format("15:04:05"), tview.Escape(plainLine(e)))
+}
+
+// plainLine is an entry without its time: LEVEL (tag): message key=value ...
+func plainLine(e logger.Entry) string {
+	return fmt.Sprintf("%s (%s): %s%s", logger.LevelName(e.Level), e.Tag, e.Message, e.Fields)
+}
@@ -0,0 +1,42 @@
+package ui
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/bz888/blab/internal/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func messages(b *logBuffer) []string {
+	var out []string
+	b.each(func(e logger.Entry) { out = append(out, e.Message) })
+	return out
+}
+
+func TestLogBufferKeepsNewest(t *testing.T) {
+	b := newLogBuffer(3)
+	for _, msg := range []string{"a", "b"} {
+		b.add(logger.Entry{Message: msg})
+	}
+	assert.Equal(t, []string{"a", "b"}, messages(b))
+
+	for _, msg := range []string{"c", "d", "e"} {
+		b.add(logger.Entry{Message: msg})
+	}
+	assert.Equal(t, []string{"c", "d", "e"}, messages(b))
+}
+
+func TestConsoleFilter(t *testing.T) {
+	entry := logger.Entry{Level: slog.LevelInfo, Tag: "Ollama stream chat", Message: "Received response", Fields: " content=Hello"}
+
+	assert.True(t, consoleFilter{level: slog.LevelDebug}.match(entry))
+	assert.False(t, consoleFilter{level: slog.LevelWarn}.match(entry))
+
+	assert.True(t, consoleFilter{tags: map[string]bool{"ollama stream chat": true}}.match(entry))
+	assert.False(t, consoleFilter{tags: map[string]bool{"views": true}}.match(entry))
+
+	assert.True(t, consoleFilter{query: "content=hello"}.match(entry))
+	assert.True(t, consoleFilter{query: "(ollama"}.match(entry))
+	assert.False(t, consoleFilter{query: "goodbye"}.match(entry))
+}
@@ -0,0 +1,345 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bz888/blab/pkg/blabclient"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const modelBrowserHelp = "[gray]Enter use, / filter, p pull, x delete, r refresh, Esc close[-]"
+
+// modelBrowser lists the models of every provider with their details, and
+// pulls and deletes Ollama models. It is only used on the UI goroutine.
+type modelBrowser struct {
+	currentModel *string
+	mainFlex     *tview.Flex
+
+	pages  *tview.Pages
+	layout *tview.Flex
+	filter *tview.InputField
+	table  *tview.Table
+	status *tview.TextView
+	pull   *tview.InputField
+
+	models  []blabclient.Model
+	shown   []blabclient.Model // the filtered models, in table order
+	pulling bool
+}
+
+// openModelBrowser fetches the models and shows the browser over mainFlex.
+func openModelBrowser(currentModel *string, mainFlex *tview.Flex) {
+	go func() {
+		models, err := apiClient.ModelDetails(context.Background())
+		app.QueueUpdateDraw(func() {
+			if err != nil {
+				localLogger.Error("Failed to list models", "err", err)
+				fmt.Fprintf(textView, "\nFailed to list models: %s\n", err)
+				textArea.SetDisabled(false)
+				return
+			}
+			newModelBrowser(currentModel, mainFlex, models).show()
+		})
+	}()
+}
+
+func newModelBrowser(currentModel *string, mainFlex *tview.Flex, models []blabclient.Model) *modelBrowser {
+	b := &modelBrowser{currentModel: currentModel, mainFlex: mainFlex, models: models}
+
+	b.table = tview.NewTable().
+		SetSelectable(true, false).
+		SetFixed(1, 0).
+		SetSelectedFunc(func(row, _ int) {
+			if model, ok := b.selected(row); ok {
+				b.use(model)
+			}
+		})
+	b.table.SetInputCapture(b.handleKey)
+
+	b.filter = tview.NewInputField().
+		SetLabel("Filter: ").
+		SetFieldBackgroundColor(tcell.ColorDefault).
+		SetChangedFunc(func(string) { b.render() }).
+		SetDoneFunc(func(tcell.Key) { app.SetFocus(b.table) })
+
+	b.pull = tview.NewInputField().
+		SetLabel("Pull model: ").
+		SetFieldBackgroundColor(tcell.ColorDefault).
+		SetDoneFunc(func(key tcell.Key) {
+			name := strings.TrimSpace(b.pull.GetText())
+			b.layout.RemoveItem(b.pull)
+			b.layout.AddItem(b.status, 1, 0, false)
+			app.SetFocus(b.table)
+			if key == tcell.KeyEnter && name != "" {
+				b.startPull(name)
+			}
+		})
+
+	b.status = tview.NewTextView().SetDynamicColors(true).SetText(modelBrowserHelp)
+
+	b.layout = tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(b.filter, 1, 0, false).
+		AddItem(b.table, 0, 1, true).
+		AddItem(b.status, 1, 0, false)
+	b.layout.SetBorder(true).SetTitle("Models")
+
+	b.render()
+	return b
+}
+
+func (b *modelBrowser) show() {
+	b.pages = tview.NewPages().
+		AddPage("main", b.mainFlex, true, true).
+		AddPage("modelModal", createModal(b.layout, 110, 24), true, true)
+	app.SetRoot(b.pages, true).SetFocus(b.table)
+}
+
+func (b *modelBrowser) close() {
+	b.pages.RemovePage("modelModal")
+	app.SetRoot(b.mainFlex, true).SetFocus(textArea)
+	textArea.SetDisabled(false)
+}
+
+func (b *modelBrowser) handleKey(event *tcell.EventKey) *tcell.EventKey {
+	switch event.Key() {
+	case tcell.KeyESC:
+		b.close()
+		return nil
+	case tcell.KeyDelete:
+		b.confirmDelete()
+		return nil
+	}
+	switch event.Rune() {
+	case 'q':
+		b.close()
+	case '/':
+		app.SetFocus(b.filter)
+	case 'p':
+		b.promptPull()
+	case 'x':
+		b.confirmDelete()
+	case 'r':
+		b.refresh(apiClient.RefreshModels)
+	default:
+		return event
+	}
+	return nil
+}
+
+// render fills the table with the models matching the filter, keeping the
+// selected model selected when it is still shown.
+func (b *modelBrowser) render() {
+	previous, hadSelection := b.selected(b.rowSelected())
+
+	b.table.Clear()
+	for column, title := range []string{"", "Name", "Provider", "Family", "Params", "Quant", "Size", "Modified"} {
+		b.table.SetCell(0, column, tview.NewTableCell(title).
+			SetTextColor(tcell.ColorYellow).
+			SetSelectable(false))
+	}
+
+	// A bare current model name only marks the model when a single provider
+	// offers it, as the server would then chat with that one.
+	ids := make([]string, len(b.models))
+	for i, model := range b.models {
+		ids[i] = model.ID
+	}
+	current, _ := findModel(ids, *b.currentModel)
+
+	query := strings.ToLower(strings.TrimSpace(b.filter.GetText()))
+	b.shown = b.shown[:0]
+	selectRow := 1
+	for _, model := range b.models {
+		if !matchModel(model, query) {
+			continue
+		}
+		b.shown = append(b.shown, model)
+		row := len(b.shown)
+		if hadSelection && model.Name == previous.Name && model.Provider == previous.Provider {
+			selectRow = row
+		}
+
+		marker := ""
+		if model.ID == current {
+			marker = "*"
+		}
+		modified := ""
+		if !model.ModifiedAt.IsZero() {
+			modified = model.ModifiedAt.Format("2006-01-02")
+		}
+		for column, text := range []string{marker, model.Name, model.Provider, model.Family, model.ParameterSize, model.Quantization, formatSize(model.Size), modified} {
+			cell := tview.NewTableCell(tview.Escape(text))
+			if column == 1 {
+				cell.SetExpansion(1)
+			}
+			b.table.SetCell(row, column, cell)
+		}
+	}
+	b.table.Select(selectRow, 0)
+}
+
+func (b *modelBrowser) rowSelected() int {
+	row, _ := b.table.GetSelection()
+	return row
+}
+
+// selected is the model shown in a table row.
+func (b *modelBrowser) selected(row int) (blabclient.Model, bool) {
+	if row < 1 || row > len(b.shown) {
+		return blabclient.Model{}, false
+	}
+	return b.shown[row-1], true
+}
+
+func (b *modelBrowser) use(model blabclient.Model) {
+	if model.ID == *b.currentModel {
+		localLogger.Info("This model is currently in use", "model", model.ID)
+		fmt.Fprintf(textView, "\nAlready using model: %s\n\n", model.ID)
+	} else {
+		localLogger.Info("Selected model", "model", model.ID)
+		*b.currentModel = model.ID
+		fmt.Fprintf(textView, "\nUsing Model: %s\n\n", model.ID)
+	}
+	b.close()
+}
+
+func (b *modelBrowser) setStatus(format string, args ...any) {
+	b.status.SetText(fmt.Sprintf(format, args...))
+}
+
+// refresh reloads the models with list, either apiClient.ModelDetails or
+// apiClient.RefreshModels to skip the server's cache.
+func (b *modelBrowser) refresh(list func(context.Context) ([]blabclient.Model, error)) {
+	go func() {
+		models, err := list(context.Background())
+		app.QueueUpdateDraw(func() {
+			if err != nil {
+				b.setStatus("[red]Failed to list models: %s[-]", tview.Escape(err.Error()))
+				return
+			}
+			b.models = models
+			b.render()
+		})
+	}()
+}
+
+func (b *modelBrowser) promptPull() {
+	if b.pulling {
+		return
+	}
+	b.pull.SetText("")
+	b.layout.RemoveItem(b.status)
+	b.layout.AddItem(b.pull, 1, 0, true)
+	app.SetFocus(b.pull)
+}
+
+func (b *modelBrowser) startPull(name string) {
+	b.pulling = true
+	b.setStatus("[yellow]Pulling %s...[-]", tview.Escape(name))
+	localLogger.Info("Pulling model", "model", name)
+
+	go func() {
+		err := apiClient.PullModel(context.Background(), name, func(progress blabclient.PullProgress) {
+			app.QueueUpdateDraw(func() {
+				b.setStatus("[yellow]%s: %s[-] %s", tview.Escape(name), tview.Escape(progress.Status), pullProgress(progress))
+			})
+		})
+		app.QueueUpdateDraw(func() {
+			b.pulling = false
+			if err != nil {
+				localLogger.Error("Failed to pull model", "model", name, "err", err)
+				b.setStatus("[red]Failed to pull %s: %s[-]", tview.Escape(name), tview.Escape(err.Error()))
+				return
+			}
+			b.setStatus("[green]Pulled %s[-]  %s", tview.Escape(name), modelBrowserHelp)
+			b.refresh(apiClient.ModelDetails)
+		})
+	}()
+}
+
+func (b *modelBrowser) confirmDelete() {
+	model, ok := b.selected(b.rowSelected())
+	if !ok {
+		return
+	}
+	if model.Provider != "ollama" {
+		b.setStatus("[red]Only local Ollama models can be deleted[-]  %s", modelBrowserHelp)
+		return
+	}
+
+	modal := tview.NewModal().
+		SetText(fmt.Sprintf("Delete %s from Ollama?", model.Name)).
+		AddButtons([]string{"Delete", "Cancel"}).
+		SetDoneFunc(func(_ int, label string) {
+			b.pages.RemovePage("confirmDelete")
+			app.SetFocus(b.table)
+			if label == "Delete" {
+				b.delete(model.ID)
+			}
+		})
+	b.pages.AddPage("confirmDelete", modal, true, true)
+	app.SetFocus(modal)
+}
+
+func (b *modelBrowser) delete(name string) {
+	go func() {
+		err := apiClient.DeleteModel(context.Background(), name)
+		app.QueueUpdateDraw(func() {
+			if err != nil {
+				localLogger.Error("Failed to delete model", "model", name, "err", err)
+				b.setStatus("[red]Failed to delete %s: %s[-]", tview.Escape(name), tview.Escape(err.Error()))
+				return
+			}
+			localLogger.Info("Deleted model", "model", name)
+			b.setStatus("[green]Deleted %s[-]  %s", tview.Escape(name), modelBrowserHelp)
+			b.refresh(apiClient.ModelDetails)
+		})
+	}()
+}
+
+func matchModel(model blabclient.Model, query string) bool {
+	if query == "" {
+		return true
+	}
+	for _, field := range []string{model.Name, model.Provider, model.Family, model.ParameterSize, model.Quantization} {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// pullProgress is a progress bar for the layer being downloaded, or nothing
+// while Ollama reports a step without a size.
+func pullProgress(progress blabclient.PullProgress) string {
+	if progress.Total <= 0 {
+		return ""
+	}
+	const width = 20
+	done := min(int(progress.Completed*width/progress.Total), width)
+	return fmt.Sprintf("[%s%s[] %3d%% %s / %s",
+		strings.Repeat("#", done), strings.Repeat("-", width-done),
+		progress.Completed*100/progress.Total, formatSize(progress.Completed), formatSize(progress.Total))
+}
+
+// formatSize renders a byte count the way Ollama does, in decimal units.
+func formatSize(size int64) string {
+	if size <= 0 {
+		return ""
+	}
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value := float64(size)
+	unit := 0
+	for value >= 1000 && unit < len(units)-1 {
+		value /= 1000
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
@@ -0,0 +1,48 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/bz888/blab/pkg/blabclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatSize(t *testing.T) {
+	assert.Equal(t, "", formatSize(0))
+	assert.Equal(t, "512 B", formatSize(512))
+	assert.Equal(t, "4.7 GB", formatSize(4_661_224_676))
+	assert.Equal(t, "1.5 MB", formatSize(1_500_000))
+}
+
+func TestPullProgress(t *testing.T) {
+	assert.Equal(t, "", pullProgress(blabclient.PullProgress{Status: "pulling manifest"}))
+	assert.Equal(t, "[#########-----------[]  45% 1.2 GB / 2.6 GB",
+		pullProgress(blabclient.PullProgress{Completed: 1_170_000_000, Total: 2_600_000_000}))
+	assert.Equal(t, "[####################[] 100% 2.6 GB / 2.6 GB",
+		pullProgress(blabclient.PullProgress{Completed: 2_600_000_000, Total: 2_600_000_000}))
+}
+
+func TestFindModel(t *testing.T) {
+	ids := []string{"ollama/llama3:latest", "ollama/shared", "openai/shared", "openai/gpt-4o"}
+
+	id, ok := findModel(ids, "openai/shared")
+	assert.True(t, ok)
+	assert.Equal(t, "openai/shared", id)
+
+	id, ok = findModel(ids, "llama3:latest")
+	assert.True(t, ok)
+	assert.Equal(t, "ollama/llama3:latest", id)
+
+	_, ok = findModel(ids, "shared")
+	assert.False(t, ok, "bare names offered by several providers are ambiguous")
+	_, ok = findModel(ids, "mistral")
+	assert.False(t, ok)
+}
+
+func TestMatchModel(t *testing.T) {
+	model := blabclient.Model{Name: "llama3:latest", Provider: "ollama", Family: "llama", ParameterSize: "8.0B", Quantization: "Q4_0"}
+	assert.True(t, matchModel(model, ""))
+	assert.True(t, matchModel(model, "ollama"))
+	assert.True(t, matchModel(model, "q4"))
+	assert.False(t, matchModel(model, "gpt"))
+}
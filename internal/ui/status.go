@@ -0,0 +1,150 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bz888/blab/pkg/blabclient"
+	"github.com/rivo/tview"
+)
+
+// statusPoll is how often the status bar asks the server about the providers.
+const statusPoll = 5 * time.Second
+
+// noProviderHelp explains how to bring a model provider online.
+const noProviderHelp = "Start Ollama with `ollama serve`, or add an OpenAI key with /provider add openai <key>."
+
+// statusBar shows whether each model provider is reachable, so it is obvious
+// when one of them stops.
+type statusBar struct {
+	*tview.TextView
+	// polled is set once the server has answered, to tell it still starting
+	// from it having gone away.
+	polled bool
+	// hinted is set once the chat explains that no provider is reachable,
+	// until one is.
+	hinted bool
+}
+
+func newStatusBar() *statusBar {
+	view := tview.NewTextView().
+		SetDynamicColors(true).
+		SetText("[gray]Checking providers...[-]")
+	return &statusBar{TextView: view}
+}
+
+// run polls the server for the provider status until the app exits.
+func (s *statusBar) run() {
+	ticker := time.NewTicker(statusPoll)
+	defer ticker.Stop()
+	for {
+		statuses, err := apiClient.Providers(context.Background())
+		app.QueueUpdateDraw(func() {
+			s.update(statuses, err)
+		})
+		<-ticker.C
+	}
+}
+
+// update shows the statuses, or err when the server could not be asked.
+func (s *statusBar) update(statuses []blabclient.ProviderStatus, err error) {
+	if err != nil && !s.polled {
+		return
+	}
+	s.polled = true
+	s.SetText(formatStatus(statuses, err))
+	if err != nil {
+		return
+	}
+
+	for _, status := range statuses {
+		if status.Reachable {
+			s.hinted = false
+			return
+		}
+	}
+	if !s.hinted {
+		s.hinted = true
+		fmt.Fprintf(textView, "\n[yellow]No model provider is reachable.[-] %s\n", noProviderHelp)
+	}
+}
+
+// providerCommand lists the providers, or adds one: "add openai <key>" sets
+// the OpenAI API key and "add ollama" checks Ollama now.
+func providerCommand(args string) {
+	fields := strings.Fields(args)
+	switch {
+	case len(fields) == 0:
+		go func() {
+			statuses, err := apiClient.Providers(context.Background())
+			app.QueueUpdateDraw(func() {
+				textArea.SetDisabled(false)
+				if err != nil {
+					fmt.Fprintf(textView, "\nFailed to list providers: %s\n", err)
+					return
+				}
+				fmt.Fprintf(textView, "\n")
+				for _, status := range statuses {
+					fmt.Fprintf(textView, "%s\n", formatStatus([]blabclient.ProviderStatus{status}, nil))
+				}
+			})
+		}()
+	case fields[0] == "add" && (len(fields) == 2 || len(fields) == 3):
+		provider, apiKey := fields[1], ""
+		if len(fields) == 3 {
+			apiKey = fields[2]
+		}
+		fmt.Fprintf(textView, "\nChecking %s...\n", provider)
+		go func() {
+			status, err := apiClient.AddProvider(context.Background(), provider, apiKey)
+			var statuses []blabclient.ProviderStatus
+			if err == nil {
+				// Shown on the status bar without waiting for the next poll.
+				statuses, _ = apiClient.Providers(context.Background())
+			}
+			app.QueueUpdateDraw(func() {
+				textArea.SetDisabled(false)
+				if err != nil {
+					localLogger.Error("Failed to add provider", "provider", provider, "err", err)
+					fmt.Fprintf(textView, "Failed to add %s: %s\n", provider, err)
+					return
+				}
+				if statuses != nil {
+					statusLine.update(statuses, nil)
+				}
+				localLogger.Info("Provider added", "provider", status.Provider)
+				fmt.Fprintf(textView, "%s is ready, its models are listed under /models\n", status.Provider)
+			})
+		}()
+	default:
+		fmt.Fprintf(textView, "\nUsage: /provider [add openai <key> | add ollama]\n")
+		textArea.SetDisabled(false)
+	}
+}
+
+func formatStatus(statuses []blabclient.ProviderStatus, err error) string {
+	if err != nil {
+		return fmt.Sprintf("[red]● server unreachable[-] [gray]%s[-]", tview.Escape(err.Error()))
+	}
+	if len(statuses) == 0 {
+		return "[yellow]● no providers configured[-]"
+	}
+	parts := make([]string, len(statuses))
+	for i, status := range statuses {
+		if status.Reachable {
+			parts[i] = fmt.Sprintf("[green]●[-] %s [gray]%s[-]", status.Provider, status.Latency.Round(time.Millisecond))
+			continue
+		}
+		part := fmt.Sprintf("[red]● %s down[-]", status.Provider)
+		if !status.Since.IsZero() {
+			part += fmt.Sprintf(" [gray]since %s[-]", status.Since.Format(time.TimeOnly))
+		}
+		if status.Error != "" {
+			part += fmt.Sprintf(" [gray]%s[-]", tview.Escape(status.Error))
+		}
+		parts[i] = part
+	}
+	return strings.Join(parts, "  ")
+}
@@ -0,0 +1,50 @@
+package ui
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bz888/blab/pkg/blabclient"
+	"github.com/rivo/tview"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatStatus(t *testing.T) {
+	since := time.Date(2024, 5, 1, 10, 4, 5, 0, time.UTC)
+	text := formatStatus([]blabclient.ProviderStatus{
+		{Provider: "ollama", Error: "connection refused", Since: since},
+		{Provider: "openai", Reachable: true, Latency: 123456789},
+	}, nil)
+	assert.Equal(t, "[red]● ollama down[-] [gray]since 10:04:05[-] [gray]connection refused[-]  [green]●[-] openai [gray]123ms[-]", text)
+
+	assert.Equal(t, "[yellow]● no providers configured[-]", formatStatus(nil, nil))
+	assert.Contains(t, formatStatus(nil, errors.New("connection refused")), "server unreachable")
+}
+
+func TestStatusBarWaitsForServer(t *testing.T) {
+	bar := newStatusBar()
+	bar.update(nil, errors.New("connection refused"))
+	assert.Contains(t, bar.GetText(false), "Checking providers", "the server may still be starting")
+
+	bar.update([]blabclient.ProviderStatus{{Provider: "ollama", Reachable: true}}, nil)
+	bar.update(nil, errors.New("connection refused"))
+	assert.Contains(t, bar.GetText(false), "server unreachable")
+}
+
+func TestStatusBarExplainsMissingProviders(t *testing.T) {
+	previous := textView
+	textView = tview.NewTextView()
+	t.Cleanup(func() { textView = previous })
+
+	bar := newStatusBar()
+	down := []blabclient.ProviderStatus{{Provider: "ollama", Error: "connection refused"}}
+	bar.update(down, nil)
+	bar.update(down, nil)
+	assert.Equal(t, 1, strings.Count(textView.GetText(false), "No model provider is reachable"), "explained once while down")
+
+	bar.update([]blabclient.ProviderStatus{{Provider: "ollama", Reachable: true}}, nil)
+	bar.update(down, nil)
+	assert.Equal(t, 2, strings.Count(textView.GetText(false), "No model provider is reachable"), "explained again after going down")
+}
@@ -0,0 +1,128 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bz888/blab/internal/config"
+	"github.com/bz888/blab/internal/templates"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const templateUsage = "Usage: /t [<name> [key=value ...]]"
+
+// templateStore holds the prompt templates, under the config directory.
+var templateStore *templates.Store
+
+func initTemplates() {
+	dir, err := config.Dir()
+	if err != nil {
+		localLogger.Warn("Prompt templates are disabled", "err", err)
+		return
+	}
+	templateStore = templates.NewStore(filepath.Join(dir, "templates"))
+}
+
+// templateCommand expands a template into the chat input, so it can be read
+// and edited before it is sent, or opens the picker when no name is given.
+func templateCommand(args string, mainFlex *tview.Flex) {
+	if templateStore == nil {
+		fmt.Fprintf(textView, "\nPrompt templates need a config directory, set one with -configDir\n")
+		textArea.SetDisabled(false)
+		return
+	}
+	if strings.TrimSpace(args) == "" {
+		pickTemplate(mainFlex)
+		return
+	}
+
+	textArea.SetDisabled(false)
+	name, vars, err := templates.ParseArgs(args)
+	if err != nil {
+		fmt.Fprintf(textView, "\n%s\n%s\n", tview.Escape(err.Error()), templateUsage)
+		return
+	}
+	text, err := templateStore.Expand(name, vars)
+	if err != nil {
+		localLogger.Warn("Failed to expand template", "template", name, "err", err)
+		fmt.Fprintf(textView, "\n%s\n", tview.Escape(err.Error()))
+		return
+	}
+	textArea.SetText(text, true)
+}
+
+// pickTemplate lists the templates to choose from. One without variables is
+// expanded into the input at once; otherwise its /t command is, for the
+// values to be filled in.
+func pickTemplate(mainFlex *tview.Flex) {
+	list, err := templateStore.List()
+	if err != nil {
+		localLogger.Error("Failed to list templates", "dir", templateStore.Dir(), "err", err)
+		fmt.Fprintf(textView, "\nFailed to list templates: %s\n", err)
+		textArea.SetDisabled(false)
+		return
+	}
+	if len(list) == 0 {
+		fmt.Fprintf(textView, "\nNo templates yet. Add prompts as <name>%s files to %s, with {{.Var}} placeholders\n",
+			templates.Ext, templateStore.Dir())
+		textArea.SetDisabled(false)
+		return
+	}
+
+	var pages *tview.Pages
+	closeModal := func() {
+		pages.RemovePage("templatesModal")
+		app.SetRoot(mainFlex, true).SetFocus(textArea)
+		textArea.SetDisabled(false)
+	}
+
+	picker := tview.NewList()
+	picker.SetBorder(true).SetTitle("Templates (Esc to close)")
+	for _, t := range list {
+		picker.AddItem(tview.Escape(t.Name), tview.Escape(templateSummary(t)), 0, func() {
+			closeModal()
+			if len(t.Variables) > 0 {
+				textArea.SetText(templateCommandLine(t), true)
+				return
+			}
+			templateCommand(t.Name, mainFlex)
+		})
+	}
+	picker.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyESC {
+			closeModal()
+			return nil
+		}
+		return event
+	})
+
+	height := min(2*picker.GetItemCount()+2, 20)
+	pages = tview.NewPages().
+		AddPage("main", mainFlex, true, true).
+		AddPage("templatesModal", createModal(picker, 70, height), true, true)
+	app.SetRoot(pages, true).SetFocus(picker)
+}
+
+// templateSummary is the line under a template's name in the picker.
+func templateSummary(t templates.Template) string {
+	summary := t.Description
+	if len(t.Variables) > 0 {
+		if summary != "" {
+			summary += " "
+		}
+		summary += "(" + strings.Join(t.Variables, ", ") + ")"
+	}
+	return summary
+}
+
+// templateCommandLine is the /t command for a template, with its variables
+// left to fill in.
+func templateCommandLine(t templates.Template) string {
+	line := "/t " + t.Name
+	for _, name := range t.Variables {
+		line += " " + name + "="
+	}
+	return line
+}
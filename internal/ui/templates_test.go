@@ -0,0 +1,18 @@
+package ui
+
+import (
+	"testing"
+
+	"github.com/bz888/blab/internal/templates"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplatePicker(t *testing.T) {
+	review := templates.Template{Name: "review", Description: "Review a diff", Variables: []string{"Lang", "Diff"}}
+	assert.Equal(t, "Review a diff (Lang, Diff)", templateSummary(review))
+	assert.Equal(t, "/t review Lang= Diff=", templateCommandLine(review))
+
+	standup := templates.Template{Name: "standup"}
+	assert.Equal(t, "", templateSummary(standup))
+	assert.Equal(t, "/t standup", templateCommandLine(standup))
+}
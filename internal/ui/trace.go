@@ -0,0 +1,105 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bz888/blab/pkg/blabclient"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// showLastTrace opens the most recent provider request recorded with -trace.
+func showLastTrace(mainFlex *tview.Flex) {
+	go func() {
+		exchange, err := apiClient.LastTrace(context.Background())
+		app.QueueUpdateDraw(func() {
+			textArea.SetDisabled(false)
+			if err != nil {
+				fmt.Fprintf(textView, "\nNo trace to show: %s\n", err)
+				return
+			}
+			showTrace(exchange, mainFlex)
+		})
+	}()
+}
+
+func showTrace(exchange blabclient.Trace, mainFlex *tview.Flex) {
+	var pages *tview.Pages
+	view := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWordWrap(true).
+		SetText(formatTrace(exchange))
+	view.SetBorder(true).SetTitle(fmt.Sprintf("Trace #%d (Esc to close)", exchange.ID))
+	view.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyESC || event.Rune() == 'q' {
+			pages.RemovePage("traceModal")
+			app.SetRoot(mainFlex, true).SetFocus(textArea)
+			return nil
+		}
+		return event
+	})
+
+	pages = tview.NewPages().
+		AddPage("main", mainFlex, true, true).
+		AddPage("traceModal", createModal(view, 100, 30), true, true)
+	app.SetRoot(pages, true).SetFocus(view)
+}
+
+func formatTrace(e blabclient.Trace) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[::b]%s %s[::-]\n", e.Method, tview.Escape(e.URL))
+	fmt.Fprintf(&b, "%s, %d chunks, headers after %s, done after %s\n",
+		e.Start.Format(time.DateTime), len(e.Chunks), e.Headers.Round(time.Millisecond), e.Duration.Round(time.Millisecond))
+	if e.Status != "" {
+		fmt.Fprintf(&b, "Status: %s\n", e.Status)
+	}
+	if e.Err != "" {
+		fmt.Fprintf(&b, "[red]Error: %s[-]\n", tview.Escape(e.Err))
+	}
+
+	b.WriteString("\n[yellow]Request headers[-]\n")
+	writeHeader(&b, e.RequestHeader)
+	if e.RequestBody != "" {
+		b.WriteString("\n[yellow]Request body[-]\n")
+		b.WriteString(tview.Escape(indentJSON(e.RequestBody)))
+		b.WriteString("\n")
+	}
+	if e.ResponseHeader != nil {
+		b.WriteString("\n[yellow]Response headers[-]\n")
+		writeHeader(&b, e.ResponseHeader)
+	}
+	if len(e.Chunks) > 0 {
+		b.WriteString("\n[yellow]Response chunks[-]\n")
+		for _, chunk := range e.Chunks {
+			fmt.Fprintf(&b, "[gray]+%s[-] %s\n", chunk.At.Round(time.Millisecond), tview.Escape(strings.TrimRight(chunk.Data, "\n")))
+		}
+	}
+	return b.String()
+}
+
+func writeHeader(b *strings.Builder, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(b, "  %s: %s\n", name, tview.Escape(strings.Join(header[name], ", ")))
+	}
+}
+
+// indentJSON pretty-prints a JSON body, or returns it unchanged.
+func indentJSON(body string) string {
+	var out bytes.Buffer
+	if err := json.Indent(&out, []byte(body), "", "  "); err != nil {
+		return body
+	}
+	return out.String()
+}
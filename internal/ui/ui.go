@@ -1,26 +1,38 @@
 package ui
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"github.com/bz888/blab/internal/api"
 	"github.com/bz888/blab/internal/config"
 	"github.com/bz888/blab/internal/logger"
 	"github.com/bz888/blab/internal/speech"
+	"github.com/bz888/blab/internal/speech/pipeline"
+	"github.com/bz888/blab/internal/speech/recorder"
+	"github.com/bz888/blab/pkg/blabclient"
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 	"log"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var app *tview.Application
-var defaultModel = "llama3:latest" // default
 var wg sync.WaitGroup
 
+// listening is set while /voice is recording.
+var listening atomic.Bool
+
+// consoleShown is set while the debug console is part of the layout.
+var consoleShown bool
+
 var (
-	debugConsole *tview.TextView
+	apiClient    *blabclient.Client
+	debugConsole *DebugConsole
+	statusLine   *statusBar
 	textView     *tview.TextView
 	textArea     *tview.TextArea
 	localLogger  *logger.Logger
@@ -31,10 +43,11 @@ func Init() {
 	app.EnablePaste(true)
 	app.EnableMouse(true)
 
-	debugConsole = initDebugConsole()
+	debugConsole = newDebugConsole(app, config.ConsoleLines)
 
 	textView = initChatViewer()
 	textArea = initChatInput()
+	statusLine = newStatusBar()
 }
 
 func initChatViewer() *tview.TextView {
@@ -59,24 +72,14 @@ func initChatInput() *tview.TextArea {
 	return textArea
 }
 
-func initDebugConsole() *tview.TextView {
-	console := tview.NewTextView().
-		SetChangedFunc(func() {
-			app.Draw()
-		}).
-		SetDynamicColors(true).
-		SetRegions(true).
-		SetWordWrap(true)
-
-	console.SetTitle("Debugger").SetBorder(true)
-	console.ScrollToEnd()
-	return console
-}
-
 // Run InitUi logPath and dev should be set to a ()
-func Run() {
+// client talks to the embedded server.
+func Run(client *blabclient.Client) {
 	localLogger = logger.NewLogger("views")
-	currentModel := &defaultModel
+	apiClient = client
+	initTemplates()
+	model := config.Model
+	currentModel := &model
 
 	textView.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		switch event.Key() {
@@ -89,16 +92,19 @@ func Run() {
 	subFlex := tview.NewFlex().
 		SetDirection(tview.FlexRow).
 		AddItem(textView, 0, 1, false).
-		AddItem(textArea, 8, 2, true)
+		AddItem(textArea, 8, 2, true).
+		AddItem(statusLine, 1, 0, false)
 	mainFlex := tview.NewFlex().
 		AddItem(subFlex, 0, 2, false)
 
 	if config.Dev {
 		mainFlex.AddItem(debugConsole, 0, 1, true)
+		consoleShown = true
 	}
 
 	// setup input capture logic
 	setInputCapture(mainFlex, currentModel)
+	go statusLine.run()
 
 	if err := app.SetRoot(mainFlex, true).SetFocus(textArea).Run(); err != nil {
 		panic(err)
@@ -110,6 +116,14 @@ func setInputCapture(mainFlex *tview.Flex, currentModel *string) {
 
 		switch event.Key() {
 		case tcell.KeyESC:
+			if listening.Load() {
+				// Stopping waits for a transcription in flight to give up,
+				// which must not block the UI. The voice goroutine reports
+				// the cancellation.
+				fmt.Fprintf(textView, "\nStopping voice input...\n")
+				go speech.Stop()
+				return nil
+			}
 			if textView.GetText(false) != "" {
 				app.SetFocus(textView)
 			}
@@ -121,6 +135,41 @@ func setInputCapture(mainFlex *tview.Flex, currentModel *string) {
 			textArea.SetText("", true)
 			textArea.SetDisabled(true)
 
+			// Commands taking arguments are matched on their first word, so
+			// "/tracer" is not "/trace".
+			command, args, _ := strings.Cut(strings.TrimSpace(content), " ")
+			args = strings.TrimSpace(args)
+			switch {
+			case command == "/transcribe":
+				transcribeFile(args)
+				return event
+			case command == "/debug" && args != "":
+				debugCommand(args)
+				textArea.SetDisabled(false)
+				return event
+			case command == "/trace":
+				if args != "last" {
+					fmt.Fprintf(textView, "\nUsage: /trace last\n")
+					textArea.SetDisabled(false)
+					return event
+				}
+				showLastTrace(mainFlex)
+				return event
+			case command == "/provider":
+				providerCommand(args)
+				return event
+			case command == "/session":
+				sessionCommand(args)
+				return event
+			case command == "/t":
+				templateCommand(args, mainFlex)
+				return event
+			case command == "/lang":
+				setLanguage(args)
+				textArea.SetDisabled(false)
+				return event
+			}
+
 			switch strings.TrimSpace(content) {
 
 			// todo refactor into a const object of all commands and followed by the running function
@@ -136,28 +185,31 @@ func setInputCapture(mainFlex *tview.Flex, currentModel *string) {
 				textArea.SetDisabled(false)
 				return event
 			case "/voice":
-				voiceRecognition(*currentModel)
+				voiceRecognition(*currentModel, mainFlex)
 				return event
 			case "/models":
-				go func() {
-					createModelModal(currentModel, mainFlex)
-					textArea.SetDisabled(false)
-				}()
+				openModelBrowser(currentModel, mainFlex)
 				return event
 			}
 
 			go func() {
-				models, err := api.ListModels()
-				if err != nil {
-					localLogger.Error("Failed to list models")
-					//return
+				models, err := apiClient.ListModels(context.Background())
+				if err != nil || len(models) == 0 {
+					localLogger.Error("Failed to list models", "err", err)
+					app.QueueUpdateDraw(func() {
+						fmt.Fprintf(textView, "\nNo models available to chat with. %s\n", noProviderHelp)
+						textArea.SetDisabled(false)
+					})
+					return
 				}
-				if !contains(models, *currentModel) {
-					currentModel = &models[0]
-					localLogger.Warn("Selected model "+defaultModel+"not found, switching to default model: ", currentModel)
+				if id, ok := findModel(models, *currentModel); ok {
+					*currentModel = id
+				} else {
+					localLogger.Warn("Selected model not found, switching to default model", "model", *currentModel, "default", models[0])
+					*currentModel = models[0]
 				}
 
-				api.Chatting(*currentModel, content, app, textView)
+				chat(*currentModel, content, "")
 				textArea.SetDisabled(false)
 			}()
 		}
@@ -165,7 +217,7 @@ func setInputCapture(mainFlex *tview.Flex, currentModel *string) {
 	})
 }
 
-func voiceRecognition(currentModel string) {
+func voiceRecognition(currentModel string, mainFlex *tview.Flex) {
 
 	if os.Getenv("GOOGLE_API_KEY") == "" {
 		fmt.Fprintf(textView, "\nGOOGLE_API_KEY is required to enable voice recognistion\n")
@@ -175,25 +227,89 @@ func voiceRecognition(currentModel string) {
 	}
 
 	localLogger.Info("Voice recogniser Started")
-	var voiceContent string
-	var err error
-	wg.Add(1)
+	fmt.Fprintf(textView, "\nListening... (Esc to cancel)\n")
+	listening.Store(true)
 	go func() {
-		defer wg.Done()
-		voiceContent, err = speech.Run()
+		transcription, err := speech.Run(context.Background())
+		listening.Store(false)
+		if errors.Is(err, recorder.ErrStopped) {
+			app.QueueUpdateDraw(func() {
+				fmt.Fprintf(textView, "\nVoice input cancelled\n")
+				textArea.SetDisabled(false)
+			})
+			return
+		}
 		if err != nil {
-			localLogger.Error("Failed to process voice")
+			localLogger.Error("Failed to process voice", "err", err)
+			app.QueueUpdateDraw(func() {
+				fmt.Fprintf(textView, "\nVoice recognition failed: %s\n", err)
+				textArea.SetDisabled(false)
+			})
+			return
 		}
-		app.Draw()
+
+		app.QueueUpdateDraw(func() {
+			showTranscription(transcription)
+		})
+
+		// Doubtful transcripts are confirmed before they reach the model.
+		if transcription.NeedsReview() {
+			app.QueueUpdateDraw(func() {
+				pickAlternative(transcription, mainFlex, func(alternative pipeline.Alternative) {
+					go sendVoice(currentModel, alternative.Text, alternative.Language)
+				})
+			})
+			return
+		}
+		sendVoice(currentModel, transcription.Text, transcription.Language)
 	}()
+}
 
+func sendVoice(currentModel, text, language string) {
 	localLogger.Info("Voice to api")
-	go func() {
-		app.Draw()
-		wg.Wait()
-		api.Chatting(currentModel, voiceContent, app, textView)
-		localLogger.Info("Voice recognizer Completed")
+	if !config.LangPrompt {
+		language = ""
+	}
+	chat(currentModel, text, language)
+	localLogger.Info("Voice recognizer Completed")
+	textArea.SetDisabled(false)
+}
+
+// setLanguage changes the speech recognition language, or shows it when
+// spec is empty.
+func setLanguage(spec string) {
+	if spec != "" {
+		if err := speech.SetLanguage(spec); err != nil {
+			fmt.Fprintf(textView, "\n%s\nUsage: /lang <tag> | /lang auto <tag> <tag> [tag]\n", err)
+			return
+		}
+		localLogger.Info("Recognition language changed", "language", speech.Language())
+	}
+	fmt.Fprintf(textView, "\nRecognition language: %s\n", speech.Language())
+}
+
+// transcribeFile runs a recorded WAV file through the speech pipeline and
+// leaves the text in the chat input so it can be edited before sending.
+func transcribeFile(path string) {
+	if path == "" {
+		fmt.Fprintf(textView, "\nUsage: /transcribe <file.wav>\n")
 		textArea.SetDisabled(false)
+		return
+	}
+
+	localLogger.Info("Transcribing file", "path", path)
+	go func() {
+		text, err := speech.TranscribeFile(path)
+		app.QueueUpdateDraw(func() {
+			if err != nil {
+				localLogger.Error("Failed to transcribe file", "path", path, "err", err)
+				fmt.Fprintf(textView, "\nFailed to transcribe %s: %s\n", path, err)
+			} else {
+				textArea.SetText(text, true)
+			}
+			textArea.SetDisabled(false)
+			app.SetFocus(textArea)
+		})
 	}()
 }
 
@@ -207,79 +323,65 @@ func createModal(p tview.Primitive, width, height int) tview.Primitive {
 		AddItem(nil, 0, 1, false)
 }
 
-func createModelModal(currentModel *string, mainFlex *tview.Flex) {
-	models, err := api.ListModels()
-	if err != nil {
-		localLogger.Error("Failed to list models")
-		return
-	}
-
-	var pages *tview.Pages
-	list := tview.NewList()
-	list.SetBorder(true)
-	for i, model := range models {
-		runeValue := '0' + rune(i)
-
-		if model == *currentModel {
-			list.AddItem(model, "Current LLM", runeValue, func() {
-				localLogger.Info("This model is currently in use", model)
-				fmt.Fprintf(textView, "\nAlready using model: %s\n\n", model)
-			})
-		} else {
-			list.AddItem(model, "LLM", runeValue, func() {
-				localLogger.Info("Selected: ", model)
-				*currentModel = model
-				fmt.Fprintf(textView, "\nUsing Model: %s\n\n", model)
-
-				pages.RemovePage("modelModal")
-				textArea.SetDisabled(false)
-				app.SetFocus(textArea)
-				return
-			})
-		}
-	}
-	modal := createModal(list, 40, 10)
-	list.
-		AddItem("Back", "", 'q', func() {
-			pages.RemovePage("modelModal")
-			textArea.SetDisabled(false)
-			app.SetFocus(textArea)
-			return
-		})
-
-	pages = tview.NewPages().
-		AddPage("main", mainFlex, true, true).
-		AddPage("modelModal", modal, true, true)
-
-	if err := app.SetRoot(pages, true).Run(); err != nil {
-		panic(err)
-	}
-	localLogger.Info("/models command executed and completed")
-}
-
 func toggleDebugConsole(mainFlex *tview.Flex) {
 	go func() {
-		// todo should be based on if the item is apart of the mainFlex
-		if !config.Dev {
-			app.QueueUpdateDraw(func() {
+		app.QueueUpdateDraw(func() {
+			if !consoleShown {
 				mainFlex.AddItem(debugConsole, 0, 1, true) // Adjust size as needed
 				fmt.Fprintf(textView, "\nDebug console enabled\n")
-			})
-		} else {
-			app.QueueUpdateDraw(func() {
+			} else {
 				mainFlex.RemoveItem(debugConsole)
 				fmt.Fprintf(textView, "\nDebug console disabled\n")
-			})
-		}
+			}
+			consoleShown = !consoleShown
+		})
 	}()
 }
 
+// debugCommand changes the debug console filters or saves its contents.
+func debugCommand(args string) {
+	command, arg, _ := strings.Cut(args, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch command {
+	case "level":
+		level, err := logger.ParseLevel(arg)
+		if err != nil {
+			fmt.Fprintf(textView, "\n%s\n", err)
+			return
+		}
+		debugConsole.SetLevel(level)
+	case "tags":
+		var tags []string
+		if arg != "" {
+			tags = strings.Split(arg, ",")
+		}
+		debugConsole.SetTags(tags)
+	case "pause":
+		debugConsole.SetPaused(!debugConsole.Paused())
+	case "dump":
+		path := arg
+		if path == "" {
+			path = fmt.Sprintf("blab_console_%s.log", time.Now().Format("20060102_150405"))
+		}
+		if err := debugConsole.Dump(path); err != nil {
+			localLogger.Error("Failed to dump debug console", "path", path, "err", err)
+			fmt.Fprintf(textView, "\nFailed to save debug console: %s\n", err)
+			return
+		}
+		fmt.Fprintf(textView, "\nDebug console saved to %s\n", path)
+	default:
+		fmt.Fprintf(textView, "\nUsage: /debug [level <level> | tags [tag, ...] | pause | dump [file]]\n")
+	}
+}
+
 func quitApp() {
 	fmt.Fprintf(textView, "Bye bye\n")
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
+		speech.Close()
 		localLogger.Close()
 		app.Stop()
 		log.Println("Shutting down gracefully.")
@@ -299,11 +401,18 @@ func listHelp(content string) {
 	fmt.Fprintf(textView, "- /help: Display this help message\n")
 	fmt.Fprintf(textView, "- /bye: Exit the application\n")
 	fmt.Fprintf(textView, "- /debug: Toggle the debug console\n")
-	fmt.Fprintf(textView, "- /voice: Activate voice input\n\n")
-	fmt.Fprintf(textView, "- /models: Select between local LLM\n\n")
+	fmt.Fprintf(textView, "- /debug level <level> | tags [tag, ...] | pause | dump [file]: Filter, pause or save the debug console\n")
+	fmt.Fprintf(textView, "- /voice: Activate voice input\n")
+	fmt.Fprintf(textView, "- /trace last: Show the last provider request and response recorded with -trace\n")
+	fmt.Fprintf(textView, "- /transcribe <file.wav>: Transcribe a recorded file into the input\n")
+	fmt.Fprintf(textView, "- /lang [tag | auto <tags...>]: Show or set the speech recognition language, auto tries each of 2-3 languages per utterance\n")
+	fmt.Fprintf(textView, "- /models: Browse, select, pull and delete models\n")
+	fmt.Fprintf(textView, "- /provider [add openai <key> | add ollama]: Show the providers, set the OpenAI key or check Ollama now\n")
+	fmt.Fprintf(textView, "- /t [<name> [key=value ...]]: Pick a prompt template, or fill one in and put it in the input\n")
+	fmt.Fprintf(textView, "- /session [new]: Show the conversation's session, or start a new conversation\n\n")
 }
 
-func GetDebugConsole() (*tview.TextView, error) {
+func GetDebugConsole() (*DebugConsole, error) {
 	if debugConsole == nil {
 		return nil, errors.New("debug console not initialized")
 	}
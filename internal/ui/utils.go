@@ -1,5 +1,7 @@
 package ui
 
+import "strings"
+
 func contains(slice []string, value string) bool {
 	for _, v := range slice {
 		if v == value {
@@ -8,3 +10,22 @@ func contains(slice []string, value string) bool {
 	}
 	return false
 }
+
+// findModel resolves want, a provider-qualified id such as "ollama/llama3" or
+// a bare model name, against the ids the server lists. A bare name only
+// resolves when a single provider offers it.
+func findModel(ids []string, want string) (string, bool) {
+	if contains(ids, want) {
+		return want, true
+	}
+	var found []string
+	for _, id := range ids {
+		if _, name, ok := strings.Cut(id, "/"); ok && name == want {
+			found = append(found, id)
+		}
+	}
+	if len(found) != 1 {
+		return "", false
+	}
+	return found[0], true
+}
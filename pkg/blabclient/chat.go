@@ -0,0 +1,94 @@
+package blabclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Event is one step of a streamed chat reply: Queued, Delta, Done or Failed.
+type Event interface {
+	event()
+}
+
+// Queued reports that the request waits for the provider, Position being 1
+// when it is next. It is sent again whenever the position changes.
+type Queued struct {
+	Position int
+}
+
+// Delta is the next piece of the reply.
+type Delta struct {
+	Text string
+}
+
+// Done ends a reply that completed; Reply is the whole of it.
+type Done struct {
+	Reply string
+}
+
+// Failed ends a reply that broke off.
+type Failed struct {
+	Err error
+}
+
+func (Queued) event() {}
+func (Delta) event()  {}
+func (Done) event()   {}
+func (Failed) event() {}
+
+// Chat sends a message and streams the reply. It returns an error, often a
+// *StatusError, when the server refuses the request; otherwise the events
+// end with Done or Failed, after which the channel is closed. Cancelling ctx
+// stops the reply and closes the channel, with or without a final event.
+func (c *Client) Chat(ctx context.Context, req ChatRequest) (<-chan Event, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/chat", req)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+
+		send := func(event Event) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		var reply strings.Builder
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 512*1024)
+		for scanner.Scan() {
+			var chunk chatChunk
+			if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+				// Errors after the reply started arrive as plain text.
+				send(Failed{Err: errors.New(strings.TrimSpace(scanner.Text()))})
+				return
+			}
+			var event Event = Delta{Text: chunk.Text}
+			if chunk.Queued > 0 {
+				event = Queued{Position: chunk.Queued}
+			} else {
+				reply.WriteString(chunk.Text)
+			}
+			if !send(event) {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			send(Failed{Err: err})
+			return
+		}
+		send(Done{Reply: reply.String()})
+	}()
+	return events, nil
+}
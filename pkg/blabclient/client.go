@@ -0,0 +1,276 @@
+// Package blabclient is a client for the blab API server: it lists and
+// manages models, streams chat replies as typed events and keeps
+// conversations in server-side sessions.
+//
+//	client, err := blabclient.New("http://127.0.0.1:8080", blabclient.WithToken(token))
+//	events, err := client.Chat(ctx, blabclient.ChatRequest{Model: "ollama/llama3", Text: "Hi"})
+//	for event := range events {
+//		switch event := event.(type) {
+//		case blabclient.Delta:
+//			fmt.Print(event.Text)
+//		case blabclient.Failed:
+//			log.Print(event.Err)
+//		}
+//	}
+package blabclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client talks to a blab server, over TCP or a Unix socket. It is safe for
+// concurrent use.
+type Client struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithToken sends token as a bearer token with every request.
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithHTTPClient sends the requests through client instead of a default one.
+// For a Unix socket, client must dial the socket itself.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) { c.http = client }
+}
+
+// New creates a client for the server at address: a URL such as
+// "http://127.0.0.1:8080", a bare host:port, or "unix:" followed by the path
+// of the server's socket.
+func New(address string, opts ...Option) (*Client, error) {
+	c := &Client{}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if path, ok := strings.CutPrefix(address, "unix:"); ok {
+		if path == "" {
+			return nil, fmt.Errorf("address %q names no socket", address)
+		}
+		// The host is ignored, every connection goes to the socket.
+		c.base = "http://blab"
+		if c.http == nil {
+			c.http = &http.Client{Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", path)
+				},
+			}}
+		}
+		return c, nil
+	}
+
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	base, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+	if base.Host == "" {
+		return nil, fmt.Errorf("address %q names no host", address)
+	}
+	c.base = strings.TrimSuffix(base.String(), "/")
+	if c.http == nil {
+		c.http = &http.Client{}
+	}
+	return c, nil
+}
+
+// StatusError is returned when the server answers with an error status.
+type StatusError struct {
+	StatusCode int
+	// Message is the server's explanation.
+	Message string
+	// RetryAfter is how long to wait before retrying a request refused with
+	// 429 Too Many Requests, zero when the server did not say.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return http.StatusText(e.StatusCode)
+	}
+	return e.Message
+}
+
+// ListModels lists the IDs of the models the client's token may use.
+func (c *Client) ListModels(ctx context.Context) ([]string, error) {
+	var models []string
+	return models, c.getJSON(ctx, "/models", &models)
+}
+
+// ModelDetails lists every model with its provider and details.
+func (c *Client) ModelDetails(ctx context.Context) ([]Model, error) {
+	var models []Model
+	return models, c.getJSON(ctx, "/models/info", &models)
+}
+
+// RefreshModels has the server fetch every provider's models now, instead
+// of waiting for its cached lists to expire.
+func (c *Client) RefreshModels(ctx context.Context) ([]Model, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/models/refresh", nil)
+	if err != nil {
+		return nil, err
+	}
+	var models []Model
+	return models, decode(resp, &models)
+}
+
+// PullModel downloads an Ollama model, calling fn with each progress update.
+func (c *Client) PullModel(ctx context.Context, name string, fn func(PullProgress)) error {
+	resp, err := c.do(ctx, http.MethodPost, "/models/pull", modelRequest{Name: name})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var progress PullProgress
+		if err := decoder.Decode(&progress); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if progress.Error != "" {
+			return fmt.Errorf("pulling %s: %s", name, progress.Error)
+		}
+		fn(progress)
+	}
+}
+
+// DeleteModel removes a local Ollama model.
+func (c *Client) DeleteModel(ctx context.Context, name string) error {
+	resp, err := c.do(ctx, http.MethodPost, "/models/delete", modelRequest{Name: name})
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Providers reports whether each provider was reachable when the server
+// last probed it.
+func (c *Client) Providers(ctx context.Context) ([]ProviderStatus, error) {
+	var statuses []ProviderStatus
+	return statuses, c.getJSON(ctx, "/providers", &statuses)
+}
+
+// AddProvider sets a provider's API key, or has the server probe it now when
+// it needs none, and returns the provider's status.
+func (c *Client) AddProvider(ctx context.Context, provider, apiKey string) (ProviderStatus, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/providers/add", providerRequest{Provider: provider, APIKey: apiKey})
+	if err != nil {
+		return ProviderStatus{}, err
+	}
+	var status ProviderStatus
+	return status, decode(resp, &status)
+}
+
+// LastTrace fetches the most recent provider request recorded with -trace.
+func (c *Client) LastTrace(ctx context.Context) (Trace, error) {
+	var trace Trace
+	return trace, c.getJSON(ctx, "/trace/last", &trace)
+}
+
+// CreateSession starts a new, empty conversation.
+func (c *Client) CreateSession(ctx context.Context) (Session, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/sessions", nil)
+	if err != nil {
+		return Session{}, err
+	}
+	var session Session
+	return session, decode(resp, &session)
+}
+
+// Sessions lists the server's sessions, oldest first.
+func (c *Client) Sessions(ctx context.Context) ([]Session, error) {
+	var sessions []Session
+	return sessions, c.getJSON(ctx, "/sessions", &sessions)
+}
+
+// Session fetches a session with its messages.
+func (c *Client) Session(ctx context.Context, id string) (Session, error) {
+	var session Session
+	return session, c.getJSON(ctx, "/sessions/"+url.PathEscape(id), &session)
+}
+
+// DeleteSession ends a session, forgetting its messages.
+func (c *Client) DeleteSession(ctx context.Context, id string) error {
+	resp, err := c.do(ctx, http.MethodDelete, "/sessions/"+url.PathEscape(id), nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (c *Client) getJSON(ctx context.Context, path string, v any) error {
+	resp, err := c.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	return decode(resp, v)
+}
+
+// do sends body, if any, as JSON and turns an error status into a
+// StatusError.
+func (c *Client) do(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(resp.Body)
+		statusErr := &StatusError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			statusErr.RetryAfter = time.Duration(seconds) * time.Second
+		}
+		return nil, statusErr
+	}
+	return resp, nil
+}
+
+func decode(resp *http.Response, v any) error {
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decoding %s response: %w", resp.Request.URL.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package blabclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bz888/blab/internal/api/server/client"
+	"github.com/bz888/blab/internal/api/server/handlers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echoOllama is an Ollama with one model that repeats the user's message.
+type echoOllama struct{}
+
+func (echoOllama) GetModels() ([]client.OllamaModel, error) {
+	return []client.OllamaModel{{Name: "llama3"}}, nil
+}
+
+func (echoOllama) Chat(ctx context.Context, req *client.ServerChatRequest, fn func([]byte) error) error {
+	last := req.Messages[len(req.Messages)-1].Content
+	for _, part := range []string{"you said: ", last} {
+		bts, _ := json.Marshal(client.OllamaAPIResponse{Message: client.ServerChatMessage{Content: part}})
+		if err := fn(bts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (echoOllama) Pull(ctx context.Context, name string, fn func(client.PullProgress) error) error {
+	return errors.New("pull disabled")
+}
+
+func (echoOllama) Delete(ctx context.Context, name string) error {
+	return nil
+}
+
+// newServer serves the API's chat, model and session routes.
+func newServer(t *testing.T) *httptest.Server {
+	handler := handlers.NewHandler(nil, echoOllama{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/chat", handler.ProcessTextHandler)
+	mux.HandleFunc("/models", handler.ModelHandler)
+	mux.HandleFunc("/models/info", handler.ModelInfoHandler)
+	mux.HandleFunc("/sessions", handler.SessionsHandler)
+	mux.HandleFunc("/sessions/", handler.SessionHandler)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func collect(t *testing.T, events <-chan Event) []Event {
+	var all []Event
+	for event := range events {
+		all = append(all, event)
+	}
+	return all
+}
+
+func TestChatInSession(t *testing.T) {
+	server := newServer(t)
+	c, err := New(server.URL)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	models, err := c.ListModels(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ollama/llama3"}, models)
+	details, err := c.ModelDetails(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "ollama", details[0].Provider)
+
+	session, err := c.CreateSession(ctx)
+	require.NoError(t, err)
+
+	events, err := c.Chat(ctx, ChatRequest{Model: "llama3", Text: "hi", Session: session.ID})
+	require.NoError(t, err)
+	assert.Equal(t, []Event{
+		Delta{Text: "you said: "},
+		Delta{Text: "hi"},
+		Done{Reply: "you said: hi"},
+	}, collect(t, events))
+
+	session, err = c.Session(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []Message{
+		{Role: RoleUser, Content: "hi"},
+		{Role: RoleAssistant, Content: "you said: hi"},
+	}, session.Messages)
+
+	sessions, err := c.Sessions(ctx)
+	require.NoError(t, err)
+	assert.Len(t, sessions, 1)
+
+	require.NoError(t, c.DeleteSession(ctx, session.ID))
+	_, err = c.Chat(ctx, ChatRequest{Model: "llama3", Text: "hi", Session: session.ID})
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+}
+
+func TestChatEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		var req ChatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.Model == "busy" {
+			w.Header().Set("Retry-After", "3")
+			http.Error(w, "ollama is busy, try again later", http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"queued":2}` + "\n" + `{"queued":1}` + "\n" + `{"processedText":"Hel"}` + "\n"))
+		w.Write([]byte("Failed to process request: connection reset\n"))
+	}))
+	defer server.Close()
+	c, err := New(server.URL, WithToken("secret"))
+	require.NoError(t, err)
+
+	events, err := c.Chat(context.Background(), ChatRequest{Model: "llama3", Text: "hi"})
+	require.NoError(t, err)
+	all := collect(t, events)
+	require.Len(t, all, 4)
+	assert.Equal(t, []Event{Queued{Position: 2}, Queued{Position: 1}, Delta{Text: "Hel"}}, all[:3])
+	assert.EqualError(t, all[3].(Failed).Err, "Failed to process request: connection reset")
+
+	_, err = c.Chat(context.Background(), ChatRequest{Model: "busy", Text: "hi"})
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusTooManyRequests, statusErr.StatusCode)
+	assert.Equal(t, 3*time.Second, statusErr.RetryAfter)
+	assert.EqualError(t, err, "ollama is busy, try again later")
+}
+
+func TestChatCancel(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"processedText":"Hel"}` + "\n"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+	c, err := New(server.URL)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := c.Chat(ctx, ChatRequest{Model: "llama3", Text: "hi"})
+	require.NoError(t, err)
+	assert.Equal(t, Delta{Text: "Hel"}, <-events)
+	cancel()
+	for range events {
+	}
+}
+
+func TestNewAddresses(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "blab.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`["ollama/llama3"]`))
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	c, err := New("unix:" + socket)
+	require.NoError(t, err)
+	models, err := c.ListModels(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ollama/llama3"}, models)
+
+	c, err = New("127.0.0.1:8080")
+	require.NoError(t, err)
+	assert.Equal(t, "http://127.0.0.1:8080", c.base)
+
+	_, err = New("unix:")
+	assert.Error(t, err)
+	_, err = New("http://")
+	assert.Error(t, err)
+}
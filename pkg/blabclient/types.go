@@ -0,0 +1,122 @@
+package blabclient
+
+import (
+	"net/http"
+	"time"
+)
+
+// Model describes a model offered by one of the server's providers. Fields a
+// provider does not report are left empty.
+type Model struct {
+	// ID is the provider-qualified name, e.g. "ollama/llama3:latest".
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	Provider      string    `json:"provider"`
+	Family        string    `json:"family,omitempty"`
+	ParameterSize string    `json:"parameterSize,omitempty"`
+	Quantization  string    `json:"quantization,omitempty"`
+	Size          int64     `json:"size,omitempty"`
+	ModifiedAt    time.Time `json:"modifiedAt,omitempty"`
+}
+
+// PullProgress is one status update while a model is pulled.
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ProviderStatus is whether a provider was reachable when the server last
+// probed it.
+type ProviderStatus struct {
+	Provider  string        `json:"provider"`
+	Reachable bool          `json:"reachable"`
+	Latency   time.Duration `json:"latency"`
+	// Error is why the latest probe failed. LastError keeps the most recent
+	// failure after the provider recovers.
+	Error     string    `json:"error,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+	// Since is when the provider last became reachable or unreachable.
+	Since time.Time `json:"since"`
+}
+
+// Trace is a request the server sent to a provider, recorded with -trace.
+type Trace struct {
+	ID     int64     `json:"id"`
+	Start  time.Time `json:"start"`
+	Method string    `json:"method"`
+	URL    string    `json:"url"`
+
+	RequestHeader http.Header `json:"request_header"`
+	RequestBody   string      `json:"request_body,omitempty"`
+
+	Status         string      `json:"status,omitempty"`
+	ResponseHeader http.Header `json:"response_header,omitempty"`
+	// Headers is how long the provider took to answer with headers;
+	// Duration also includes reading the whole body.
+	Headers  time.Duration `json:"headers"`
+	Duration time.Duration `json:"duration"`
+	Chunks   []TraceChunk  `json:"chunks,omitempty"`
+	Err      string        `json:"error,omitempty"`
+}
+
+// TraceChunk is one read of a traced response body, as it arrived.
+type TraceChunk struct {
+	// At is the time since the request started.
+	At   time.Duration `json:"at"`
+	Data string        `json:"data"`
+}
+
+// Roles of the messages in a session.
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message is one turn of a conversation.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Session is a conversation kept by the server. Only the token that created
+// it can see it, and the server forgets it a day after its last message.
+type Session struct {
+	ID       string    `json:"id"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+	Messages []Message `json:"messages"`
+}
+
+// ChatRequest is a message to send to a model.
+type ChatRequest struct {
+	// Model is a model ID, or a name offered by a single provider.
+	Model string `json:"model"`
+	Text  string `json:"text"`
+	// Language, a BCP 47 tag, asks the model to reply in that language.
+	Language string `json:"language,omitempty"`
+	// Session is the conversation the message belongs to, the token's
+	// default session on the server when empty.
+	Session string `json:"session,omitempty"`
+}
+
+// modelRequest names the model to pull or delete.
+type modelRequest struct {
+	Name string `json:"name"`
+}
+
+// providerRequest sets the API key of a provider.
+type providerRequest struct {
+	Provider string `json:"provider"`
+	APIKey   string `json:"api_key,omitempty"`
+}
+
+// chatChunk is one line of the server's chat stream.
+type chatChunk struct {
+	Text   string `json:"processedText"`
+	Queued int    `json:"queued,omitempty"`
+}